@@ -1,12 +1,18 @@
 package cssc
 
 import (
+	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/samsarahq/go/oops"
-	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/ast"
 	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/internal/parser"
 	"github.com/stephen/cssc/internal/printer"
@@ -26,24 +32,122 @@ type Options struct {
 	Reporter Reporter
 
 	Transforms transforms.Options
+
+	// Minify shortens the printed output, e.g. by dropping comments and
+	// unnecessary zeros. Comments starting with /*! are kept.
+	Minify bool
+
+	// Pretty prints the output with each rule and declaration on its own line.
+	// It is ignored if Minify is set.
+	Pretty bool
+
+	// Indent is the number of spaces to indent by when Pretty is set. If it is
+	// zero, two spaces are used.
+	Indent int
+
+	// Bundle compiles each entry and everything it imports into a single output
+	// file. Files that are imported more than once are only included once, at the
+	// position of their last import, to keep the same cascade order. Bundle overrides
+	// Transforms.ImportRules.
+	Bundle bool
+
+	// Outdir is the directory to name output files in. Output files keep their
+	// path relative to the deepest directory containing every entry. If neither
+	// Outdir nor Outfile are set, outputs are named by their absolute source path.
+	Outdir string
+
+	// Outfile is the name of the output file. It can only be used with a single
+	// entry and Bundle.
+	Outfile string
+
+	// FS is the file system to read sources from. If it is set, paths in Entry and
+	// paths returned by Resolve are paths in FS, i.e. slash-separated and unrooted, and
+	// outputs are named by those paths. If it is nil, sources are read from disk.
+	FS fs.FS
+
+	// Resolve returns the path of the file that an @import refers to, given the
+	// import's specifier, e.g. "./other.css", and the path of the importing file. If
+	// it is nil, specifiers are resolved relative to the importing file's directory.
+	Resolve func(specifier, importer string) (string, error)
+
+	// SourceMap controls how source maps are written. By default, they are added
+	// to the end of each output file.
+	SourceMap SourceMap
+
+	// SourceRoot is the sourceRoot of source maps, which consumers prepend to the
+	// paths of sources.
+	SourceRoot string
+
+	// ExcludeSourcesContent leaves the content of sources out of source maps.
+	ExcludeSourcesContent bool
 }
 
+// SourceMap controls how source maps are written.
+type SourceMap int
+
+const (
+	// SourceMapInline adds the source map to the end of each output file as a data url.
+	// It is the default.
+	SourceMapInline SourceMap = iota
+	// SourceMapExternal writes the source map of each output file to a separate file
+	// with .map appended to the output's name, e.g. index.css.map.
+	SourceMapExternal
+	// SourceMapNone does not write source maps.
+	SourceMapNone
+)
+
 func newCompilation(opts Options) *compilation {
 	c := &compilation{
-		sources:        make(map[string]int),
-		sourcesByIndex: make(map[int]*sources.Source),
-		outputsByIndex: make(map[int]struct{}),
-		astsByIndex:    make(map[int]*ast.Stylesheet),
-		lockersByIndex: make(map[int]*sync.Mutex),
-		result:         newResult(),
-		reporter:       logging.DefaultReporter,
-		transforms:     opts.Transforms,
+		sources:         make(map[string]int),
+		sourcesByIndex:  make(map[int]*sources.Source),
+		outputsByIndex:  make(map[int]struct{}),
+		astsByIndex:     make(map[int]*ast.Stylesheet),
+		lockersByIndex:  make(map[int]*sync.Mutex),
+		importsByIndex:  make(map[int][]importEdge),
+		importGraph:     make(map[int][]int),
+		names:           make(map[ast.Node]string),
+		inputSourceMaps: make(map[int]*printer.InputSourceMap),
+		inputSources:    make(map[string]*sources.Source),
+		result:          newResult(),
+		reporter:        logging.DefaultReporter,
+		transforms:      opts.Transforms,
+		bundle:          opts.Bundle,
+		outdir:          opts.Outdir,
+		outfile:         opts.Outfile,
+		fs:              opts.FS,
+		resolve:         opts.Resolve,
+		sourceMap:       opts.SourceMap,
+		printOptions: printer.Options{
+			Minify:                opts.Minify,
+			Pretty:                opts.Pretty,
+			Indent:                opts.Indent,
+			SourceRoot:            opts.SourceRoot,
+			ExcludeSourcesContent: opts.ExcludeSourcesContent,
+		},
 	}
 
 	if opts.Reporter != nil {
 		c.reporter = opts.Reporter
 	}
 
+	if c.bundle {
+		c.transforms.ImportRules = transforms.ImportRulesInline
+	}
+
+	if c.resolve == nil {
+		c.resolve = c.resolveRelative
+	}
+
+	if c.outdir != "" {
+		paths := make([]string, 0, len(opts.Entry))
+		for _, e := range opts.Entry {
+			if path, err := c.sourcePath(e); err == nil {
+				paths = append(paths, path)
+			}
+		}
+		c.outbase = commonDir(paths)
+	}
+
 	return c
 }
 
@@ -60,47 +164,144 @@ type compilation struct {
 	outputsByIndex map[int]struct{}
 	lockersByIndex map[int]*sync.Mutex
 
+	// importsByIndex is the list of imports of each source, in order.
+	importsByIndex map[int][]importEdge
+
+	// importGraph is the source indexes imported by each source. It is built as
+	// sources are parsed and is kept free of cycles.
+	importGraph map[int][]int
+
+	// names is the original name of each node renamed by a transform, for source maps.
+	names map[ast.Node]string
+
+	// inputSourceMaps is the source map of each source that has one, e.g. because it
+	// was generated by Sass, and inputSources is the set of original sources in them.
+	inputSourceMaps map[int]*printer.InputSourceMap
+	inputSources    map[string]*sources.Source
+
 	result *Result
 
 	reporter Reporter
 
 	transforms transforms.Options
+
+	bundle  bool
+	outdir  string
+	outfile string
+
+	// outbase is the directory that output paths are relative to when outdir is set.
+	outbase string
+
+	// fs is the file system to read sources from, or nil to read them from disk.
+	fs fs.FS
+
+	// resolve returns the path of the file imported by an @import specifier.
+	resolve func(specifier, importer string) (string, error)
+
+	// printOptions is the set of options for printing outputs. OriginalSource
+	// is set separately for each output.
+	printOptions printer.Options
+
+	sourceMap SourceMap
+
+	// skipImports is set if imported files are not loaded, e.g. by Transform when
+	// imports are passed through, since it has no outputs for them.
+	skipImports bool
+}
+
+// sourcePath returns the path that identifies a source. Paths on disk are made
+// absolute, and paths in c.fs are cleaned.
+func (c *compilation) sourcePath(p string) (string, error) {
+	if c.fs != nil {
+		p = path.Clean(p)
+		if !fs.ValidPath(p) {
+			return "", fmt.Errorf("invalid path: %s", p)
+		}
+		return p, nil
+	}
+
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", oops.Wrapf(err, "failed to make path absolute: %s", p)
+	}
+	return abs, nil
+}
+
+// readFile reads the source at a path returned by sourcePath.
+func (c *compilation) readFile(p string) ([]byte, error) {
+	if c.fs != nil {
+		return fs.ReadFile(c.fs, p)
+	}
+
+	return ioutil.ReadFile(p)
+}
+
+// resolveRelative resolves an @import specifier relative to the importer's directory.
+// It is the default resolver.
+func (c *compilation) resolveRelative(specifier, importer string) (string, error) {
+	return c.join(c.dir(importer), specifier), nil
 }
 
 // addSource will read in a path and assign it a source index. If
 // it's already been loaded, the cached source is returned.
 func (c *compilation) addSource(path string) (int, error) {
+	p, err := c.sourcePath(path)
+	if err != nil {
+		return 0, err
+	}
+
 	c.mu.RLock()
-	if _, ok := c.sources[path]; ok {
+	if i, ok := c.sources[p]; ok {
 		defer c.mu.RUnlock()
-		return c.sources[path], nil
+		return i, nil
 	}
 	c.mu.RUnlock()
 
-	abs, err := filepath.Abs(path)
-	if err != nil {
-		return 0, oops.Wrapf(err, "failed to make path absolute: %s", path)
-	}
-
-	in, err := ioutil.ReadFile(abs)
+	in, err := c.readFile(p)
 	if err != nil {
 		return 0, oops.Wrapf(err, "failed to read file: %s", path)
 	}
 
-	source := &sources.Source{
-		Content: string(in),
-		Path:    abs,
-	}
+	return c.addSourceContent(p, string(in)), nil
+}
 
+// addSourceContent assigns a source index to the content of the source at p,
+// which must be a path returned by sourcePath. If a source with the same path
+// has already been added, its index is returned instead.
+func (c *compilation) addSourceContent(p, content string) int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+
+	// Another caller may have loaded the same file in the meantime.
+	if i, ok := c.sources[p]; ok {
+		return i
+	}
+
 	i := c.nextIndex
-	c.sources[abs] = i
+	source := &sources.Source{
+		Content: content,
+		Path:    p,
+		Index:   i,
+	}
+	c.sources[p] = i
 	c.sourcesByIndex[i] = source
 	c.lockersByIndex[i] = &sync.Mutex{}
 
 	c.nextIndex++
-	return i, nil
+	return i
+}
+
+// importEdge is an @import of one source by another.
+type importEdge struct {
+	// index is the source index of the imported file, or -1 if it could not be loaded
+	// or would create an import cycle.
+	index int
+
+	// rule is the @import rule, which holds any import conditions.
+	rule *ast.AtRule
+
+	// cycle is set if the import would create an import cycle.
+	cycle bool
 }
 
 func newResult() *Result {
@@ -122,51 +323,95 @@ type Result struct {
 // parseFile also runs the last transformation pass on the output. Note that we
 // don't make this function print the output as well so that we can make the current
 // file available to any callers as a dependency.
-func (c *compilation) parseFile(file string, hasOutput bool) *ast.Stylesheet {
+//
+// The source index is returned along with the stylesheet, which is nil if the file
+// could not be loaded.
+func (c *compilation) parseFile(file string, hasOutput bool) (int, *ast.Stylesheet) {
 	// Assign the file a source index.
 	idx, err := c.addSource(file)
 	if err != nil {
 		c.reporter.AddError(err)
-		return nil
+		return -1, nil
 	}
 
+	return idx, c.parseSource(idx, hasOutput)
+}
+
+// parseSource parses the source with the given index. See parseFile.
+func (c *compilation) parseSource(idx int, hasOutput bool) *ast.Stylesheet {
+	c.mu.Lock()
 	if hasOutput {
 		c.outputsByIndex[idx] = struct{}{}
 	}
+	locker := c.lockersByIndex[idx]
+	source := c.sourcesByIndex[idx]
+	c.mu.Unlock()
 
 	// Grab the lock for this source, since multiple callers might try
 	// to parse the same file.
-	locker := c.lockersByIndex[idx]
 	locker.Lock()
 	defer locker.Unlock()
-	if ss, ok := c.astsByIndex[idx]; ok {
+	c.mu.RLock()
+	ss, ok := c.astsByIndex[idx]
+	c.mu.RUnlock()
+	if ok {
 		return ss
 	}
 
-	source := c.sourcesByIndex[idx]
-	ss, err := parser.Parse(source)
-	if err != nil {
-		c.reporter.AddError(err)
-		return nil
+	ss = parser.ParseWithReporter(source, c.reporter)
+	c.loadInputSourceMap(source, ss)
+
+	// Load the imported files first, so that any import cycles can be found before
+	// waiting on the lock of an imported file that is waiting on this one.
+	imports := make([]importEdge, len(ss.Imports))
+	for i, imp := range ss.Imports {
+		imports[i] = importEdge{index: -1, rule: imp.AtRule}
+		if c.skipImports {
+			continue
+		}
+
+		resolved, err := c.resolve(imp.Value, source.Path)
+		if err != nil {
+			start := imp.AtRule.Loc.Position
+			c.reporter.AddError(logging.LocationErrorf(source, start, start+len("@import"), "failed to resolve import %q: %v", imp.Value, err))
+			continue
+		}
+
+		importedIdx, err := c.addSource(resolved)
+		if err != nil {
+			start := imp.AtRule.Loc.Position
+			c.reporter.AddError(logging.LocationErrorf(source, start, start+len("@import"), "failed to read import %q: %v", imp.Value, oops.Cause(err)))
+			continue
+		}
+		imports[i].index = importedIdx
 	}
+	c.addImportEdges(idx, imports)
+	imports = removeCyclicImports(ss, imports)
 
 	// Immediately look at the imports from the file and feed those dependencies
-	// into parseFile as well. If we're set to inline imports, then we'll use
+	// into parseSource as well. If we're set to inline imports, then we'll use
 	// collect those dependency ASTs to let the transformer replace them.
 	var mu sync.Mutex
 	replacements := make(map[*ast.AtRule]*ast.Stylesheet)
 	var wg errgroup.Group
-	for _, imp := range ss.Imports {
+	for _, imp := range imports {
+		if imp.index < 0 {
+			continue
+		}
+
+		imp := imp
 		wg.Go(func() error {
-			rel := filepath.Join(filepath.Dir(source.Path), imp.Value)
 			// If import passthrough is on, then every referenced file makes it to the output.
-			imported := c.parseFile(rel, c.transforms.ImportRules == transforms.ImportRulesPassthrough)
+			imported := c.parseSource(imp.index, c.transforms.ImportRules == transforms.ImportRulesPassthrough)
 
 			mu.Lock()
 			defer mu.Unlock()
-			if imported != nil {
-				replacements[imp.AtRule] = imported
+			// When bundling, imported files are added to the bundle separately
+			// so that they are only included once.
+			if c.bundle {
+				imported = &ast.Stylesheet{}
 			}
+			replacements[imp.rule] = imported
 			return nil
 		})
 	}
@@ -176,6 +421,7 @@ func (c *compilation) parseFile(file string, hasOutput bool) *ast.Stylesheet {
 		Options:        c.transforms,
 		OriginalSource: source,
 		Reporter:       c.reporter,
+		Names:          make(map[ast.Node]string),
 	}
 
 	if c.transforms.ImportRules == transforms.ImportRulesInline {
@@ -183,17 +429,268 @@ func (c *compilation) parseFile(file string, hasOutput bool) *ast.Stylesheet {
 	}
 
 	ss = transformer.Transform(ss, opts)
+	c.mu.Lock()
 	c.astsByIndex[idx] = ss
+	c.importsByIndex[idx] = imports
+	for node, name := range opts.Names {
+		c.names[node] = name
+	}
+	c.mu.Unlock()
+	return ss
+}
+
+// addImportEdges adds the imports of idx to the import graph. Imports that would
+// create a cycle are reported and marked as cyclic, so that they are not
+// followed. Since the graph never has cycles, parseSource never waits on the lock
+// of a source that is (indirectly) waiting on it.
+func (c *compilation) addImportEdges(idx int, imports []importEdge) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, imp := range imports {
+		if imp.index < 0 {
+			continue
+		}
+
+		if cycle := c.findImportPath(imp.index, idx, make(map[int]bool)); cycle != nil {
+			// Show the cycle starting from the imported file, e.g. a.css -> b.css -> a.css
+			// for the import of a.css by b.css.
+			source := c.sourcesByIndex[idx]
+			paths := make([]string, 0, len(cycle)+1)
+			for _, i := range append(cycle, imp.index) {
+				paths = append(paths, displayPath(c.sourcesByIndex[i].Path))
+			}
+
+			start := imp.rule.Loc.Position
+			c.reporter.AddError(logging.LocationErrorf(source, start, start+len("@import"), "circular import: %s", strings.Join(paths, " -> ")))
+			imports[i].index = -1
+			imports[i].cycle = true
+			continue
+		}
+
+		c.importGraph[idx] = append(c.importGraph[idx], imp.index)
+	}
+}
+
+// removeCyclicImports removes the @import rules that would create a cycle from ss,
+// since the file that they import is already included before them. It returns the
+// remaining imports.
+func removeCyclicImports(ss *ast.Stylesheet, imports []importEdge) []importEdge {
+	cyclic := make(map[ast.Node]bool)
+	rv := imports[:0:0]
+	for _, imp := range imports {
+		if imp.cycle {
+			cyclic[imp.rule] = true
+			continue
+		}
+		rv = append(rv, imp)
+	}
+
+	if len(cyclic) == 0 {
+		return imports
+	}
+
+	nodes := ss.Nodes[:0:0]
+	for _, n := range ss.Nodes {
+		if !cyclic[n] {
+			nodes = append(nodes, n)
+		}
+	}
+	ss.Nodes = nodes
+
+	specifiers := ss.Imports[:0:0]
+	for _, imp := range ss.Imports {
+		if !cyclic[imp.AtRule] {
+			specifiers = append(specifiers, imp)
+		}
+	}
+	ss.Imports = specifiers
+
+	return rv
+}
+
+// findImportPath returns the sources on a path of imports from "from" to "to",
+// including both ends, or nil if there is no path. c.mu must be held.
+func (c *compilation) findImportPath(from, to int, visited map[int]bool) []int {
+	if from == to {
+		return []int{to}
+	}
+
+	if visited[from] {
+		return nil
+	}
+	visited[from] = true
+
+	for _, next := range c.importGraph[from] {
+		if path := c.findImportPath(next, to, visited); path != nil {
+			return append([]int{from}, path...)
+		}
+	}
+
+	return nil
+}
+
+// displayPath returns path relative to the working directory if it is inside of it,
+// to keep error messages short.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	return rel
+}
+
+// bundleEntry returns a stylesheet with the contents of the entry and every file it
+// imports. Each file is included once, at the position of its last import, since
+// that is the position that decides the cascade order. Files imported with different
+// conditions, e.g. media queries, are included once per set of conditions.
+// See https://www.w3.org/TR/css-cascade-4/#at-import.
+func (c *compilation) bundleEntry(entry int) *ast.Stylesheet {
+	var order []bundlePart
+	c.appendImportOrder(entry, nil, make(map[int]bool), &order)
+
+	// Keep only the last occurrence of each file.
+	seen := make(map[string]struct{}, len(order))
+	deduped := make([]bundlePart, 0, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		key := order[i].key()
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		deduped = append(deduped, order[i])
+	}
+
+	ss := &ast.Stylesheet{}
+	for i := len(deduped) - 1; i >= 0; i-- {
+		part := deduped[i]
+		nodes := c.astsByIndex[part.index].Nodes
+		for j := len(part.conditions) - 1; j >= 0; j-- {
+			nodes = transformer.WrapImportConditions(part.conditions[j], nodes)
+		}
+		ss.Nodes = append(ss.Nodes, nodes...)
+	}
+
 	return ss
 }
 
+// bundlePart is a source included in a bundle.
+type bundlePart struct {
+	index int
+
+	// conditions is the chain of @import rules that included the source, from
+	// the outermost import to the innermost.
+	conditions []*ast.AtRule
+}
+
+// key returns a key that is the same for parts with the same source and conditions.
+func (b bundlePart) key() string {
+	var key strings.Builder
+	key.WriteString(strconv.Itoa(b.index))
+	for _, imp := range b.conditions {
+		for _, prelude := range imp.Preludes[1:] {
+			key.WriteRune(' ')
+			out, err := printer.Print(prelude, printer.Options{})
+			if err != nil {
+				continue
+			}
+			key.WriteString(out)
+		}
+		key.WriteRune(';')
+	}
+
+	return key.String()
+}
+
+// appendImportOrder appends the files imported by idx, followed by idx itself,
+// to order. Files may be appended more than once. conditions is the chain of
+// @import rules that led to idx.
+func (c *compilation) appendImportOrder(idx int, conditions []*ast.AtRule, visiting map[int]bool, order *[]bundlePart) {
+	// Import cycles are not followed by parseFile, but check anyway.
+	if visiting[idx] {
+		return
+	}
+
+	visiting[idx] = true
+	for _, imp := range c.importsByIndex[idx] {
+		if imp.index < 0 {
+			continue
+		}
+
+		chain := conditions
+		if len(imp.rule.Preludes) > 1 {
+			chain = append(append([]*ast.AtRule(nil), conditions...), imp.rule)
+		}
+		c.appendImportOrder(imp.index, chain, visiting, order)
+	}
+	visiting[idx] = false
+
+	*order = append(*order, bundlePart{index: idx, conditions: conditions})
+}
+
+// outputPath returns the name of the output file for a source.
+func (c *compilation) outputPath(source *sources.Source) string {
+	if c.outfile != "" {
+		return c.outfile
+	}
+
+	if c.outdir != "" {
+		return filepath.Join(c.outdir, relativeOutput(c.outbase, source.Path))
+	}
+
+	return source.Path
+}
+
+// print prints the output for source to path, returning the output files,
+// which include a source map if it is written to a separate file.
+func (c *compilation) print(ss *ast.Stylesheet, source *sources.Source, path string) (map[string]string, error) {
+	opts := c.printOptions
+	opts.OriginalSource = source
+	opts.Sources = c.sourcesByIndex
+	opts.SourceMapFile = path
+	opts.Names = c.names
+	opts.InputSourceMaps = c.inputSourceMaps
+
+	switch c.sourceMap {
+	case SourceMapNone:
+		opts.OriginalSource = nil
+		out, err := printer.Print(ss, opts)
+		return map[string]string{path: out}, err
+
+	case SourceMapExternal:
+		out, sourceMap, err := printer.PrintWithSourceMap(ss, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		out += "\n/*# sourceMappingURL=" + filepath.Base(path) + ".map */\n"
+		return map[string]string{path: out, path + ".map": sourceMap}, nil
+
+	default:
+		out, err := printer.Print(ss, opts)
+		return map[string]string{path: out}, err
+	}
+}
+
 // Compile runs a compilation with the specified Options.
 func Compile(opts Options) *Result {
 	c := newCompilation(opts)
 
+	if opts.Outfile != "" && (len(opts.Entry) != 1 || !opts.Bundle) {
+		c.reporter.AddError(fmt.Errorf("Outfile can only be used with a single entry and Bundle"))
+		return c.result
+	}
+
 	var wg errgroup.Group
 
 	for _, e := range opts.Entry {
+		e := e
 		wg.Go(func() error {
 			c.parseFile(e, true)
 			return nil
@@ -205,20 +702,24 @@ func Compile(opts Options) *Result {
 	for i := range c.outputsByIndex {
 		idx := i
 		wg.Go(func() error {
-			// XXX: this is the wrong file name
 			source := c.sourcesByIndex[idx]
-			c.result.mu.Lock()
-			defer c.result.mu.Unlock()
+			ss := c.astsByIndex[idx]
+			if c.bundle {
+				ss = c.bundleEntry(idx)
+			}
 
-			out, err := printer.Print(c.astsByIndex[idx], printer.Options{
-				OriginalSource: source,
-			})
+			path := c.outputPath(source)
+			files, err := c.print(ss, source, path)
 			if err != nil {
 				c.reporter.AddError(err)
 				return nil
 			}
 
-			c.result.Files[source.Path] = out
+			c.result.mu.Lock()
+			defer c.result.mu.Unlock()
+			for name, content := range files {
+				c.result.Files[name] = content
+			}
 			return nil
 		})
 	}
@@ -227,6 +728,35 @@ func Compile(opts Options) *Result {
 	return c.result
 }
 
+// commonDir returns the deepest directory containing all of paths, which must
+// be cleaned, and either all absolute or all relative.
+func commonDir(paths []string) string {
+	var dir string
+	for i, abs := range paths {
+		if i == 0 {
+			dir = filepath.Dir(abs)
+			continue
+		}
+
+		for !strings.HasPrefix(abs, dir+string(filepath.Separator)) && dir != filepath.Dir(dir) {
+			dir = filepath.Dir(dir)
+		}
+	}
+
+	return dir
+}
+
+// relativeOutput returns path relative to base. If path is outside
+// of base, only its file name is used.
+func relativeOutput(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.Base(path)
+	}
+
+	return rel
+}
+
 // Reporter is an error and warning reporter.
 //
 // Note that it is the same type as logging.Reporter, which is
@@ -1,11 +1,15 @@
 package cssc_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stephen/cssc"
 	"github.com/stephen/cssc/transforms"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TestReporter []error
@@ -55,3 +59,53 @@ func TestApi_BrokenImport(t *testing.T) {
 	assert.Len(t, result.Files, 1)
 	assert.Len(t, errors, 1)
 }
+
+func TestApi_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"css/index.css":        {Data: []byte(`@import "./theme/colors.css"; .index { color: red; }`)},
+		"css/theme/colors.css": {Data: []byte(`.colors { color: blue; }`)},
+	}
+
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"css/index.css"},
+		FS:       fsys,
+		Reporter: &errors,
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	assert.Len(t, errors, 0)
+	require.Contains(t, result.Files, "css/index.css")
+	assert.True(t, strings.HasPrefix(result.Files["css/index.css"], ".colors{color:blue}.index{color:red}"))
+}
+
+func TestApi_Resolve(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.css":        {Data: []byte(`@import "theme"; @import "missing";`)},
+		"themes/theme.css": {Data: []byte(`.theme { color: red; }`)},
+	}
+
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"index.css"},
+		FS:       fsys,
+		Reporter: &errors,
+		Resolve: func(specifier, importer string) (string, error) {
+			if specifier == "missing" {
+				return "", fmt.Errorf("not found")
+			}
+			return "themes/" + specifier + ".css", nil
+		},
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	if assert.Len(t, errors, 1) {
+		assert.True(t, strings.Contains(errors[0].Error(), `failed to resolve import "missing": not found`), errors[0].Error())
+	}
+	require.Contains(t, result.Files, "index.css")
+	assert.True(t, strings.HasPrefix(result.Files["index.css"], `.theme{color:red}@import "missing";`))
+}
@@ -0,0 +1,122 @@
+// Package ast declares the types used to represent css stylesheets, along
+// with Walk and Inspect for traversing them.
+package ast
+
+// Node is any top-level stylesheet rule.
+type Node interface {
+	Location() Loc
+}
+
+// Loc is a location in the source.
+type Loc struct {
+	Position int
+
+	// Source is the index of the source that Position is in. It tells sources
+	// apart when nodes from several of them are combined, e.g. by inlining imports.
+	Source int
+}
+
+// Location implements Node.
+func (l Loc) Location() Loc { return l }
+
+// Stylesheet is a CSS stylesheet.
+type Stylesheet struct {
+	Nodes []Node
+
+	Imports []ImportSpecifier
+}
+
+// ImportSpecifier is a pointer to an import at rule.
+type ImportSpecifier struct {
+	Value string
+
+	// AtRule is a pointer to the at rule that specified this import.
+	AtRule *AtRule
+}
+
+// Location implements Node.
+func (l Stylesheet) Location() Loc { return Loc{} }
+
+// Comment represents a comment. Comments may be top-level nodes, values of a
+// declaration or parts of a selector.
+type Comment struct {
+	Loc
+
+	Text string
+}
+
+func (Comment) isValue()    {}
+func (Comment) isSelector() {}
+
+var _ Value = Comment{}
+var _ SelectorPart = Comment{}
+
+// Block can either be a block of rules or declarations.
+// See https://www.w3.org/TR/css-syntax-3/#declaration-rule-list.
+type Block interface {
+	Node
+
+	isBlock()
+}
+
+// DeclarationBlock is a block containing a set of declarations.
+type DeclarationBlock struct {
+	Loc
+
+	Declarations []*Declaration
+
+	// Rules is the set of at-rules inside of the block, e.g. margin
+	// rules inside of @page, along with nested style rules and comments
+	// that aren't directly before a declaration. They are printed in source
+	// order with the declarations.
+	Rules []Node
+}
+
+// Nodes returns the declarations and rules in b in the order that they appear in
+// the source, by their locations. Declarations and rules from different sources,
+// e.g. ones added by a transform, keep their order within their own list.
+func (b *DeclarationBlock) Nodes() []Node {
+	nodes := make([]Node, 0, len(b.Declarations)+len(b.Rules))
+	rules := b.Rules
+	for _, d := range b.Declarations {
+		for len(rules) > 0 && rules[0].Location().Source == d.Source && rules[0].Location().Position < d.Position {
+			nodes = append(nodes, rules[0])
+			rules = rules[1:]
+		}
+		nodes = append(nodes, d)
+	}
+
+	return append(nodes, rules...)
+}
+
+// QualifiedRuleBlock is a block containing a set of rules. Rules
+// may be qualified rules, nested at-rules (e.g. @media inside of
+// @supports), or comments.
+type QualifiedRuleBlock struct {
+	Loc
+
+	Rules []Node
+}
+
+func (DeclarationBlock) isBlock()   {}
+func (QualifiedRuleBlock) isBlock() {}
+
+var _ Block = DeclarationBlock{}
+var _ Block = QualifiedRuleBlock{}
+
+// Declaration is a property assignment, e.g. width: 2px.
+type Declaration struct {
+	Loc
+
+	// Property is the property being assigned.
+	Property string
+
+	// Values is the list of values assigned to the declaration.
+	Values []Value
+
+	// Important is whether or not the declaration was marked !important.
+	Important bool
+
+	// Comments are the comments directly before the declaration.
+	Comments []*Comment
+}
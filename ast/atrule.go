@@ -13,9 +13,11 @@ type AtRule struct {
 
 func (String) isAtPrelude()     {}
 func (Identifier) isAtPrelude() {}
+func (URL) isAtPrelude()        {}
 
 var _ AtPrelude = String{}
 var _ AtPrelude = Identifier{}
+var _ AtPrelude = URL{}
 
 // AtPrelude is the set of arguments for an at-rule.
 // The interface is only used for type discrimination.
@@ -0,0 +1,25 @@
+package ast
+
+// ImportLayer is the cascade layer of an @import, e.g. layer or layer(base).
+// See: https://www.w3.org/TR/css-cascade-5/#at-import.
+type ImportLayer struct {
+	Loc
+
+	// Name is the name of the layer, which is empty for anonymous layers.
+	Name string
+}
+
+// ImportSupports is the supports() condition of an @import, e.g.
+// supports(display: grid).
+// See: https://www.w3.org/TR/css-cascade-4/#conditional-import.
+type ImportSupports struct {
+	Loc
+
+	Condition SupportsCondition
+}
+
+func (ImportLayer) isAtPrelude()    {}
+func (ImportSupports) isAtPrelude() {}
+
+var _ AtPrelude = ImportLayer{}
+var _ AtPrelude = ImportSupports{}
@@ -0,0 +1,25 @@
+package ast
+
+// PageSelectorList is a list of page selectors used by @page rules.
+// See: https://www.w3.org/TR/css-page-3/#syntax-page-selector.
+type PageSelectorList struct {
+	Loc
+
+	Selectors []*PageSelector
+}
+
+// PageSelector selects a page by name and pseudo class, e.g. toc:first.
+type PageSelector struct {
+	Loc
+
+	// Name is the page name. It may be empty.
+	Name string
+
+	// PseudoClasses is the list of page pseudo classes, e.g. first, left,
+	// right, or blank.
+	PseudoClasses []string
+}
+
+func (PageSelectorList) isAtPrelude() {}
+
+var _ AtPrelude = PageSelectorList{}
@@ -0,0 +1,32 @@
+package ast
+
+// RawToken is a single token kept exactly as it appeared in the source.
+// Whitespace is collapsed into a single space token.
+type RawToken struct {
+	Loc
+
+	// Text is the source text of the token.
+	Text string
+}
+
+// RawPrelude is the prelude for at-rules that cssc does not understand,
+// kept as a list of tokens so that it can be passed through unchanged.
+type RawPrelude struct {
+	Loc
+
+	Tokens []RawToken
+}
+
+// RawBlock is the block for at-rules that cssc does not understand. It
+// holds the tokens between (but not including) the curly braces.
+type RawBlock struct {
+	Loc
+
+	Tokens []RawToken
+}
+
+func (RawPrelude) isAtPrelude() {}
+func (RawBlock) isBlock()       {}
+
+var _ AtPrelude = RawPrelude{}
+var _ Block = RawBlock{}
@@ -107,6 +107,13 @@ type PseudoElementSelector struct {
 	Inner *PseudoClassSelector
 }
 
+// NestingSelector is the & selector, which refers to the parent rule's
+// selectors inside of a nested style rule.
+// See: https://www.w3.org/TR/css-nesting-1/#nest-selector.
+type NestingSelector struct {
+	Loc
+}
+
 // Whitespace represents any whitespace sequence. Whitespace is
 // only kept in the AST when necessary for disambiguating syntax,
 // e.g. in selectors.
@@ -136,6 +143,7 @@ var _ SelectorPart = IDSelector{}
 var _ SelectorPart = CombinatorSelector{}
 var _ SelectorPart = PseudoClassSelector{}
 var _ SelectorPart = PseudoElementSelector{}
+var _ SelectorPart = NestingSelector{}
 var _ SelectorPart = Whitespace{}
 var _ SelectorPart = AttributeSelector{}
 
@@ -145,5 +153,6 @@ func (IDSelector) isSelector()            {}
 func (CombinatorSelector) isSelector()    {}
 func (PseudoClassSelector) isSelector()   {}
 func (PseudoElementSelector) isSelector() {}
+func (NestingSelector) isSelector()       {}
 func (Whitespace) isSelector()            {}
 func (AttributeSelector) isSelector()     {}
@@ -0,0 +1,83 @@
+package ast
+
+// SupportsCondition is a condition for @supports rules.
+// See: https://www.w3.org/TR/css-conditional-3/#at-supports.
+type SupportsCondition interface {
+	Node
+	AtPrelude
+
+	isSupportsCondition()
+}
+
+// SupportsNot negates a condition, e.g. not (display: grid).
+type SupportsNot struct {
+	Loc
+
+	Condition SupportsCondition
+}
+
+// SupportsCombination is a list of conditions joined by the
+// same operator, e.g. (a: b) and (c: d) and (e: f).
+type SupportsCombination struct {
+	Loc
+
+	// Operator is either and or or.
+	Operator string
+
+	Conditions []SupportsCondition
+}
+
+// SupportsInParens is a condition wrapped in parenthesis, e.g.
+// ((a: b) or (c: d)).
+type SupportsInParens struct {
+	Loc
+
+	Condition SupportsCondition
+}
+
+// SupportsDeclaration tests for support of a single declaration,
+// e.g. (display: grid).
+type SupportsDeclaration struct {
+	Loc
+
+	Declaration *Declaration
+}
+
+// SupportsSelector tests for support of a selector, e.g.
+// selector(a > b).
+type SupportsSelector struct {
+	Loc
+
+	Selector *Selector
+}
+
+// SupportsGeneralEnclosed is a condition that we do not understand,
+// but is still valid syntax. It always evaluates to false in browsers.
+// See: https://www.w3.org/TR/mediaqueries-4/#typedef-general-enclosed.
+type SupportsGeneralEnclosed struct {
+	Loc
+
+	// Text is the raw text of the condition, including parenthesis.
+	Text string
+}
+
+func (SupportsNot) isSupportsCondition()             {}
+func (SupportsCombination) isSupportsCondition()     {}
+func (SupportsInParens) isSupportsCondition()        {}
+func (SupportsDeclaration) isSupportsCondition()     {}
+func (SupportsSelector) isSupportsCondition()        {}
+func (SupportsGeneralEnclosed) isSupportsCondition() {}
+
+func (SupportsNot) isAtPrelude()             {}
+func (SupportsCombination) isAtPrelude()     {}
+func (SupportsInParens) isAtPrelude()        {}
+func (SupportsDeclaration) isAtPrelude()     {}
+func (SupportsSelector) isAtPrelude()        {}
+func (SupportsGeneralEnclosed) isAtPrelude() {}
+
+var _ SupportsCondition = SupportsNot{}
+var _ SupportsCondition = SupportsCombination{}
+var _ SupportsCondition = SupportsInParens{}
+var _ SupportsCondition = SupportsDeclaration{}
+var _ SupportsCondition = SupportsSelector{}
+var _ SupportsCondition = SupportsGeneralEnclosed{}
@@ -45,6 +45,15 @@ type Identifier struct {
 	Value string
 }
 
+// URL is an unquoted url, e.g. url(image.png). Quoted urls are
+// represented as a url Function with a String argument.
+type URL struct {
+	Loc
+
+	// Value is the url.
+	Value string
+}
+
 // Image is an image. Only one of the URL or Gradient fields
 // can be validly non-zero.
 // See: https://www.w3.org/TR/css-images-3/.
@@ -114,6 +123,7 @@ func (MathExpression) isValue() {}
 func (Comma) isValue()          {}
 func (Identifier) isValue()     {}
 func (HexColor) isValue()       {}
+func (URL) isValue()            {}
 
 var _ Value = String{}
 var _ Value = Dimension{}
@@ -122,3 +132,4 @@ var _ Value = MathExpression{}
 var _ Value = Comma{}
 var _ Value = Identifier{}
 var _ Value = HexColor{}
+var _ Value = URL{}
@@ -0,0 +1,189 @@
+package ast
+
+// Visitor's Visit method is called for each node visited by Walk. If the
+// returned visitor w is not nil, Walk visits each of the children of node
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, starting with a call of
+// v.Visit(node). Nodes are visited in the order they appear in the source.
+// Since nodes are pointers, a visitor may modify the nodes that it visits.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Stylesheet:
+		walkNodes(v, n.Nodes)
+
+	case *QualifiedRule:
+		walkIf(v, n.Prelude)
+		walkIf(v, n.Block)
+
+	case *AtRule:
+		for _, p := range n.Preludes {
+			Walk(v, p)
+		}
+		walkIf(v, n.Block)
+
+	case *DeclarationBlock:
+		walkNodes(v, n.Nodes())
+
+	case *QualifiedRuleBlock:
+		walkNodes(v, n.Rules)
+
+	case *RawPrelude:
+		for i := range n.Tokens {
+			Walk(v, &n.Tokens[i])
+		}
+
+	case *RawBlock:
+		for i := range n.Tokens {
+			Walk(v, &n.Tokens[i])
+		}
+
+	case *Declaration:
+		for _, c := range n.Comments {
+			Walk(v, c)
+		}
+		walkValues(v, n.Values)
+
+	// Selectors.
+	case *SelectorList:
+		for _, s := range n.Selectors {
+			Walk(v, s)
+		}
+
+	case *Selector:
+		for _, p := range n.Parts {
+			Walk(v, p)
+		}
+
+	case *PseudoClassSelector:
+		walkIf(v, n.Arguments)
+
+	case *PseudoElementSelector:
+		if n.Inner != nil {
+			Walk(v, n.Inner)
+		}
+
+	case *AttributeSelector:
+		walkIf(v, n.Value)
+
+	case *KeyframeSelectorList:
+		for _, s := range n.Selectors {
+			Walk(v, s)
+		}
+
+	case *PageSelectorList:
+		for _, s := range n.Selectors {
+			Walk(v, s)
+		}
+
+	// Media queries.
+	case *MediaQueryList:
+		for _, q := range n.Queries {
+			Walk(v, q)
+		}
+
+	case *MediaQuery:
+		walkMediaQueryParts(v, n.Parts)
+
+	case *MediaInParens:
+		walkMediaQueryParts(v, n.Parts)
+
+	case *MediaFeaturePlain:
+		if n.Property != nil {
+			Walk(v, n.Property)
+		}
+		walkIf(v, n.Value)
+
+	case *MediaFeatureRange:
+		walkIf(v, n.LeftValue)
+		if n.Property != nil {
+			Walk(v, n.Property)
+		}
+		walkIf(v, n.RightValue)
+
+	// Supports conditions.
+	case *ImportSupports:
+		walkIf(v, n.Condition)
+
+	case *SupportsNot:
+		walkIf(v, n.Condition)
+
+	case *SupportsCombination:
+		for _, c := range n.Conditions {
+			Walk(v, c)
+		}
+
+	case *SupportsInParens:
+		walkIf(v, n.Condition)
+
+	case *SupportsDeclaration:
+		if n.Declaration != nil {
+			Walk(v, n.Declaration)
+		}
+
+	case *SupportsSelector:
+		if n.Selector != nil {
+			Walk(v, n.Selector)
+		}
+
+	// Values.
+	case *Function:
+		walkValues(v, n.Arguments)
+
+	case *MathExpression:
+		walkIf(v, n.Left)
+		walkIf(v, n.Right)
+	}
+
+	v.Visit(nil)
+}
+
+// walkIf walks node if it is not nil.
+func walkIf(v Visitor, node Node) {
+	if node != nil {
+		Walk(v, node)
+	}
+}
+
+func walkNodes(v Visitor, nodes []Node) {
+	for _, n := range nodes {
+		Walk(v, n)
+	}
+}
+
+func walkValues(v Visitor, values []Value) {
+	for _, n := range values {
+		Walk(v, n)
+	}
+}
+
+func walkMediaQueryParts(v Visitor, parts []MediaQueryPart) {
+	for _, p := range parts {
+		Walk(v, p)
+	}
+}
+
+// inspector is a Visitor that calls a function for each node.
+type inspector func(Node) bool
+
+// Visit implements Visitor.
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order, starting with a call of
+// f(node). If f returns true, Inspect calls f for each of the children of
+// node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
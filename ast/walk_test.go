@@ -0,0 +1,119 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Parse(t testing.TB, s string) *ast.Stylesheet {
+	ss, diagnostics := cssc.Parse(s, "main.css")
+	require.Empty(t, diagnostics)
+	return ss
+}
+
+func TestInspect(t *testing.T) {
+	ss := Parse(t, `@import "a.css" supports(display: grid) screen;
+.a, .b > :not(.c) { width: calc(1px + 2px); }
+@media (min-width: 100px) and (200px < height) {
+	.d { color: red; }
+}`)
+
+	var classes, dimensions, features []string
+	ast.Inspect(ss, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ClassSelector:
+			classes = append(classes, n.Name)
+		case *ast.Dimension:
+			dimensions = append(dimensions, n.Value+n.Unit)
+		case *ast.Identifier:
+			features = append(features, n.Value)
+		}
+		return true
+	})
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, classes)
+	assert.Equal(t, []string{"1px", "2px", "100px", "200px"}, dimensions)
+	assert.Equal(t, []string{"grid", "screen", "min-width", "and", "height", "red"}, features)
+}
+
+func TestInspect_Prune(t *testing.T) {
+	ss := Parse(t, `.a { color: red; } @media screen { .b { color: blue; } }`)
+
+	var classes []string
+	ast.Inspect(ss, func(n ast.Node) bool {
+		if _, ok := n.(*ast.AtRule); ok {
+			return false
+		}
+
+		if c, ok := n.(*ast.ClassSelector); ok {
+			classes = append(classes, c.Name)
+		}
+		return true
+	})
+
+	assert.Equal(t, []string{"a"}, classes)
+}
+
+type depthVisitor struct {
+	depth    int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		return nil
+	}
+
+	if v.depth > *v.maxDepth {
+		*v.maxDepth = v.depth
+	}
+	return depthVisitor{v.depth + 1, v.maxDepth}
+}
+
+func TestWalk(t *testing.T) {
+	ss := Parse(t, `.a { color: red; }`)
+
+	var maxDepth int
+	ast.Walk(depthVisitor{maxDepth: &maxDepth}, ss)
+
+	// Stylesheet > QualifiedRule > DeclarationBlock > Declaration > Identifier.
+	assert.Equal(t, 4, maxDepth)
+}
+
+func TestWalk_Rewrite(t *testing.T) {
+	ss := Parse(t, `.a, .b:not(.a) { color: red; }`)
+
+	ast.Inspect(ss, func(n ast.Node) bool {
+		if c, ok := n.(*ast.ClassSelector); ok && c.Name == "a" {
+			c.Name = "prefix-a"
+		}
+		return true
+	})
+
+	out, err := cssc.Print(ss, cssc.PrintOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, ".prefix-a,.b:not(.prefix-a){color:red}", out)
+}
+
+func TestInspect_SourceOrder(t *testing.T) {
+	ss := Parse(t, `.a { /* first */ .b { color: red; } width: /* second */ 1px; & .c {} }`)
+
+	var order []string
+	ast.Inspect(ss, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ClassSelector:
+			order = append(order, "."+n.Name)
+		case *ast.Declaration:
+			order = append(order, n.Property)
+		case *ast.Comment:
+			order = append(order, n.Text)
+		}
+		return true
+	})
+
+	assert.Equal(t, []string{".a", " first ", ".b", "color", "width", " second ", ".c"}, order)
+}
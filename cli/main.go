@@ -1,78 +1,270 @@
+// Command cssc compiles css files from the command line.
+//
+// Usage:
+//
+//	cssc [flags] entry.css...
+//
+// By default, output is written to stdout. Use -outdir to write each output
+// file into a directory instead, or -bundle with -outfile to write a single entry
+// and its imports to one file. cssc exits with a non-zero status if any
+// errors were reported during compilation.
+//
+// With -check, cssc acts as a formatter check instead: it lists each entry
+// file whose contents differ from its pretty printed form, and exits with a
+// non-zero status if there are any.
 package main
 
 import (
-	"log"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/internal/parser"
 	"github.com/stephen/cssc/internal/printer"
 	"github.com/stephen/cssc/internal/sources"
+	"github.com/stephen/cssc/transforms"
 )
 
 func main() {
-	source := &sources.Source{
-		Content: `@import "test.css";
-@import url("./testing.css");
-	@import url(tester.css);
-	/* some notes about the next line
-	are here */
-
-	.class {
-		width: 2rem;
-		margin: 2em 1px;
-		height: 20%;
-		padding: 0;
-		color: rgb(255, 255, calc(2 + 2));
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Exit codes for run.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// run runs the cli with the given arguments and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	var opts cssc.Options
+
+	flags := flag.NewFlagSet("cssc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cssc [flags] entry.css...")
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&opts.Outdir, "outdir", "", "directory to write output files to. If not set, output is written to stdout")
+	flags.StringVar(&opts.Outfile, "outfile", "", "file to write the output to. Requires -bundle and a single entry")
+	flags.BoolVar(&opts.Bundle, "bundle", false, "bundle each entry and its imports into a single output file")
+	flags.BoolVar(&opts.Minify, "minify", false, "minify the output, keeping only /*! comments")
+	flags.BoolVar(&opts.Pretty, "pretty", false, "pretty print the output with one rule and declaration per line")
+	flags.IntVar(&opts.Indent, "indent", 2, "number of spaces to indent by with -pretty or -check")
+	check := flags.Bool("check", false, "list entry files that are not pretty printed instead of compiling them")
+	flags.StringVar(&opts.SourceRoot, "source-root", "", "sourceRoot to set in source maps")
+	flags.BoolVar(&opts.ExcludeSourcesContent, "exclude-sources-content", false, "leave the content of sources out of source maps")
+
+	flags.Var(&enumFlag{current: "inline", choices: map[string]func(){
+		"inline":   func() { opts.SourceMap = cssc.SourceMapInline },
+		"external": func() { opts.SourceMap = cssc.SourceMapExternal },
+		"none":     func() { opts.SourceMap = cssc.SourceMapNone },
+	}}, "sourcemap", "how to write source maps: inline, external (requires -outdir or -outfile), or none")
+
+	flags.Var(&enumFlag{current: "passthrough", choices: map[string]func(){
+		"passthrough": func() { opts.Transforms.ImportRules = transforms.ImportRulesPassthrough },
+		"inline":      func() { opts.Transforms.ImportRules = transforms.ImportRulesInline },
+	}}, "import-rules", "transform for @import rules: passthrough or inline")
+
+	flags.Var(&enumFlag{current: "passthrough", choices: map[string]func(){
+		"passthrough": func() { opts.Transforms.MediaFeatureRanges = transforms.MediaFeatureRangesPassthrough },
+		"transform":   func() { opts.Transforms.MediaFeatureRanges = transforms.MediaFeatureRangesTransform },
+	}}, "media-feature-ranges", "transform for media feature ranges: passthrough or transform")
+
+	flags.Var(&enumFlag{current: "passthrough", choices: map[string]func(){
+		"passthrough": func() { opts.Transforms.AnyLink = transforms.AnyLinkPassthrough },
+		"transform":   func() { opts.Transforms.AnyLink = transforms.AnyLinkTransform },
+	}}, "any-link", "transform for :any-link selectors: passthrough or transform")
+
+	flags.Var(&enumFlag{current: "passthrough", choices: map[string]func(){
+		"passthrough": func() { opts.Transforms.CustomProperties = transforms.CustomPropertiesPassthrough },
+		"root":        func() { opts.Transforms.CustomProperties = transforms.CustomPropertiesTransformRoot },
+		"scoped":      func() { opts.Transforms.CustomProperties = transforms.CustomPropertiesTransformScoped },
+		"preserve":    func() { opts.Transforms.CustomProperties = transforms.CustomPropertiesTransformRootPreserve },
+	}}, "custom-properties", "transform for custom properties: passthrough, root, scoped or preserve")
+
+	flags.Var(&enumFlag{current: "passthrough", choices: map[string]func(){
+		"passthrough": func() { opts.Transforms.CustomMediaQueries = transforms.CustomMediaQueriesPassthrough },
+		"transform":   func() { opts.Transforms.CustomMediaQueries = transforms.CustomMediaQueriesTransform },
+	}}, "custom-media-queries", "transform for @custom-media: passthrough or transform")
+
+	flags.Var(&enumFlag{current: "passthrough", choices: map[string]func(){
+		"passthrough": func() { opts.Transforms.CalcReduction = transforms.CalcReductionPassthrough },
+		"reduce":      func() { opts.Transforms.CalcReduction = transforms.CalcReductionReduce },
+	}}, "calc-reduction", "transform for math functions: passthrough or reduce")
+
+	flags.Var(&enumFlag{current: "passthrough", choices: map[string]func(){
+		"passthrough": func() { opts.Transforms.Nesting = transforms.NestingPassthrough },
+		"transform":   func() { opts.Transforms.Nesting = transforms.NestingTransform },
+	}}, "nesting", "transform for nested style rules: passthrough or transform")
+
+	flags.Var(&enumFlag{current: "passthrough", choices: map[string]func(){
+		"passthrough": func() { opts.Transforms.Prefixes = transforms.PrefixesPassthrough },
+		"add":         func() { opts.Transforms.Prefixes = transforms.PrefixesAdd },
+		"remove":      func() { opts.Transforms.Prefixes = transforms.PrefixesRemove },
+		"add-remove":  func() { opts.Transforms.Prefixes = transforms.PrefixesAddAndRemove },
+	}}, "prefixes", "transform for vendor prefixes: passthrough, add, remove or add-remove (uses -targets)")
+	flags.BoolVar(&opts.Transforms.WarnRemovedPrefixes, "warn-removed-prefixes", false, "report a warning for each vendor prefix removed by -prefixes")
+
+	targets := flags.String("targets", "", "browserslist queries for the browsers to support, e.g. \"chrome >= 90, safari >= 14\". Turns on the transforms they need")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *targets != "" {
+		t, err := transforms.ParseTargets(*targets)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		opts.Transforms.Targets = t
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
 	}
 
-	section {
-		float: left;
-		margin: 1em; border: solid 1px;
-		width: calc(100%/3 - 2*1em - 2*1px);
+	if opts.SourceMap == cssc.SourceMapExternal && opts.Outdir == "" && opts.Outfile == "" {
+		fmt.Fprintln(stderr, "-sourcemap external requires -outdir or -outfile")
+		return exitUsage
 	}
 
-	/*
-		here we are:
-	*/
-	section .child {}
-	section.self {}
+	reporter := &countingReporter{Writer: stderr}
+	opts.Entry = flags.Args()
+	opts.Reporter = reporter
+
+	if *check {
+		return runCheck(opts, stdout, reporter)
+	}
 
-	[test="hello"] {}
-	[test=hello] {}
-	[test*=hello] {}
-	[test^=2.5] {}
-	[test] {}
+	result := cssc.Compile(opts)
 
-	@media (width: 600px), (200px < width < 600px), (200px < width), (width < 600px) {
-		.c {height: 100%;}
+	paths := make([]string, 0, len(result.Files))
+	for path := range result.Files {
+		paths = append(paths, path)
 	}
+	sort.Strings(paths)
 
-	@media not screen {
-		.c {height: 100%;}
+	if opts.Outdir == "" && opts.Outfile == "" {
+		for _, path := range paths {
+			io.WriteString(stdout, result.Files[path])
+		}
+	} else {
+		for _, path := range paths {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				reporter.AddError(err)
+				continue
+			}
+
+			if err := ioutil.WriteFile(path, []byte(result.Files[path]), 0644); err != nil {
+				reporter.AddError(err)
+			}
+		}
 	}
 
-	@media screen and (color), projection and (color) {
-		.c {height: 100%;}
+	if reporter.errors > 0 {
+		return exitError
 	}
 
-	@media not (width <= -100px) {
-		body { background: green; }
+	return exitOK
+}
+
+// runCheck lists each entry file that is not already pretty printed. Transforms
+// are not run, so that the check only looks at formatting.
+func runCheck(opts cssc.Options, stdout io.Writer, reporter *countingReporter) int {
+	unformatted := 0
+	for _, path := range opts.Entry {
+		in, err := ioutil.ReadFile(path)
+		if err != nil {
+			reporter.AddError(err)
+			continue
+		}
+
+		errors := reporter.errors
+		ss := parser.ParseWithReporter(&sources.Source{
+			Path:    path,
+			Content: string(in),
+		}, reporter)
+		if reporter.errors > errors {
+			// Don't compare files that could only be partially parsed.
+			continue
+		}
+
+		out, err := printer.Print(ss, printer.Options{
+			Pretty: true,
+			Indent: opts.Indent,
+		})
+		if err != nil {
+			reporter.AddError(err)
+			continue
+		}
+
+		if out != string(in) {
+			fmt.Fprintln(stdout, path)
+			unformatted++
+		}
 	}
-	@media (min-width: 30em) and (orientation: landscape) {
-		body { background: green; }
+
+	if reporter.errors > 0 || unformatted > 0 {
+		return exitError
 	}
 
-	@import url('landscape.css') screen and (orientation: landscape);
-	`,
+	return exitOK
+}
+
+// countingReporter writes errors and warnings to a writer, keeping track
+// of how many errors (but not warnings) were reported.
+type countingReporter struct {
+	io.Writer
+
+	errors int
+}
+
+// AddError implements cssc.Reporter.
+func (r *countingReporter) AddError(err error) {
+	if !logging.IsWarning(err) {
+		r.errors++
 	}
 
-	sheet, err := parser.Parse(source)
-	if err != nil {
-		panic(err)
+	fmt.Fprintln(r, err.Error())
+}
+
+// enumFlag is a flag.Value that accepts one of a fixed set of choices,
+// running the matching setter when parsed.
+type enumFlag struct {
+	current string
+	choices map[string]func()
+}
+
+// String implements flag.Value.
+func (e *enumFlag) String() string {
+	return e.current
+}
+
+// Set implements flag.Value.
+func (e *enumFlag) Set(value string) error {
+	set, ok := e.choices[value]
+	if !ok {
+		names := make([]string, 0, len(e.choices))
+		for name := range e.choices {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("must be one of: %s", strings.Join(names, ", "))
 	}
 
-	log.Println(spew.Sdump(sheet))
-	log.Println(printer.Print(sheet, printer.Options{
-		OriginalSource: source,
-	}))
+	set()
+	e.current = value
+	return nil
 }
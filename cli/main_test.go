@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_Stdout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"../testdata/simple/index.css"}, &stdout, &stderr)
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "div{background-color:green}")
+	assert.Empty(t, stderr.String())
+}
+
+func TestRun_Transforms(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-import-rules", "inline", "../testdata/imports/index.css"}, &stdout, &stderr)
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), ".another{margin:0 auto}")
+	assert.NotContains(t, stdout.String(), "@import")
+}
+
+func TestRun_Outdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cssc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-outdir", dir, "../testdata/imports/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Empty(t, stdout.String())
+
+	for _, name := range []string{"index.css", "other.css", "another.css"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitError, run([]string{"../testdata/nonexistent/index.css"}, &stdout, &stderr))
+	assert.NotEmpty(t, stderr.String())
+
+	assert.Equal(t, exitUsage, run(nil, &stdout, &stderr))
+	assert.Equal(t, exitUsage, run([]string{"-any-link", "sometimes", "index.css"}, &stdout, &stderr))
+}
+
+func TestRun_Check(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-check", "../testdata/simple/index.css", "../testdata/imports/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Empty(t, stdout.String())
+
+	dir, err := ioutil.TempDir("", "cssc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	unformatted := filepath.Join(dir, "index.css")
+	require.NoError(t, ioutil.WriteFile(unformatted, []byte(".a{color:red}"), 0644))
+
+	stdout.Reset()
+	code = run([]string{"-check", "../testdata/simple/index.css", unformatted}, &stdout, &stderr)
+	assert.Equal(t, exitError, code)
+	assert.Equal(t, unformatted+"\n", stdout.String())
+}
+
+func TestRun_Pretty(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-pretty", "-indent", "4", "../testdata/simple/index.css"}, &stdout, &stderr)
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "div {\n    background-color: green;\n}\n\nbody {")
+}
+
+func TestRun_Bundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cssc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "bundle.css")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-bundle", "-outfile", out, "../testdata/bundle/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Empty(t, stdout.String())
+
+	content, err := ioutil.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), ".a{color:green}.c{color:white}.b{color:blue}.index{color:red}")
+}
+
+func TestRun_SourceMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cssc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-sourcemap", "external", "-outdir", dir, "../testdata/simple/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+
+	_, err = os.Stat(filepath.Join(dir, "index.css.map"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, exitUsage, run([]string{"-sourcemap", "external", "../testdata/simple/index.css"}, &stdout, &stderr))
+}
+
+func TestRun_Targets(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-sourcemap", "none", "-targets", "safari >= 8", "../testdata/targets/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "a:visited,a:link{color:red}.card .title{color:blue}@media (min-width:600px){.wide{display:block}}", stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"-sourcemap", "none", "-targets", "chrome >= 120, firefox >= 117", "../testdata/targets/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "a:any-link{color:red}.card{& .title{color:blue}}@media (width>=600px){.wide{display:block}}", stdout.String())
+	assert.Empty(t, stderr.String())
+
+	code = run([]string{"-targets", "> 0.5%", "../testdata/targets/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr.String(), "unsupported browserslist query")
+}
+
+func TestRun_Prefixes(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-sourcemap", "none", "-prefixes", "add", "-targets", "safari >= 15, firefox >= 60", "../testdata/prefixes/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, ".button{-webkit-user-select:none;-moz-user-select:none;user-select:none;display:flex}::-moz-selection{color:red}::selection{color:red}", stdout.String())
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	code = run([]string{"-sourcemap", "none", "-targets", "safari >= 15, firefox >= 60", "../testdata/prefixes/index.css"}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, ".button{user-select:none;display:flex}::selection{color:red}", stdout.String())
+}
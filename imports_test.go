@@ -1,11 +1,15 @@
 package cssc_test
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/ast"
 	"github.com/stephen/cssc/transforms"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestImports(t *testing.T) {
@@ -41,3 +45,196 @@ func TestImports(t *testing.T) {
 		assert.Len(t, errors, 0)
 	})
 }
+
+func TestImports_Bundle(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{
+			"testdata/bundle/index.css",
+		},
+		Reporter: &errors,
+		Bundle:   true,
+		Outdir:   "dist",
+	})
+
+	assert.Len(t, errors, 0)
+	require.Len(t, result.Files, 1)
+
+	out, ok := result.Files[filepath.Join("dist", "index.css")]
+	require.True(t, ok)
+
+	// c.css is imported twice, so it is only kept at its last import.
+	assert.True(t, strings.HasPrefix(out, ".a{color:green}.c{color:white}.b{color:blue}.index{color:red}"), out)
+}
+
+func TestImports_Outfile(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{
+			"testdata/bundle/index.css",
+		},
+		Reporter: &errors,
+		Bundle:   true,
+		Outfile:  "out.css",
+	})
+
+	assert.Len(t, errors, 0)
+	assert.Contains(t, result.Files, "out.css")
+
+	errors = nil
+	result = cssc.Compile(cssc.Options{
+		Entry: []string{
+			"testdata/bundle/index.css",
+			"testdata/simple/index.css",
+		},
+		Reporter: &errors,
+		Bundle:   true,
+		Outfile:  "out.css",
+	})
+
+	assert.Len(t, errors, 1)
+	assert.Len(t, result.Files, 0)
+}
+
+func TestImports_Outdir(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{
+			"testdata/imports/index.css",
+		},
+		Reporter: &errors,
+		Outdir:   "dist",
+	})
+
+	assert.Len(t, errors, 0)
+	for _, name := range []string{"index.css", "other.css", "another.css"} {
+		assert.Contains(t, result.Files, filepath.Join("dist", name))
+	}
+}
+
+func TestImports_Conditions(t *testing.T) {
+	expected := "@media print and (max-width:100px){.narrow{color:red}}@media print{.print{color:black}}" +
+		"@media screen{@supports (display:grid){.grid{display:grid}}}@layer base{.layered{color:blue}}.index{color:white}"
+
+	for name, opts := range map[string]cssc.Options{
+		"inline": {Transforms: transforms.Options{ImportRules: transforms.ImportRulesInline}},
+		"bundle": {Bundle: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var errors TestReporter
+			opts.Entry = []string{"testdata/conditionalimports/index.css"}
+			opts.Reporter = &errors
+			result := cssc.Compile(opts)
+
+			assert.Len(t, errors, 0)
+			require.Len(t, result.Files, 1)
+			for _, out := range result.Files {
+				assert.True(t, strings.HasPrefix(out, expected), out)
+			}
+		})
+	}
+}
+
+func TestImports_Cycle(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts     cssc.Options
+		expected map[string]string
+	}{
+		"inline": {
+			opts:     cssc.Options{Transforms: transforms.Options{ImportRules: transforms.ImportRulesInline}},
+			expected: map[string]string{"a.css": ".c{color:green}.b{color:blue}.a{color:red}"},
+		},
+		"passthrough": {
+			opts: cssc.Options{Transforms: transforms.Options{ImportRules: transforms.ImportRulesPassthrough}},
+			expected: map[string]string{
+				"a.css": `@import "./b.css";.a{color:red}`,
+				"b.css": `@import "./c.css";.b{color:blue}`,
+				"c.css": ".c{color:green}",
+			},
+		},
+		"bundle": {
+			opts:     cssc.Options{Bundle: true},
+			expected: map[string]string{"a.css": ".c{color:green}.b{color:blue}.a{color:red}"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var errors TestReporter
+			opts := tc.opts
+			opts.Entry = []string{"testdata/cycle/a.css"}
+			opts.Reporter = &errors
+			opts.SourceMap = cssc.SourceMapNone
+			result := cssc.Compile(opts)
+
+			require.Len(t, errors, 1)
+			assert.Contains(t, errors[0].Error(), "circular import: "+filepath.Join("testdata", "cycle", "a.css")+" -> "+
+				filepath.Join("testdata", "cycle", "b.css")+" -> "+filepath.Join("testdata", "cycle", "a.css"))
+
+			// The @import that closes the cycle is removed from the output.
+			require.Len(t, result.Files, len(tc.expected))
+			for path, out := range result.Files {
+				assert.Equal(t, tc.expected[filepath.Base(path)], out, path)
+			}
+		})
+	}
+
+	t.Run("self", func(t *testing.T) {
+		var errors TestReporter
+		result := cssc.Compile(cssc.Options{
+			Entry:    []string{"testdata/cycle/self.css"},
+			Reporter: &errors,
+			Bundle:   true,
+		})
+
+		require.Len(t, errors, 1)
+		assert.Contains(t, errors[0].Error(), "circular import")
+		require.Len(t, result.Files, 1)
+		for _, out := range result.Files {
+			assert.Contains(t, out, ".self{color:white}")
+		}
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		// Both files are entries, so either import can be the one that closes the cycle.
+		var errors TestReporter
+		result := cssc.Compile(cssc.Options{
+			Entry:    []string{"testdata/cycle/a.css", "testdata/cycle/b.css"},
+			Reporter: &errors,
+			Bundle:   true,
+		})
+
+		require.Len(t, errors, 1)
+		assert.Contains(t, errors[0].Error(), "circular import")
+		assert.Len(t, result.Files, 2)
+	})
+}
+
+func TestImports_Plugins(t *testing.T) {
+	prefix := transforms.Plugin{
+		Name: "prefix",
+		Selector: func(sel *ast.Selector, ctx transforms.PluginContext) []*ast.Selector {
+			for _, p := range sel.Parts {
+				if c, ok := p.(*ast.ClassSelector); ok {
+					c.Name = "x-" + c.Name
+				}
+			}
+			return []*ast.Selector{sel}
+		},
+	}
+
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/bundle/index.css"},
+		Reporter: &errors,
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+			Plugins:     []transforms.Plugin{prefix},
+		},
+	})
+
+	assert.Len(t, errors, 0)
+	require.Len(t, result.Files, 1)
+	for _, out := range result.Files {
+		// Inlined rules are only transformed once, with their own file.
+		assert.True(t, strings.HasPrefix(out, ".x-c{color:white}.x-a{color:green}.x-c{color:white}.x-b{color:blue}.x-index{color:red}"), out)
+	}
+}
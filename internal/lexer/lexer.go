@@ -6,7 +6,7 @@ import (
 	"unicode"
 	"unicode/utf8"
 
-	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/ast"
 	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/internal/sources"
 )
@@ -51,12 +51,31 @@ type Lexer struct {
 	// it will keep whitespace tokens around. This is useful for parsing
 	// some CSS that must be space disambiguated.
 	RetainWhitespace bool
+
+	// SkipComments is settable by the caller of the lexer. When set, comments
+	// are skipped instead of being returned as Comment tokens, e.g. inside of
+	// math functions, where they can't be kept in place.
+	SkipComments bool
+
+	// reporter receives errors in the source text, e.g. unclosed strings. If
+	// it is nil, these errors panic instead.
+	reporter logging.Reporter
 }
 
-// NewLexer creates a new lexer for the source.
+// NewLexer creates a new lexer for the source. Errors in the source
+// text panic, like errors raised with Errorf.
 func NewLexer(source *sources.Source) *Lexer {
+	return NewLexerWithReporter(source, nil)
+}
+
+// NewLexerWithReporter creates a new lexer for the source that reports errors
+// in the source text to reporter and keeps lexing, following the error handling
+// in https://www.w3.org/TR/css-syntax-3/#tokenization. Invalid strings and urls
+// become BadString and BadURL tokens.
+func NewLexerWithReporter(source *sources.Source, reporter logging.Reporter) *Lexer {
 	l := &Lexer{
-		source: source,
+		source:   source,
+		reporter: reporter,
 	}
 	l.step()
 	l.Next()
@@ -103,7 +122,7 @@ func (l *Lexer) peek(i int) rune {
 // Location is the start offset of the current token in the source, i.e.
 // the value of l.pos when Next() was called.
 func (l *Lexer) Location() ast.Loc {
-	return ast.Loc{Position: l.start}
+	return ast.Loc{Position: l.start, Source: l.source.Index}
 }
 
 // Range is the start to end offset of the current token in the source. The returned
@@ -242,7 +261,9 @@ func (l *Lexer) Next() {
 
 		case '\\':
 			if !startsEscape(l.ch, l.peek(0)) {
-				l.Errorf("parse error")
+				l.nextDelimToken()
+				l.sourceErrorf("parse error")
+				return
 			}
 
 			l.nextIdentLikeToken()
@@ -269,11 +290,16 @@ func (l *Lexer) Next() {
 					}
 					l.step()
 				case -1:
-					l.Errorf("unexpected EOF")
+					l.sourceErrorf("unexpected EOF")
+					end = l.lastPos
+					break commentToken
 				default:
 					l.step()
 				}
 			}
+			if l.SkipComments {
+				continue
+			}
 			l.Current = Comment
 			l.CurrentString = l.source.Content[start:end]
 
@@ -291,7 +317,10 @@ func (l *Lexer) Next() {
 					l.step()
 					break stringToken
 				case '\n':
-					l.Errorf("unclosed string: unexpected newline")
+					l.sourceErrorf("unclosed string: unexpected newline")
+					l.Current = BadString
+					l.CurrentString = l.source.Content[start:l.lastPos]
+					return
 				case '\\':
 					l.step()
 
@@ -299,14 +328,18 @@ func (l *Lexer) Next() {
 					case '\n':
 						l.step()
 					case -1:
-						l.Errorf("unexpected EOF")
+						l.sourceErrorf("unexpected EOF")
+						end = l.lastPos
+						break stringToken
 					default:
 						if startsEscape(l.ch, l.peek(0)) {
 							l.nextEscaped()
 						}
 					}
 				case -1:
-					l.Errorf("unexpected EOF")
+					l.sourceErrorf("unexpected EOF")
+					end = l.lastPos
+					break stringToken
 				default:
 					l.step()
 				}
@@ -423,8 +456,6 @@ func isURLString(in string) bool {
 }
 
 // nextIdentLikeToken implements https://www.w3.org/TR/css-syntax-3/#consume-an-ident-like-token.
-// The spec tells us to return a bad-url-token, but we
-// are uninterested in best-effort interpretation for compilation.
 func (l *Lexer) nextIdentLikeToken() {
 	start := l.lastPos
 	l.nextName()
@@ -451,16 +482,22 @@ func (l *Lexer) nextIdentLikeToken() {
 				l.step()
 				return
 			case -1:
-				l.Errorf("unexpected EOF")
+				l.sourceErrorf("unexpected EOF")
+				l.CurrentString = l.source.Content[urlStart:l.lastPos]
+				return
 			case '"', '\'', '(':
-				l.Errorf("unexpected token: %c", l.ch)
+				l.sourceErrorf("unexpected token: %c", l.ch)
+				l.nextBadURLRemnants()
+				return
 			case '\\':
 				if startsEscape(l.ch, l.peek(0)) {
 					l.nextEscaped()
 					continue
 				}
 
-				l.Errorf("unexpected token: %c", l.ch)
+				l.sourceErrorf("unexpected token: %c", l.ch)
+				l.nextBadURLRemnants()
+				return
 			default:
 				if isWhitespace(l.ch) {
 					l.step()
@@ -468,7 +505,9 @@ func (l *Lexer) nextIdentLikeToken() {
 				}
 
 				if isNonPrintable(l.ch) {
-					l.Errorf("unexpected token: %c", l.ch)
+					l.sourceErrorf("unexpected token: %c", l.ch)
+					l.nextBadURLRemnants()
+					return
 				}
 
 				l.step()
@@ -487,6 +526,29 @@ func (l *Lexer) nextIdentLikeToken() {
 	l.Current = Ident
 }
 
+// nextBadURLRemnants implements https://www.w3.org/TR/css-syntax-3/#consume-remnants-of-bad-url
+// and sets the lexer state to a BadURL token.
+func (l *Lexer) nextBadURLRemnants() {
+	l.Current = BadURL
+	for {
+		switch l.ch {
+		case ')':
+			l.step()
+			return
+		case -1:
+			return
+		case '\\':
+			if startsEscape(l.ch, l.peek(0)) {
+				l.nextEscaped()
+				continue
+			}
+			l.step()
+		default:
+			l.step()
+		}
+	}
+}
+
 // nextNumber implements https://www.w3.org/TR/css-syntax-3/#consume-a-number
 // and consumes a number. We don't distinguish between number and integer because
 // it doesn't matter for us.
@@ -568,6 +630,17 @@ func (l *Lexer) Errorf(f string, args ...interface{}) {
 	l.LocationErrorf(l.start, l.lastPos, f, args...)
 }
 
+// sourceErrorf is for errors in the source text found while lexing a token. If
+// the lexer has a reporter, the error is reported and the caller should recover.
+// Otherwise, it panics like Errorf.
+func (l *Lexer) sourceErrorf(f string, args ...interface{}) {
+	if l.reporter == nil {
+		l.Errorf(f, args...)
+	}
+
+	l.reporter.AddError(logging.LocationErrorf(l.source, l.start, l.lastPos, f, args...))
+}
+
 // Error is an error that the lexer ran into.
 type Error struct {
 	inner error
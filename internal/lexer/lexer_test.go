@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stephen/cssc/internal/lexer"
+	"github.com/stephen/cssc/internal/sources"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -126,3 +127,22 @@ func TestLexer_Errorf(t *testing.T) {
 }`).RunUntil(lexer.EOF)
 	})
 }
+
+type reporter []error
+
+func (r *reporter) AddError(err error) {
+	*r = append(*r, err)
+}
+
+func TestLexer_Reporter(t *testing.T) {
+	var errs reporter
+	h := &Harness{t, lexer.NewLexerWithReporter(&sources.Source{Path: "main.css", Content: `"no good;
+url(a"b) ok`}, &errs)}
+
+	h.ExpectAndNext(lexer.BadString, "no good;", "")
+	h.ExpectAndNext(lexer.BadURL, "url", "")
+	h.ExpectAndNext(lexer.Ident, "ok", "")
+	h.ExpectAndNext(lexer.EOF, "", "")
+
+	assert.Len(t, errs, 2)
+}
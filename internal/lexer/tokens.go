@@ -36,6 +36,8 @@ const (
 	String        // String literal
 	Ident         // Identifier
 	Delim         // Delimiter (used for preserving tokens for subprocessors)
+	BadString     // Unclosed string literal
+	BadURL        // Invalid url(...)
 )
 
 func (t Token) String() string {
@@ -59,6 +61,8 @@ var tokens = [...]string{
 	String:     "STRING",
 	Ident:      "IDENT",
 	URL:        "URL",
+	BadString:  "BAD_STRING",
+	BadURL:     "BAD_URL",
 
 	Comma:         ",",
 	Colon:         ":",
@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -38,6 +39,17 @@ func LocationWarnf(source *sources.Source, start, end int, f string, args ...int
 	return &locationError{fmt.Errorf(f, args...), true, source, start, end - start}
 }
 
+// IsWarning returns whether or not err was reported as a warning
+// rather than an error.
+func IsWarning(err error) bool {
+	var l *locationError
+	if !errors.As(err, &l) {
+		return false
+	}
+
+	return l.warning
+}
+
 // locationError is an error that happened at a specific location
 // in the source.
 type locationError struct {
@@ -55,30 +67,77 @@ func (l *locationError) Unwrap() error {
 	return l.inner
 }
 
-// Error implements error. It's relatively slow because it needs to
-// rescan the source to figure out line and column numbers. The output
-// looks like:
-// file.css:1:1
-// there's a problem here:
-//   contents
-//   ~~~~~~~~
-func (l *locationError) Error() string {
-	lineNumber, lineStart := 1, 0
+// Location is the location and message of an error reported at a
+// specific location in a source.
+type Location struct {
+	// Path is the path of the source.
+	Path string
+
+	// Line is the 1-indexed line of the start of the location, and Column
+	// is its 0-indexed byte offset in the line.
+	Line, Column int
+
+	// Length is the length of the location in bytes.
+	Length int
+
+	// Message is the error message, without the location.
+	Message string
+
+	// Warning is set if the error was reported as a warning.
+	Warning bool
+}
+
+// Locate returns the location of err if it was reported at a specific
+// location in a source.
+func Locate(err error) (Location, bool) {
+	var l *locationError
+	if !errors.As(err, &l) {
+		return Location{}, false
+	}
+
+	line, lineStart, _ := l.line()
+	return Location{
+		Path:    l.Source.Path,
+		Line:    line,
+		Column:  l.start - lineStart,
+		Length:  l.length,
+		Message: l.inner.Error(),
+		Warning: l.warning,
+	}, true
+}
+
+// line returns the line number of the error, along with the offsets of
+// the start and end of that line.
+func (l *locationError) line() (number, start, end int) {
+	number, start = 1, 0
 	for i, ch := range l.Source.Content[:l.start] {
 		if ch == '\n' {
-			lineNumber++
-			lineStart = i + 1
+			number++
+			start = i + 1
 		}
 	}
 
-	lineEnd := len(l.Source.Content)
+	end = len(l.Source.Content)
 	for i, ch := range l.Source.Content[l.start:] {
 		if ch == '\n' {
-			lineEnd = i + l.start
+			end = i + l.start
 			break
 		}
 	}
 
+	return number, start, end
+}
+
+// Error implements error. It's relatively slow because it needs to
+// rescan the source to figure out line and column numbers. The output
+// looks like:
+// file.css:1:1
+// there's a problem here:
+//   contents
+//   ~~~~~~~~
+func (l *locationError) Error() string {
+	lineNumber, lineStart, lineEnd := l.line()
+
 	line := l.Source.Content[lineStart:lineEnd]
 	col := l.start - lineStart
 
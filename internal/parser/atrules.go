@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/lexer"
+)
+
+// parseFontFaceAtRule parses a @font-face rule.
+// See: https://www.w3.org/TR/css-fonts-4/#font-face-rule.
+func (p *parser) parseFontFaceAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Loc:  p.lexer.Location(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	r.Block = p.parseDeclarationBlock()
+	return r
+}
+
+// parsePageAtRule parses a @page rule with an optional list of page selectors.
+// See: https://www.w3.org/TR/css-page-3/#at-page-rule.
+func (p *parser) parsePageAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Loc:  p.lexer.Location(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	if p.lexer.Current != lexer.LCurly {
+		r.Preludes = []ast.AtPrelude{p.parsePageSelectorList()}
+	}
+
+	prevInPage := p.inPage
+	p.inPage = true
+	defer func() {
+		p.inPage = prevInPage
+	}()
+
+	r.Block = p.parseDeclarationBlock()
+	return r
+}
+
+func (p *parser) parsePageSelectorList() *ast.PageSelectorList {
+	l := &ast.PageSelectorList{
+		Loc: p.lexer.Location(),
+	}
+
+	for {
+		s := &ast.PageSelector{
+			Loc: p.lexer.Location(),
+		}
+
+		if p.lexer.Current == lexer.Ident {
+			s.Name = p.lexer.CurrentString
+			p.lexer.Next()
+		}
+
+		for p.lexer.Current == lexer.Colon {
+			p.lexer.Next()
+			s.PseudoClasses = append(s.PseudoClasses, p.lexer.CurrentString)
+			p.lexer.Expect(lexer.Ident)
+		}
+
+		if s.Name == "" && len(s.PseudoClasses) == 0 {
+			p.lexer.Errorf("unexpected token: %s, expected page selector", p.lexer.Current.String())
+		}
+		l.Selectors = append(l.Selectors, s)
+
+		if p.lexer.Current == lexer.Comma {
+			p.lexer.Next()
+			continue
+		}
+
+		return l
+	}
+}
+
+// parsePageMarginAtRule parses a margin rule, e.g. @top-left, inside of @page.
+// See: https://www.w3.org/TR/css-page-3/#margin-at-rule.
+func (p *parser) parsePageMarginAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Loc:  p.lexer.Location(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	// Margin rules cannot be nested.
+	prevInPage := p.inPage
+	p.inPage = false
+	defer func() {
+		p.inPage = prevInPage
+	}()
+
+	r.Block = p.parseDeclarationBlock()
+	return r
+}
+
+// parseNamespaceAtRule parses a @namespace rule with an optional prefix.
+// See: https://www.w3.org/TR/css-namespaces-3/#declaration.
+func (p *parser) parseNamespaceAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Loc:  p.lexer.Location(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	if p.lexer.Current == lexer.Ident {
+		r.Preludes = append(r.Preludes, &ast.Identifier{
+			Loc:   p.lexer.Location(),
+			Value: p.lexer.CurrentString,
+		})
+		p.lexer.Next()
+	}
+
+	switch p.lexer.Current {
+	case lexer.URL:
+		r.Preludes = append(r.Preludes, &ast.URL{
+			Loc:   p.lexer.Location(),
+			Value: p.lexer.CurrentString,
+		})
+		p.lexer.Next()
+
+	case lexer.FunctionStart:
+		if p.lexer.CurrentString != "url" {
+			p.lexer.Errorf("@namespace must be a url or string")
+		}
+		p.lexer.Next()
+
+		r.Preludes = append(r.Preludes, &ast.String{
+			Loc:   p.lexer.Location(),
+			Value: p.lexer.CurrentString,
+		})
+		p.lexer.Expect(lexer.String)
+		p.lexer.Expect(lexer.RParen)
+
+	case lexer.String:
+		r.Preludes = append(r.Preludes, &ast.String{
+			Loc:   p.lexer.Location(),
+			Value: p.lexer.CurrentString,
+		})
+		p.lexer.Next()
+
+	default:
+		p.lexer.Errorf("@namespace must be a url or string")
+	}
+
+	p.lexer.Expect(lexer.Semicolon)
+	return r
+}
+
+// parseCharsetAtRule parses a @charset rule.
+// See: https://www.w3.org/TR/css-syntax-3/#charset-rule.
+func (p *parser) parseCharsetAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Loc:  p.lexer.Location(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	r.Preludes = []ast.AtPrelude{&ast.String{
+		Loc:   p.lexer.Location(),
+		Value: p.lexer.CurrentString,
+	}}
+	p.lexer.Expect(lexer.String)
+	p.lexer.Expect(lexer.Semicolon)
+
+	return r
+}
@@ -1,53 +1,105 @@
 package parser
 
 import (
-	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/ast"
 	"github.com/stephen/cssc/internal/lexer"
 	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/internal/sources"
 )
 
-// Parse parses an input stylesheet.
-func Parse(source *sources.Source) (ss *ast.Stylesheet, err error) {
-	p := newParser(source)
+// Parse parses an input stylesheet. The parser recovers from syntax errors,
+// so a best-effort stylesheet is always returned, along with the first error
+// found, if any. Use ParseWithReporter to see every error.
+func Parse(source *sources.Source) (*ast.Stylesheet, error) {
+	var errs firstErrorReporter
+	ss := ParseWithReporter(source, &errs)
+	return ss, errs.err
+}
+
+// ParseWithReporter parses an input stylesheet, reporting every syntax error to
+// reporter. Invalid rules and declarations are skipped in the same way as browsers,
+// following https://www.w3.org/TR/css-syntax-3/#error-handling.
+func ParseWithReporter(source *sources.Source, reporter logging.Reporter) (ss *ast.Stylesheet) {
+	var p *parser
 	defer func() {
 		if rErr := recover(); rErr != nil {
-			if errI, ok := rErr.(*lexer.Error); ok {
-				ss, err = nil, errI
+			// Errors from the lexer when reading the first token can't be
+			// recovered from, since there is no parser yet.
+			if errI, ok := rErr.(*lexer.Error); ok && p == nil {
+				reporter.AddError(errI)
+				ss = &ast.Stylesheet{}
 				return
 			}
 
-			if errI, ok := rErr.(error); ok {
+			if errI, ok := rErr.(error); ok && p != nil {
 				start, end := p.lexer.Range()
 				panic(logging.LocationErrorf(source, start, end, "%v", errI))
 			}
 
 			// Re-panic unknown issues.
-			panic(err)
+			panic(rErr)
 		}
 	}()
 
+	p = newParser(source, reporter)
 	p.parse()
-	return p.ss, nil
+	return p.ss
 }
 
-func newParser(source *sources.Source) *parser {
+func newParser(source *sources.Source, reporter logging.Reporter) *parser {
+	held := &holdingReporter{reporter: reporter}
 	return &parser{
-		lexer: lexer.NewLexer(source),
-		ss:    &ast.Stylesheet{},
+		source:   source,
+		reporter: held,
+		held:     held,
+		lexer:    lexer.NewLexerWithReporter(source, held),
+		ss:       &ast.Stylesheet{},
 	}
 }
 
 type parser struct {
-	lexer *lexer.Lexer
-	ss    *ast.Stylesheet
+	source   *sources.Source
+	reporter logging.Reporter
+	lexer    *lexer.Lexer
+	ss       *ast.Stylesheet
+
+	// held is the reporter that errors are sent to, which holds them back while
+	// the parser speculates. See speculate.
+	held *holdingReporter
+
+	// inStyleRule is set while parsing the block of a style rule, where nested
+	// style rules and conditional group rules are allowed.
+	// See https://www.w3.org/TR/css-nesting-1/#nesting.
+	inStyleRule bool
+
+	// inPage is set while parsing the block of a @page rule, where margin rules
+	// are allowed.
+	// See https://www.w3.org/TR/css-page-3/#margin-at-rules.
+	inPage bool
+}
+
+// firstErrorReporter keeps the first error reported to it.
+type firstErrorReporter struct {
+	err error
+}
+
+// AddError implements logging.Reporter.
+func (r *firstErrorReporter) AddError(err error) {
+	if r.err == nil {
+		r.err = err
+	}
 }
 
 func (p *parser) parse() {
 	for p.lexer.Current != lexer.EOF {
 		switch p.lexer.Current {
 		case lexer.At:
-			p.parseAtRule()
+			var r ast.Node
+			if !p.try(func() { r = p.parseAtRule() }) {
+				p.skipRule(false)
+				break
+			}
+			p.ss.Nodes = append(p.ss.Nodes, r)
 
 		case lexer.Semicolon:
 			p.lexer.Next()
@@ -65,9 +117,13 @@ func (p *parser) parse() {
 			p.lexer.Next()
 
 		default:
-			p.ss.Nodes = append(p.ss.Nodes, p.parseQualifiedRule(false))
+			var r ast.Node
+			if !p.try(func() { r = p.parseQualifiedRule(false) }) {
+				p.skipRule(false)
+				break
+			}
+			p.ss.Nodes = append(p.ss.Nodes, r)
 		}
-
 	}
 }
 
@@ -98,71 +154,214 @@ func (p *parser) parseQualifiedRule(isKeyframes bool) *ast.QualifiedRule {
 			p.lexer.Errorf("unexpected EOF")
 
 		case lexer.LCurly:
-			block := &ast.DeclarationBlock{
-				Loc: p.lexer.Location(),
+			if isKeyframes {
+				r.Block = p.parseDeclarationBlock()
+				return r
 			}
 
-			r.Block = block
+			r.Block = p.parseStyleRuleBlock()
+			return r
+
+		default:
+			if isKeyframes {
+				r.Prelude = p.parseKeyframeSelectorList()
+				continue
+			}
+
+			r.Prelude = p.parseSelectorList()
+		}
+	}
+}
+
+// parseStyleRuleBlock parses the block of a style rule, which may contain nested
+// rules in addition to declarations.
+func (p *parser) parseStyleRuleBlock() *ast.DeclarationBlock {
+	prevInStyleRule := p.inStyleRule
+	p.inStyleRule = true
+	defer func() {
+		p.inStyleRule = prevInStyleRule
+	}()
+
+	return p.parseDeclarationBlock()
+}
+
+// parseConditionalBlock parses the block of a conditional group rule, e.g.
+// @media or @supports. If the rule is nested inside of a style rule, the block
+// holds declarations and nested rules. Otherwise, it holds rules.
+func (p *parser) parseConditionalBlock() ast.Block {
+	if p.inStyleRule {
+		return p.parseDeclarationBlock()
+	}
+
+	return p.parseQualifiedRuleBlock()
+}
+
+// parseDeclarationBlock parses a block of declarations. At-rules inside of the
+// block, e.g. margin rules inside of @page, are kept in the block's Rules. Inside
+// of a style rule, nested style rules are kept in Rules as well. Nested style rules
+// that start with an identifier, e.g. "div & { ... }", are told apart from
+// declarations by trying to parse a declaration first.
+//
+// Comments before a declaration are kept on the declaration. Other comments are
+// kept in Rules.
+func (p *parser) parseDeclarationBlock() *ast.DeclarationBlock {
+	block := &ast.DeclarationBlock{
+		Loc: p.lexer.Location(),
+	}
+	p.lexer.Expect(lexer.LCurly)
+
+	var comments []*ast.Comment
+	flushComments := func() {
+		for _, c := range comments {
+			block.Rules = append(block.Rules, c)
+		}
+		comments = nil
+	}
+
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			p.errorf("unexpected EOF")
+			flushComments()
+			return block
+
+		case lexer.RCurly:
+			p.lexer.Next()
+			flushComments()
+			return block
+
+		case lexer.Semicolon:
 			p.lexer.Next()
 
-			for p.lexer.Current != lexer.RCurly {
-				decl := &ast.Declaration{
-					Loc:      p.lexer.Location(),
-					Property: p.lexer.CurrentString,
+		case lexer.Comment:
+			comments = append(comments, &ast.Comment{
+				Loc:  p.lexer.Location(),
+				Text: p.lexer.CurrentString,
+			})
+			p.lexer.Next()
+
+		case lexer.At:
+			var r ast.Node
+			if !p.try(func() { r = p.parseAtRule() }) {
+				p.skipRule(true)
+				break
+			}
+			flushComments()
+			block.Rules = append(block.Rules, r)
+
+		default:
+			if p.inStyleRule && p.lexer.Current != lexer.Ident {
+				var r ast.Node
+				if !p.try(func() { r = p.parseQualifiedRule(false) }) {
+					p.skipRule(true)
+					break
 				}
-				p.lexer.Expect(lexer.Ident)
-				p.lexer.Expect(lexer.Colon)
-			values:
-				for {
-					switch p.lexer.Current {
-					case lexer.EOF:
-						p.lexer.Errorf("unexpected EOF")
-
-					case lexer.Delim:
-						if p.lexer.CurrentString != "!" {
-							p.lexer.Errorf("unexpected token: %s", p.lexer.CurrentString)
-						}
-						p.lexer.Next()
-
-						if !isImportantString(p.lexer.CurrentString) {
-							p.lexer.Errorf("expected !important, unexpected token: %s", p.lexer.CurrentString)
-						}
-						p.lexer.Next()
-						decl.Important = true
-
-					case lexer.Comma:
-						decl.Values = append(decl.Values, &ast.Comma{Loc: p.lexer.Location()})
-						p.lexer.Next()
-
-					default:
-						val := p.parseValue()
-						if val == nil {
-							if len(decl.Values) == 0 {
-								p.lexer.Errorf("declaration must have a value")
-							}
-							block.Declarations = append(block.Declarations, decl)
-
-							break values
-						}
-
-						decl.Values = append(decl.Values, val)
-					}
+				flushComments()
+				block.Rules = append(block.Rules, r)
+				break
+			}
+
+			var decl *ast.Declaration
+			parseDeclaration := func() {
+				decl = p.parseDeclaration()
+				if p.lexer.Current != lexer.Semicolon && p.lexer.Current != lexer.RCurly && p.lexer.Current != lexer.EOF {
+					p.lexer.Errorf("unexpected token: %s, expected ; or }", p.lexer.Current.String())
+				}
+			}
+
+			if p.inStyleRule {
+				// Inside of a style rule, input that starts with an identifier is a
+				// declaration if it can be parsed as one, and a nested style rule
+				// otherwise, e.g. "div & { ... }".
+				// See https://www.w3.org/TR/css-syntax-3/#consume-block-contents.
+				if p.speculate(parseDeclaration) {
+					decl.Comments, comments = comments, nil
+					block.Declarations = append(block.Declarations, decl)
+					break
 				}
 
-				if p.lexer.Current == lexer.Semicolon {
-					p.lexer.Next()
+				var r ast.Node
+				if p.speculate(func() { r = p.parseQualifiedRule(false) }) {
+					flushComments()
+					block.Rules = append(block.Rules, r)
+					break
 				}
 			}
+
+			// Parse the declaration again if it was speculated, so that its errors
+			// are reported.
+			if !p.try(parseDeclaration) {
+				p.skipDeclaration()
+				break
+			}
+			decl.Comments, comments = comments, nil
+			block.Declarations = append(block.Declarations, decl)
+		}
+	}
+}
+
+// parseDeclaration parses a single declaration, e.g. width: 2px. It stops
+// at the first token that cannot be part of the declaration's values.
+func (p *parser) parseDeclaration() *ast.Declaration {
+	decl := &ast.Declaration{
+		Loc:      p.lexer.Location(),
+		Property: p.lexer.CurrentString,
+	}
+	p.lexer.Expect(lexer.Ident)
+	p.lexer.Expect(lexer.Colon)
+	p.parseDeclarationValues(decl)
+
+	return decl
+}
+
+// parseDeclarationValues parses the values of a declaration after its colon.
+// Comments between the values are kept in place.
+func (p *parser) parseDeclarationValues(decl *ast.Declaration) {
+	var hasValue bool
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			// The enclosing block will report the unexpected EOF.
+			if !hasValue {
+				p.lexer.Errorf("unexpected EOF")
+			}
+			return
+
+		case lexer.Delim:
+			if p.lexer.CurrentString != "!" {
+				p.lexer.Errorf("unexpected token: %s", p.lexer.CurrentString)
+			}
+			p.lexer.Next()
+
+			if !isImportantString(p.lexer.CurrentString) {
+				p.lexer.Errorf("expected !important, unexpected token: %s", p.lexer.CurrentString)
+			}
+			p.lexer.Next()
+			decl.Important = true
+
+		case lexer.Comma:
+			decl.Values = append(decl.Values, &ast.Comma{Loc: p.lexer.Location()})
+			p.lexer.Next()
+
+		case lexer.Comment:
+			decl.Values = append(decl.Values, &ast.Comment{
+				Loc:  p.lexer.Location(),
+				Text: p.lexer.CurrentString,
+			})
 			p.lexer.Next()
-			return r
 
 		default:
-			if isKeyframes {
-				r.Prelude = p.parseKeyframeSelectorList()
-				continue
+			val := p.parseValue()
+			if val == nil {
+				if !hasValue {
+					p.lexer.Errorf("declaration must have a value")
+				}
+
+				return
 			}
 
-			r.Prelude = p.parseSelectorList()
+			decl.Values = append(decl.Values, val)
+			hasValue = true
 		}
 	}
 }
@@ -217,15 +416,25 @@ func (p *parser) parseMathExpression() ast.Value {
 
 func (p *parser) parseMathSum() ast.Value {
 	left := p.parseMathProduct()
+	if left == nil {
+		p.lexer.Errorf("expected value")
+	}
 
 	for p.lexer.Current == lexer.Delim && (p.lexer.CurrentString == "+" || p.lexer.CurrentString == "-") {
 		op := p.lexer.CurrentString
+		start, end := p.lexer.Range()
 		p.lexer.Expect(lexer.Delim)
+
+		right := p.parseMathProduct()
+		if right == nil {
+			p.lexer.LocationErrorf(start, end, "expected value")
+		}
+
 		left = &ast.MathExpression{
 			Loc:      left.Location(),
 			Left:     left,
 			Operator: op,
-			Right:    p.parseMathProduct(),
+			Right:    right,
 		}
 	}
 
@@ -233,22 +442,45 @@ func (p *parser) parseMathSum() ast.Value {
 }
 
 func (p *parser) parseMathProduct() ast.Value {
-	left := p.parseValue()
+	left := p.parseMathValue()
+	if left == nil {
+		return nil
+	}
 
 	for p.lexer.Current == lexer.Delim && (p.lexer.CurrentString == "*" || p.lexer.CurrentString == "/") {
 		op := p.lexer.CurrentString
+		start, end := p.lexer.Range()
 		p.lexer.Expect(lexer.Delim)
+
+		right := p.parseMathValue()
+		if right == nil {
+			p.lexer.LocationErrorf(start, end, "expected value")
+		}
+
 		left = &ast.MathExpression{
 			Loc:      left.Location(),
 			Left:     left,
 			Operator: op,
-			Right:    p.parseValue(),
+			Right:    right,
 		}
 	}
 
 	return left
 }
 
+// parseMathValue parses a single value in a math expression, which may be a
+// parenthesized expression, e.g. (1px + 2rem).
+func (p *parser) parseMathValue() ast.Value {
+	if p.lexer.Current != lexer.LParen {
+		return p.parseValue()
+	}
+	p.lexer.Next()
+
+	v := p.parseMathSum()
+	p.lexer.Expect(lexer.RParen)
+	return v
+}
+
 // parseValue parses a possible ast value at the current position. Callers
 // can set allowMathOperators if the enclosing context allows math expressions.
 // See: https://www.w3.org/TR/css-values-4/#math-function.
@@ -299,17 +531,36 @@ func (p *parser) parseValue() ast.Value {
 			Value: p.lexer.CurrentString,
 		}
 
+	case lexer.URL:
+		defer p.lexer.Next()
+		return &ast.URL{
+			Loc:   p.lexer.Location(),
+			Value: p.lexer.CurrentString,
+		}
+
 	case lexer.FunctionStart:
 		fn := &ast.Function{
 			Loc:  p.lexer.Location(),
 			Name: p.lexer.CurrentString,
 		}
+
+		// Comments are kept in the arguments of functions, except for math
+		// functions, where they can be between the operands of an expression.
+		prevSkipComments := p.lexer.SkipComments
+		defer func() {
+			p.lexer.SkipComments = prevSkipComments
+		}()
+		p.lexer.SkipComments = fn.IsMath()
 		p.lexer.Next()
 
 	arguments:
 		for {
 			switch p.lexer.Current {
+			case lexer.EOF:
+				p.lexer.Errorf("unexpected EOF")
 			case lexer.RParen:
+				// The token after the function is read as in the enclosing context.
+				p.lexer.SkipComments = prevSkipComments
 				p.lexer.Next()
 				break arguments
 			case lexer.Comma:
@@ -317,43 +568,128 @@ func (p *parser) parseValue() ast.Value {
 					Loc: p.lexer.Location(),
 				})
 				p.lexer.Next()
+			case lexer.Comment:
+				fn.Arguments = append(fn.Arguments, &ast.Comment{
+					Loc:  p.lexer.Location(),
+					Text: p.lexer.CurrentString,
+				})
+				p.lexer.Next()
 			default:
 				if fn.IsMath() {
 					fn.Arguments = append(fn.Arguments, p.parseMathExpression())
 					continue
 				}
-				fn.Arguments = append(fn.Arguments, p.parseValue())
+
+				arg := p.parseValue()
+				if arg == nil {
+					p.lexer.Errorf("unexpected token: %s", p.lexer.Current.String())
+				}
+				fn.Arguments = append(fn.Arguments, arg)
 			}
 		}
 
 		return fn
+
+	case lexer.BadString, lexer.BadURL:
+		panic(errReported)
+
 	default:
 		return nil
 	}
 }
 
-func (p *parser) parseAtRule() {
+func (p *parser) parseAtRule() ast.Node {
 	switch p.lexer.CurrentString {
 	case "import":
-		p.parseImportAtRule()
+		return p.parseImportAtRule()
 
 	case "media":
-		p.parseMediaAtRule()
+		return p.parseMediaAtRule()
 
-	case "keyframes", "-webkit-keyframes":
-		p.parseKeyframes()
+	case "supports":
+		return p.parseSupportsAtRule()
+
+	case "keyframes", "-webkit-keyframes", "-moz-keyframes", "-o-keyframes":
+		return p.parseKeyframes()
 
 	case "custom-media":
-		p.parseCustomMediaAtRule()
+		return p.parseCustomMediaAtRule()
+
+	case "font-face":
+		return p.parseFontFaceAtRule()
+
+	case "page":
+		return p.parsePageAtRule()
+
+	case "namespace":
+		return p.parseNamespaceAtRule()
+
+	case "charset":
+		return p.parseCharsetAtRule()
+
+	case "top-left-corner", "top-left", "top-center", "top-right", "top-right-corner",
+		"bottom-left-corner", "bottom-left", "bottom-center", "bottom-right", "bottom-right-corner",
+		"left-top", "left-middle", "left-bottom", "right-top", "right-middle", "right-bottom":
+		if !p.inPage {
+			p.lexer.Errorf("@%s is only allowed inside of @page", p.lexer.CurrentString)
+		}
+		return p.parsePageMarginAtRule()
 
 	default:
-		p.lexer.Errorf("unsupported at rule: %s", p.lexer.CurrentString)
+		return p.parseUnknownAtRule()
+	}
+}
+
+// parseQualifiedRuleBlock parses a block of rules, e.g. the body of
+// a @media or @supports rule.
+func (p *parser) parseQualifiedRuleBlock() *ast.QualifiedRuleBlock {
+	block := &ast.QualifiedRuleBlock{
+		Loc: p.lexer.Location(),
+	}
+	p.lexer.Expect(lexer.LCurly)
+
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			p.errorf("unexpected EOF")
+			return block
+
+		case lexer.RCurly:
+			p.lexer.Next()
+			return block
+
+		case lexer.Semicolon:
+			p.lexer.Next()
+
+		case lexer.At:
+			var r ast.Node
+			if !p.try(func() { r = p.parseAtRule() }) {
+				p.skipRule(true)
+				break
+			}
+			block.Rules = append(block.Rules, r)
+
+		case lexer.Comment:
+			block.Rules = append(block.Rules, &ast.Comment{
+				Loc:  p.lexer.Location(),
+				Text: p.lexer.CurrentString,
+			})
+			p.lexer.Next()
+
+		default:
+			var r ast.Node
+			if !p.try(func() { r = p.parseQualifiedRule(false) }) {
+				p.skipRule(true)
+				break
+			}
+			block.Rules = append(block.Rules, r)
+		}
 	}
 }
 
 // parseImportAtRule parses an import at rule. It roughly implements
 // https://www.w3.org/TR/css-cascade-4/#at-import.
-func (p *parser) parseImportAtRule() {
+func (p *parser) parseImportAtRule() *ast.AtRule {
 	prelude := &ast.String{}
 
 	imp := &ast.AtRule{
@@ -367,10 +703,6 @@ func (p *parser) parseImportAtRule() {
 	case lexer.URL:
 		prelude.Loc = p.lexer.Location()
 		prelude.Value = p.lexer.CurrentString
-		p.ss.Imports = append(p.ss.Imports, ast.ImportSpecifier{
-			Value:  prelude.Value,
-			AtRule: imp,
-		})
 		p.lexer.Next()
 
 	case lexer.FunctionStart:
@@ -381,38 +713,100 @@ func (p *parser) parseImportAtRule() {
 
 		prelude.Loc = p.lexer.Location()
 		prelude.Value = p.lexer.CurrentString
-		p.ss.Imports = append(p.ss.Imports, ast.ImportSpecifier{
-			Value:  prelude.Value,
-			AtRule: imp,
-		})
 		p.lexer.Expect(lexer.String)
 		p.lexer.Expect(lexer.RParen)
 
 	case lexer.String:
 		prelude.Loc = p.lexer.Location()
 		prelude.Value = p.lexer.CurrentString
-		p.ss.Imports = append(p.ss.Imports, ast.ImportSpecifier{
-			Value:  prelude.Value,
-			AtRule: imp,
-		})
 		p.lexer.Expect(lexer.String)
 
 	default:
 		p.lexer.Errorf("unexpected import specifier")
 	}
 
-	// XXX: also support @supports.
+	if layer := p.parseImportLayer(); layer != nil {
+		imp.Preludes = append(imp.Preludes, layer)
+	}
+
+	if p.lexer.Current == lexer.FunctionStart && p.lexer.CurrentString == "supports" {
+		imp.Preludes = append(imp.Preludes, p.parseImportSupports())
+	}
+
 	mq := p.parseMediaQueryList()
-	if mq != nil {
+	if len(mq.Queries) > 0 {
 		imp.Preludes = append(imp.Preludes, mq)
 	}
 
-	p.ss.Nodes = append(p.ss.Nodes, imp)
+	p.ss.Imports = append(p.ss.Imports, ast.ImportSpecifier{
+		Value:  prelude.Value,
+		AtRule: imp,
+	})
+
+	return imp
+}
+
+// parseImportLayer parses the optional layer or layer(name) of an @import.
+func (p *parser) parseImportLayer() *ast.ImportLayer {
+	if p.lexer.CurrentString != "layer" {
+		return nil
+	}
+
+	layer := &ast.ImportLayer{
+		Loc: p.lexer.Location(),
+	}
+
+	switch p.lexer.Current {
+	case lexer.Ident:
+		p.lexer.Next()
+
+	case lexer.FunctionStart:
+		p.lexer.Next()
+
+		// Layer names may be dotted, e.g. layer(framework.base).
+		for {
+			layer.Name += p.lexer.CurrentString
+			p.lexer.Expect(lexer.Ident)
+
+			if p.lexer.Current != lexer.Delim || p.lexer.CurrentString != "." {
+				break
+			}
+			layer.Name += p.lexer.CurrentString
+			p.lexer.Next()
+		}
+		p.lexer.Expect(lexer.RParen)
+
+	default:
+		return nil
+	}
+
+	return layer
+}
+
+// parseImportSupports parses the supports() condition of an @import. The
+// condition may also be a bare declaration, e.g. supports(display: grid).
+func (p *parser) parseImportSupports() *ast.ImportSupports {
+	s := &ast.ImportSupports{
+		Loc: p.lexer.Location(),
+	}
+	p.lexer.Next()
+
+	if p.lexer.Current == lexer.Ident && p.lexer.CurrentString != "not" {
+		s.Condition = &ast.SupportsDeclaration{
+			Loc:         p.lexer.Location(),
+			Declaration: p.parseDeclaration(),
+		}
+	} else {
+		s.Condition = p.parseSupportsCondition()
+	}
+	p.lexer.Expect(lexer.RParen)
+
+	return s
 }
 
 // parseKeyframes parses a keyframes at rule. It roughly implements
 // https://www.w3.org/TR/css-animations-1/#keyframes
-func (p *parser) parseKeyframes() {
+func (p *parser) parseKeyframes() *ast.AtRule {
 	r := &ast.AtRule{
 		Loc:  p.lexer.Location(),
 		Name: p.lexer.CurrentString,
@@ -445,22 +839,34 @@ func (p *parser) parseKeyframes() {
 	for {
 		switch p.lexer.Current {
 		case lexer.EOF:
-			p.lexer.Errorf("unexpected EOF")
+			p.errorf("unexpected EOF")
+			return r
 
 		case lexer.RCurly:
-			p.ss.Nodes = append(p.ss.Nodes, r)
 			p.lexer.Next()
-			return
+			return r
+
+		case lexer.Comment:
+			block.Rules = append(block.Rules, &ast.Comment{
+				Loc:  p.lexer.Location(),
+				Text: p.lexer.CurrentString,
+			})
+			p.lexer.Next()
 
 		default:
-			block.Rules = append(block.Rules, p.parseQualifiedRule(true))
+			var rule ast.Node
+			if !p.try(func() { rule = p.parseQualifiedRule(true) }) {
+				p.skipRule(true)
+				break
+			}
+			block.Rules = append(block.Rules, rule)
 		}
 	}
 }
 
 // parseMediaAtRule parses a media at rule. It roughly implements
 // https://www.w3.org/TR/mediaqueries-4/#media.
-func (p *parser) parseMediaAtRule() {
+func (p *parser) parseMediaAtRule() *ast.AtRule {
 	r := &ast.AtRule{
 		Loc:  p.lexer.Location(),
 		Name: p.lexer.CurrentString,
@@ -468,26 +874,9 @@ func (p *parser) parseMediaAtRule() {
 	p.lexer.Next()
 
 	r.Preludes = []ast.AtPrelude{p.parseMediaQueryList()}
+	r.Block = p.parseConditionalBlock()
 
-	block := &ast.QualifiedRuleBlock{
-		Loc: p.lexer.Location(),
-	}
-	r.Block = block
-	p.lexer.Expect(lexer.LCurly)
-	for {
-		switch p.lexer.Current {
-		case lexer.EOF:
-			p.lexer.Errorf("unexpected EOF")
-
-		case lexer.RCurly:
-			p.ss.Nodes = append(p.ss.Nodes, r)
-			p.lexer.Next()
-			return
-
-		default:
-			block.Rules = append(block.Rules, p.parseQualifiedRule(false))
-		}
-	}
+	return r
 }
 
 func (p *parser) parseMediaQueryList() *ast.MediaQueryList {
@@ -513,10 +902,6 @@ func (p *parser) parseMediaQueryList() *ast.MediaQueryList {
 		break
 	}
 
-	if len(l.Queries) == 0 {
-		return nil
-	}
-
 	return l
 }
 
@@ -667,7 +1052,7 @@ func (p *parser) parseMediaRangeOperator() string {
 
 // parseCustomMediaAtRule parses a @custom-media rule.
 // See: https://www.w3.org/TR/mediaqueries-5/#custom-mq.
-func (p *parser) parseCustomMediaAtRule() {
+func (p *parser) parseCustomMediaAtRule() *ast.AtRule {
 	r := &ast.AtRule{
 		Loc:  p.lexer.Location(),
 		Name: p.lexer.CurrentString,
@@ -686,8 +1071,7 @@ func (p *parser) parseCustomMediaAtRule() {
 	if len(queries.Queries) != 1 {
 		p.lexer.Errorf("@custom-media rule requires a single media query argument")
 	}
-	r.Preludes = append(r.Preludes)
 	r.Preludes = append(r.Preludes, queries.Queries[0])
 
-	p.ss.Nodes = append(p.ss.Nodes, r)
+	return r
 }
@@ -0,0 +1,166 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reporter []error
+
+func (r *reporter) AddError(err error) {
+	*r = append(*r, err)
+}
+
+// Parse parses s with a reporter, returning the printed output and any errors.
+func Parse(t testing.TB, s string) (string, []error) {
+	var errs reporter
+	ss := parser.ParseWithReporter(&sources.Source{
+		Path:    "main.css",
+		Content: s,
+	}, &errs)
+
+	out, err := printer.Print(ss, printer.Options{})
+	require.NoError(t, err)
+
+	return out, errs
+}
+
+func TestRecovery_Declarations(t *testing.T) {
+	out, errs := Parse(t, `.a {
+	color: red;
+	width 2px;
+	height: 2px;
+	margin: ;
+	padding: 1px;
+}`)
+
+	assert.Equal(t, `.a{color:red;height:2px;padding:1px}`, out)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "main.css:3:7\nexpected :, but got DIMENSION instead:\n\t  width 2px;\n\t        ~~~", errs[0].Error())
+	assert.Equal(t, "main.css:5:9\ndeclaration must have a value:\n\t  margin: ;\n\t          ~", errs[1].Error())
+}
+
+func TestRecovery_Rules(t *testing.T) {
+	out, errs := Parse(t, `.a { color: red }
+.b!c { color: blue }
+.d { color: green }
+@media (width: { .e { color: red } }
+.f { color: white }`)
+
+	assert.Equal(t, `.a{color:red}.d{color:green}.f{color:white}`, out)
+	assert.Len(t, errs, 2)
+}
+
+func TestRecovery_Nested(t *testing.T) {
+	out, errs := Parse(t, `@media screen {
+	.a { color: red }
+	.b!c { color: blue }
+	.d { color: green; width: ; }
+}
+.e { color: white }`)
+
+	assert.Equal(t, `@media screen{.a{color:red}.d{color:green}}.e{color:white}`, out)
+	assert.Len(t, errs, 2)
+}
+
+func TestRecovery_EOF(t *testing.T) {
+	out, errs := Parse(t, `.a { color: red }
+.b { color: blue`)
+
+	assert.Equal(t, `.a{color:red}.b{color:blue}`, out)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "unexpected EOF")
+}
+
+func TestRecovery_Lexer(t *testing.T) {
+	out, errs := Parse(t, `.a { content: "unclosed;
+	color: red }
+.b { color: blue }
+\
+.c { color: green }`)
+
+	// The unclosed string swallows the rest of its declaration, and the stray
+	// backslash makes the selector of .c invalid.
+	assert.Equal(t, `.a{}.b{color:blue}`, out)
+	require.Len(t, errs, 3)
+	assert.Contains(t, errs[0].Error(), "unclosed string")
+	assert.Contains(t, errs[1].Error(), "parse error")
+	assert.Contains(t, errs[2].Error(), "unexpected delimeter")
+}
+
+func TestParse_FirstError(t *testing.T) {
+	ss, err := parser.Parse(&sources.Source{
+		Path:    "main.css",
+		Content: `.a { width 2px } .b { color: red; }`,
+	})
+
+	assert.EqualError(t, err, "main.css:1:11\nexpected :, but got DIMENSION instead:\n\t.a { width 2px } .b { color: red; }\n\t           ~~~")
+	assert.Len(t, ss.Nodes, 2)
+}
+
+func TestRecovery_Functions(t *testing.T) {
+	for _, tc := range []struct {
+		in, out, err string
+	}{
+		{`a{width:calc(1px`, `a{}`, "unexpected EOF"},
+		{`a{b:var(--x,}`, `a{}`, "unexpected token: }"},
+		{`a{width:min(1px,}`, `a{}`, "expected value"},
+		{`@font-face{src:local(`, `@font-face{}`, "unexpected EOF"},
+		{`a{width:calc(1px + ); color: red}`, `a{color:red}`, "main.css:1:17\nexpected value"},
+		{`a{width:calc(1px * ); color: red}`, `a{color:red}`, "main.css:1:17\nexpected value"},
+	} {
+		out, errs := Parse(t, tc.in)
+		assert.Equal(t, tc.out, out, tc.in)
+		if assert.NotEmpty(t, errs, tc.in) {
+			assert.Contains(t, errs[0].Error(), tc.err, tc.in)
+		}
+	}
+}
+
+func TestRecovery_Comments(t *testing.T) {
+	out, errs := Parse(t, `.a { width: /* no value */; color: /* red */ red }`)
+
+	assert.Equal(t, `.a{color:/* red */ red}`, out)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "declaration must have a value")
+}
+
+func TestPageMarginRules(t *testing.T) {
+	out, errs := Parse(t, `@page { @top-left { content: "x" } }`)
+	assert.Equal(t, `@page{@top-left{content:"x"}}`, out)
+	assert.Empty(t, errs)
+
+	for _, tc := range []struct {
+		in, out string
+	}{
+		{`@top-left { content: "x" } .a { color: red }`, `.a{color:red}`},
+		{`@media print { @top-left { content: "x" } .a { color: red } }`, `@media print{.a{color:red}}`},
+		{`.a { @bottom-right { content: "x" } color: red }`, `.a{color:red}`},
+		{`@page { @top-left { @top-right { content: "x" } color: red } }`, `@page{@top-left{color:red}}`},
+	} {
+		out, errs := Parse(t, tc.in)
+		assert.Equal(t, tc.out, out, tc.in)
+		if assert.Len(t, errs, 1, tc.in) {
+			assert.Contains(t, errs[0].Error(), "is only allowed inside of @page", tc.in)
+		}
+	}
+}
+
+func TestRecovery_NestedIdentifier(t *testing.T) {
+	// Errors inside of a nested rule are reported once.
+	out, errs := Parse(t, `.a { img { color red; width: 1px } }`)
+	assert.Equal(t, `.a{img{width:1px}}`, out)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "expected :")
+
+	// If it isn't a nested rule either, the declaration's error is reported.
+	out, errs = Parse(t, `.a { color red; width: 1px }`)
+	assert.Equal(t, `.a{width:1px}`, out)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "expected :")
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"errors"
+
+	"github.com/stephen/cssc/internal/lexer"
+	"github.com/stephen/cssc/internal/logging"
+)
+
+// errReported is raised to abandon invalid input whose error has already been
+// reported, e.g. a BadString token that the lexer reported.
+var errReported = errors.New("error already reported")
+
+// try runs fn and recovers from any lexer error it raises. The error is
+// reported and false is returned, so that the caller can skip past the
+// invalid input.
+func (p *parser) try(fn func()) (ok bool) {
+	defer func() {
+		if rErr := recover(); rErr != nil {
+			if rErr == errReported {
+				ok = false
+				return
+			}
+
+			err, isLexerError := rErr.(*lexer.Error)
+			if !isLexerError {
+				panic(rErr)
+			}
+
+			p.reporter.AddError(err)
+			ok = false
+		}
+	}()
+
+	fn()
+	return true
+}
+
+// holdingReporter forwards errors to reporter, unless it is holding them back.
+type holdingReporter struct {
+	reporter logging.Reporter
+
+	// held is the errors that are being held back. If it is nil, errors are
+	// forwarded.
+	held *[]error
+}
+
+// AddError implements logging.Reporter.
+func (r *holdingReporter) AddError(err error) {
+	if r.held != nil {
+		*r.held = append(*r.held, err)
+		return
+	}
+
+	r.reporter.AddError(err)
+}
+
+// speculate runs fn on input that may turn out to be something else. If fn
+// succeeds, the errors that it reported are reported and true is returned.
+// Otherwise, the errors are dropped and the lexer is rewound to where it was
+// before fn, so that the input can be parsed again.
+func (p *parser) speculate(fn func()) bool {
+	lexer := *p.lexer
+	prev := p.held.held
+	var errs []error
+	p.held.held = &errs
+
+	ok := p.try(fn)
+	p.held.held = prev
+	if !ok {
+		*p.lexer = lexer
+		return false
+	}
+
+	for _, err := range errs {
+		p.reporter.AddError(err)
+	}
+	return true
+}
+
+// errorf reports an error at the current token without stopping the parser.
+func (p *parser) errorf(f string, args ...interface{}) {
+	start, end := p.lexer.Range()
+	p.reporter.AddError(logging.LocationErrorf(p.source, start, end, f, args...))
+}
+
+// skipRule skips the rest of an invalid rule. It stops after a ; or {} block at the
+// current nesting level. If nested is set, it also stops before the } that ends the
+// enclosing block. See https://www.w3.org/TR/css-syntax-3/#consume-qualified-rule.
+func (p *parser) skipRule(nested bool) {
+	for !p.try(func() { p.skipRuleTokens(nested) }) {
+	}
+}
+
+func (p *parser) skipRuleTokens(nested bool) {
+	depth := 0
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			return
+
+		case lexer.Semicolon:
+			if depth == 0 {
+				p.lexer.Next()
+				return
+			}
+
+		case lexer.LCurly, lexer.LParen, lexer.LBracket, lexer.FunctionStart:
+			depth++
+
+		case lexer.RCurly:
+			if depth == 0 {
+				if nested {
+					return
+				}
+
+				// A stray } at the top-level is just skipped.
+				break
+			}
+
+			depth--
+			if depth == 0 {
+				p.lexer.Next()
+				return
+			}
+
+		case lexer.RParen, lexer.RBracket:
+			if depth > 0 {
+				depth--
+			}
+		}
+
+		p.lexer.Next()
+	}
+}
+
+// skipDeclaration skips the rest of an invalid declaration. It stops after the
+// next ; or before the } that ends the enclosing block.
+// See https://www.w3.org/TR/css-syntax-3/#consume-list-of-declarations.
+func (p *parser) skipDeclaration() {
+	for !p.try(p.skipDeclarationTokens) {
+	}
+}
+
+func (p *parser) skipDeclarationTokens() {
+	depth := 0
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			return
+
+		case lexer.Semicolon:
+			if depth == 0 {
+				p.lexer.Next()
+				return
+			}
+
+		case lexer.RCurly:
+			if depth == 0 {
+				return
+			}
+			depth--
+
+		case lexer.LCurly, lexer.LParen, lexer.LBracket, lexer.FunctionStart:
+			depth++
+
+		case lexer.RParen, lexer.RBracket:
+			if depth > 0 {
+				depth--
+			}
+		}
+
+		p.lexer.Next()
+	}
+}
@@ -1,7 +1,7 @@
 package parser
 
 import (
-	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/ast"
 	"github.com/stephen/cssc/internal/lexer"
 )
 
@@ -48,6 +48,13 @@ func (p *parser) parseSelector() *ast.Selector {
 			s.Parts = append(s.Parts, &ast.Whitespace{Loc: p.lexer.Location()})
 			p.lexer.Next()
 
+		case lexer.Comment:
+			s.Parts = append(s.Parts, &ast.Comment{
+				Loc:  p.lexer.Location(),
+				Text: p.lexer.CurrentString,
+			})
+			p.lexer.Next()
+
 		case lexer.Ident:
 			s.Parts = append(s.Parts, &ast.TypeSelector{
 				Loc:  p.lexer.Location(),
@@ -65,9 +72,10 @@ func (p *parser) parseSelector() *ast.Selector {
 		case lexer.Delim:
 			switch p.lexer.CurrentString {
 			case ".":
+				loc := p.lexer.Location()
 				p.lexer.Next()
 				s.Parts = append(s.Parts, &ast.ClassSelector{
-					Loc:  p.lexer.Location(),
+					Loc:  loc,
 					Name: p.lexer.CurrentString,
 				})
 				p.lexer.Expect(lexer.Ident)
@@ -86,24 +94,29 @@ func (p *parser) parseSelector() *ast.Selector {
 				})
 				p.lexer.Next()
 
+			case "&":
+				s.Parts = append(s.Parts, &ast.NestingSelector{
+					Loc: p.lexer.Location(),
+				})
+				p.lexer.Next()
+
 			default:
 				p.lexer.Errorf("unexpected delimeter: %s", p.lexer.CurrentString)
 			}
 
 		case lexer.Colon:
+			start := p.lexer.Location()
 			p.lexer.Next()
 
 			// Wrap it in a PseudoElementSelector if there are two colons.
 			var wrapper bool
-			var wrapperLocation ast.Loc
 			if p.lexer.Current == lexer.Colon {
 				wrapper = true
-				wrapperLocation = p.lexer.Location()
 				p.lexer.Next()
 			}
 
 			pc := &ast.PseudoClassSelector{
-				Loc:  p.lexer.Location(),
+				Loc:  start,
 				Name: p.lexer.CurrentString,
 			}
 
@@ -146,7 +159,7 @@ func (p *parser) parseSelector() *ast.Selector {
 
 			if wrapper {
 				s.Parts = append(s.Parts, &ast.PseudoElementSelector{
-					Loc:   wrapperLocation,
+					Loc:   start,
 					Inner: pc,
 				})
 				break
@@ -155,12 +168,12 @@ func (p *parser) parseSelector() *ast.Selector {
 			s.Parts = append(s.Parts, pc)
 
 		case lexer.LBracket:
-			p.lexer.Next()
-
 			attr := &ast.AttributeSelector{
-				Loc:      p.lexer.Location(),
-				Property: p.lexer.CurrentString,
+				Loc: p.lexer.Location(),
 			}
+			p.lexer.Next()
+
+			attr.Property = p.lexer.CurrentString
 			p.lexer.Expect(lexer.Ident)
 			if p.lexer.Current == lexer.RBracket {
 				s.Parts = append(s.Parts, attr)
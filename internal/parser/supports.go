@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/lexer"
+)
+
+// parseSupportsAtRule parses a supports at rule. It roughly implements
+// https://www.w3.org/TR/css-conditional-3/#at-supports.
+func (p *parser) parseSupportsAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Loc:  p.lexer.Location(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	r.Preludes = []ast.AtPrelude{p.parseSupportsCondition()}
+	r.Block = p.parseConditionalBlock()
+
+	return r
+}
+
+// parseSupportsCondition parses a <supports-condition>. and and or
+// cannot be mixed without parenthesis.
+func (p *parser) parseSupportsCondition() ast.SupportsCondition {
+	if p.lexer.Current == lexer.Ident && p.lexer.CurrentString == "not" {
+		n := &ast.SupportsNot{
+			Loc: p.lexer.Location(),
+		}
+		p.lexer.Next()
+		n.Condition = p.parseSupportsInParens()
+		return n
+	}
+
+	first := p.parseSupportsInParens()
+	if p.lexer.Current != lexer.Ident || (p.lexer.CurrentString != "and" && p.lexer.CurrentString != "or") {
+		return first
+	}
+
+	c := &ast.SupportsCombination{
+		Loc:        first.Location(),
+		Operator:   p.lexer.CurrentString,
+		Conditions: []ast.SupportsCondition{first},
+	}
+
+	for p.lexer.Current == lexer.Ident {
+		switch p.lexer.CurrentString {
+		case c.Operator:
+			p.lexer.Next()
+			c.Conditions = append(c.Conditions, p.parseSupportsInParens())
+
+		case "and", "or":
+			p.lexer.Errorf("cannot mix and and or in @supports without parenthesis")
+
+		default:
+			p.lexer.Errorf("unexpected identifier: %s", p.lexer.CurrentString)
+		}
+	}
+
+	return c
+}
+
+// parseSupportsInParens parses a <supports-in-parens>, which is either a nested
+// condition, a declaration test, a selector() test, or general enclosed syntax.
+func (p *parser) parseSupportsInParens() ast.SupportsCondition {
+	switch p.lexer.Current {
+	case lexer.LParen:
+		start := p.lexer.Location()
+		p.lexer.Next()
+
+		switch p.lexer.Current {
+		case lexer.LParen, lexer.FunctionStart:
+			c := &ast.SupportsInParens{
+				Loc:       start,
+				Condition: p.parseSupportsCondition(),
+			}
+			p.lexer.Expect(lexer.RParen)
+			return c
+
+		case lexer.Ident:
+			if p.lexer.CurrentString == "not" {
+				c := &ast.SupportsInParens{
+					Loc:       start,
+					Condition: p.parseSupportsCondition(),
+				}
+				p.lexer.Expect(lexer.RParen)
+				return c
+			}
+
+			decl := &ast.Declaration{
+				Loc:      p.lexer.Location(),
+				Property: p.lexer.CurrentString,
+			}
+			p.lexer.Next()
+
+			if p.lexer.Current != lexer.Colon {
+				return p.parseSupportsGeneralEnclosed(start)
+			}
+			p.lexer.Next()
+			p.parseDeclarationValues(decl)
+			p.lexer.Expect(lexer.RParen)
+
+			return &ast.SupportsDeclaration{
+				Loc:         start,
+				Declaration: decl,
+			}
+		}
+
+		return p.parseSupportsGeneralEnclosed(start)
+
+	case lexer.FunctionStart:
+		start := p.lexer.Location()
+		if p.lexer.CurrentString != "selector" {
+			p.lexer.Next()
+			return p.parseSupportsGeneralEnclosed(start)
+		}
+		p.lexer.Next()
+
+		s := &ast.SupportsSelector{
+			Loc:      start,
+			Selector: p.parseSelector(),
+		}
+		p.lexer.Expect(lexer.RParen)
+		return s
+
+	default:
+		p.lexer.Errorf("unexpected token: %s, expected @supports condition", p.lexer.Current.String())
+		return nil
+	}
+}
+
+// parseSupportsGeneralEnclosed consumes tokens until the parenthesis opened at start is
+// closed and keeps the raw source text. The opening token must already be consumed.
+func (p *parser) parseSupportsGeneralEnclosed(start ast.Loc) *ast.SupportsGeneralEnclosed {
+	depth := 1
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			p.lexer.Errorf("unexpected EOF")
+
+		case lexer.LParen, lexer.FunctionStart:
+			depth++
+
+		case lexer.RParen:
+			depth--
+			if depth == 0 {
+				_, end := p.lexer.Range()
+				p.lexer.Next()
+				return &ast.SupportsGeneralEnclosed{
+					Loc:  start,
+					Text: p.source.Content[start.Position:end],
+				}
+			}
+		}
+
+		p.lexer.Next()
+	}
+}
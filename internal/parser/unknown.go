@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/lexer"
+)
+
+// ruleBlockAtRules is the set of at-rules we don't otherwise understand,
+// but that are known to contain a list of rules.
+var ruleBlockAtRules = map[string]struct{}{
+	"document":       struct{}{},
+	"-moz-document":  struct{}{},
+	"layer":          struct{}{},
+	"container":      struct{}{},
+	"scope":          struct{}{},
+	"starting-style": struct{}{},
+}
+
+// declarationBlockAtRules is the set of at-rules we don't otherwise understand,
+// but that are known to contain a list of declarations.
+var declarationBlockAtRules = map[string]struct{}{
+	"property":            struct{}{},
+	"counter-style":       struct{}{},
+	"font-palette-values": struct{}{},
+	"viewport":            struct{}{},
+	"-ms-viewport":        struct{}{},
+}
+
+// parseUnknownAtRule parses any at-rule that we don't have specific handling
+// for. The prelude is kept as raw tokens. The block is parsed if the at-rule is
+// known to contain rules or declarations, and is otherwise kept as raw tokens.
+// See: https://www.w3.org/TR/css-syntax-3/#consume-at-rule.
+func (p *parser) parseUnknownAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Loc:  p.lexer.Location(),
+		Name: p.lexer.CurrentString,
+	}
+
+	prelude := &ast.RawPrelude{}
+	prelude.Tokens, prelude.Loc = p.parseRawTokens(false)
+	if len(prelude.Tokens) > 0 {
+		r.Preludes = []ast.AtPrelude{prelude}
+	}
+
+	switch p.lexer.Current {
+	case lexer.Semicolon:
+		p.lexer.Next()
+		return r
+
+	case lexer.LCurly:
+
+	default:
+		// EOF or the end of an enclosing block.
+		return r
+	}
+
+	if _, ok := ruleBlockAtRules[r.Name]; ok {
+		r.Block = p.parseQualifiedRuleBlock()
+		return r
+	}
+
+	if _, ok := declarationBlockAtRules[r.Name]; ok {
+		r.Block = p.parseDeclarationBlock()
+		return r
+	}
+
+	block := &ast.RawBlock{}
+	block.Tokens, block.Loc = p.parseRawTokens(true)
+	p.lexer.Expect(lexer.RCurly)
+	r.Block = block
+
+	return r
+}
+
+// parseRawTokens consumes the next token, then keeps every token until a matching
+// close token at the top-level. If inBlock is set, it stops at the closing } of the
+// current block. Otherwise, it stops at a ; or {, or the closing } of an enclosing
+// block. The stopping token is not consumed. Leading and trailing whitespace is dropped.
+func (p *parser) parseRawTokens(inBlock bool) ([]ast.RawToken, ast.Loc) {
+	prevRetainWhitespace := p.lexer.RetainWhitespace
+	p.lexer.RetainWhitespace = true
+	defer func() {
+		p.lexer.RetainWhitespace = prevRetainWhitespace
+	}()
+	p.lexer.Next()
+
+	var tokens []ast.RawToken
+	start := p.lexer.Location()
+	var stack []lexer.Token
+
+loop:
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			if inBlock {
+				p.lexer.Errorf("unexpected EOF")
+			}
+			break loop
+
+		case lexer.Semicolon, lexer.LCurly:
+			if len(stack) == 0 && !inBlock {
+				break loop
+			}
+
+			if p.lexer.Current == lexer.LCurly {
+				stack = append(stack, lexer.RCurly)
+			}
+
+		case lexer.LParen, lexer.FunctionStart:
+			stack = append(stack, lexer.RParen)
+
+		case lexer.LBracket:
+			stack = append(stack, lexer.RBracket)
+
+		case lexer.RParen, lexer.RBracket, lexer.RCurly:
+			if len(stack) == 0 {
+				if p.lexer.Current == lexer.RCurly {
+					break loop
+				}
+
+				p.lexer.Errorf("unexpected token: %s", p.lexer.Current.String())
+			}
+
+			if stack[len(stack)-1] != p.lexer.Current {
+				p.lexer.Errorf("unexpected token: %s, expected %s", p.lexer.Current.String(), stack[len(stack)-1].String())
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		token := ast.RawToken{
+			Loc: p.lexer.Location(),
+		}
+
+		if p.lexer.Current == lexer.Whitespace {
+			token.Text = " "
+		} else {
+			start, end := p.lexer.Range()
+			token.Text = p.source.Content[start:end]
+		}
+
+		tokens = append(tokens, token)
+		p.lexer.Next()
+	}
+
+	p.lexer.RetainWhitespace = prevRetainWhitespace
+	if p.lexer.Current == lexer.Whitespace {
+		p.lexer.Next()
+	}
+
+	for len(tokens) > 0 && tokens[0].Text == " " {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1].Text == " " {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	return tokens, start
+}
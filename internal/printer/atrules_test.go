@@ -0,0 +1,65 @@
+package printer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RoundTrip asserts that printing s gives expected, and that printing
+// the output again gives the same result.
+func RoundTrip(t testing.TB, expected, s string) {
+	out := Print(t, s)
+	assert.Equal(t, expected, out)
+	assert.Equal(t, expected, Print(t, out), "output should be stable when reprinted")
+}
+
+func TestFontFace(t *testing.T) {
+	RoundTrip(t, `@font-face{font-family:"Open Sans";src:url(/fonts/OpenSans.woff2) format("woff2"),url("/fonts/OpenSans.woff") format("woff");font-display:swap}`,
+		`@font-face {
+	font-family: "Open Sans";
+	src: url(/fonts/OpenSans.woff2) format("woff2"),
+		url("/fonts/OpenSans.woff") format("woff");
+	font-display: swap;
+}`)
+}
+
+func TestPage(t *testing.T) {
+	RoundTrip(t, `@page{margin:1in}`, `@page { margin: 1in; }`)
+	RoundTrip(t, `@page :first{margin-top:2in}`, `@page :first { margin-top: 2in; }`)
+	RoundTrip(t, `@page toc,index:blank:left{size:A4}`, `@page toc, index:blank:left { size: A4 }`)
+}
+
+func TestPage_MarginRules(t *testing.T) {
+	RoundTrip(t, `@page :left{margin:1in;@top-left{content:"Chapter"}@bottom-right-corner{content:counter(page)}}`,
+		`@page :left {
+	margin: 1in;
+
+	@top-left {
+		content: "Chapter";
+	}
+
+	@bottom-right-corner { content: counter(page) }
+}`)
+
+	RoundTrip(t, `@page{@top-center{content:none}}`, `@page { @top-center { content: none } }`)
+}
+
+func TestNamespace(t *testing.T) {
+	RoundTrip(t, `@namespace url(http://www.w3.org/1999/xhtml);`, `@namespace url(http://www.w3.org/1999/xhtml);`)
+	RoundTrip(t, `@namespace svg "http://www.w3.org/2000/svg";`, `@namespace svg url("http://www.w3.org/2000/svg");`)
+	RoundTrip(t, `@namespace svg "http://www.w3.org/2000/svg";`, `@namespace svg "http://www.w3.org/2000/svg";`)
+}
+
+func TestCharset(t *testing.T) {
+	RoundTrip(t, `@charset "UTF-8";.a{color:red}`, `@charset "UTF-8";
+.a { color: red }`)
+}
+
+func TestImport_Conditions(t *testing.T) {
+	RoundTrip(t, `@import "a.css" screen,print;`, `@import "a.css" screen, print;`)
+	RoundTrip(t, `@import "a.css" layer;`, `@import "a.css" layer;`)
+	RoundTrip(t, `@import "a.css" layer(framework.base) supports(display:grid) screen;`,
+		`@import url("a.css") layer(framework.base) supports(display: grid) screen;`)
+	RoundTrip(t, `@import "a.css" supports(not (display:grid));`, `@import "a.css" supports(not (display: grid));`)
+}
@@ -0,0 +1,106 @@
+package printer
+
+import "strings"
+
+// minifyNumber removes leading and trailing zeros from a number, e.g.
+// 0.50 becomes .5 and 1.0 becomes 1.
+func minifyNumber(in string) string {
+	// Leave scientific notation alone.
+	if strings.ContainsAny(in, "eE") || !strings.Contains(in, ".") {
+		return in
+	}
+
+	var sign string
+	if len(in) > 0 && (in[0] == '-' || in[0] == '+') {
+		sign, in = in[:1], in[1:]
+	}
+
+	in = strings.TrimRight(in, "0")
+	in = strings.TrimSuffix(in, ".")
+	in = strings.TrimLeft(in, "0")
+
+	if in == "" {
+		return "0"
+	}
+
+	return sign + in
+}
+
+// minifyHexColor shortens a hex color from 6 or 8 digits to 3 or 4
+// digits when each channel is a repeated digit, e.g. #aabbcc becomes #abc.
+func minifyHexColor(in string) string {
+	if len(in) != 6 && len(in) != 8 {
+		return in
+	}
+
+	short := make([]byte, 0, len(in)/2)
+	for i := 0; i < len(in); i += 2 {
+		if toLower(in[i]) != toLower(in[i+1]) {
+			return in
+		}
+		short = append(short, in[i])
+	}
+
+	return string(short)
+}
+
+func toLower(ch byte) byte {
+	if ch >= 'A' && ch <= 'Z' {
+		return ch + ('a' - 'A')
+	}
+	return ch
+}
+
+// isSafeIdent returns whether or not in can be printed as an identifier
+// without quotes. It is conservative and does not allow escapes.
+// See: https://www.w3.org/TR/css-syntax-3/#ident-token-diagram.
+func isSafeIdent(in string) bool {
+	if in == "" || in == "-" || strings.HasPrefix(in, "--") {
+		return false
+	}
+
+	for i, ch := range in {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch == '_', ch >= 0x80:
+		case ch == '-':
+			if i != 0 {
+				break
+			}
+
+			next := in[1]
+			if next >= '0' && next <= '9' {
+				return false
+			}
+		case ch >= '0' && ch <= '9':
+			if i == 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// isSafeURL returns whether or not in can be printed inside of url()
+// without quotes.
+// See: https://www.w3.org/TR/css-syntax-3/#consume-url-token.
+func isSafeURL(in string) bool {
+	if in == "" {
+		return false
+	}
+
+	for _, ch := range in {
+		switch ch {
+		case ' ', '\t', '\n', '\r', '\f', '"', '\'', '(', ')', '\\':
+			return false
+		}
+
+		if ch < 0x20 || ch == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,65 @@
+package printer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func PrintMinified(t testing.TB, s string) string {
+	ss, err := parser.Parse(&sources.Source{
+		Path:    "main.css",
+		Content: s,
+	})
+	require.NoError(t, err)
+
+	out, err := printer.Print(ss, printer.Options{Minify: true})
+	require.NoError(t, err)
+
+	return out
+}
+
+func TestMinify_Comments(t *testing.T) {
+	assert.Equal(t, `/*! license */.a{color:red}`,
+		PrintMinified(t, `/*! license */ /* dropped */ .a { color: red }`))
+
+	assert.Equal(t, `.a .b{margin:1px 2px;font:a(b,c)}`,
+		PrintMinified(t, `.a /* dropped */ .b /* dropped */ { margin: 1px /* dropped */ 2px; font: a(b, /* dropped */ c) }`))
+	assert.Equal(t, `.a{width:calc(1px + 2px)}`,
+		PrintMinified(t, `.a { width: calc(1px /* dropped */ + /* dropped */ 2px) }`))
+}
+
+func TestMinify_Numbers(t *testing.T) {
+	assert.Equal(t, `.a{margin:.5rem -.25em 1px 0;opacity:.5;width:10%;line-height:1.5}`,
+		PrintMinified(t, `.a { margin: 0.50rem -0.250em 1.0px 0.0; opacity: .5; width: 10.00%; line-height: 1.5 }`))
+
+	assert.Equal(t, `.a{margin:0.50rem}`, Print(t, `.a { margin: 0.50rem }`))
+}
+
+func TestMinify_HexColors(t *testing.T) {
+	assert.Equal(t, `.a{color:#abc;background:#aabbcd;border-color:#fFf0}`,
+		PrintMinified(t, `.a { color: #aabbcc; background: #aabbcd; border-color: #ffFFff00 }`))
+}
+
+func TestMinify_Quotes(t *testing.T) {
+	assert.Equal(t, `.a{background:url(a.png)}.b{background:url("a b.png")}`,
+		PrintMinified(t, `.a { background: url("a.png") } .b { background: url("a b.png") }`))
+
+	assert.Equal(t, `[type=text]{}[data-x="1a"]{}`,
+		PrintMinified(t, `[type="text"] {} [data-x="1a"] {}`))
+}
+
+func TestMinify_UnknownAtRules(t *testing.T) {
+	assert.Equal(t, `@foo bar{baz qux}`,
+		PrintMinified(t, `@foo /* x */ bar { baz /* y */ qux }`))
+	assert.Equal(t, `@foo a,b{c{d;e}}`,
+		PrintMinified(t, `@foo a , /* x */ b { c { d ; e } }`))
+	assert.Equal(t, `@foo /*! keep */ bar;`,
+		PrintMinified(t, `@foo /*! keep */ bar;`))
+
+	assert.Equal(t, `@foo /* x */ bar{baz /* y */ qux}`, Print(t, `@foo /* x */ bar { baz /* y */ qux }`))
+}
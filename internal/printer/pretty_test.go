@@ -0,0 +1,113 @@
+package printer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func PrintPretty(t testing.TB, s string, indent int) string {
+	ss, err := parser.Parse(&sources.Source{
+		Path:    "main.css",
+		Content: s,
+	})
+	require.NoError(t, err)
+
+	out, err := printer.Print(ss, printer.Options{Pretty: true, Indent: indent})
+	require.NoError(t, err)
+
+	return out
+}
+
+func TestPretty(t *testing.T) {
+	assert.Equal(t, `@import "a.css";
+@import "b.css" screen, print;
+
+.a,
+.b > .c {
+  font: 12px "Helvetica", sans-serif !important;
+  width: calc(1px + 2px);
+}
+
+.empty {}
+`, PrintPretty(t, `@import "a.css";@import "b.css" screen,print;.a,.b > .c{font:12px "Helvetica",sans-serif!important;width:calc(1px + 2px)}.empty{}`, 0))
+}
+
+func TestPretty_Nested(t *testing.T) {
+	assert.Equal(t, `@media (min-width: 100px) {
+    @supports (display: grid) {
+        .a {
+            display: grid;
+        }
+    }
+}
+
+@keyframes x {
+    0%, 50% {
+        opacity: 0;
+    }
+    to {
+        opacity: 1;
+    }
+}
+`, PrintPretty(t, `@media (min-width: 100px) { @supports (display: grid) { .a { display: grid } } }
+@keyframes x { 0%, 50% { opacity: 0 } to { opacity: 1 } }`, 4))
+}
+
+func TestPretty_Comments(t *testing.T) {
+	assert.Equal(t, `/* header */
+.a {
+  /* before color */
+  color: red;
+  width: /* kept */ 1px;
+  /* trailing */
+}
+
+/* between */
+.b /* in selector */ > .c {
+  color: blue;
+}
+`, PrintPretty(t, `/* header */
+.a { /* before color */ color: red; width: /* kept */ 1px; /* trailing */ }
+/* between */
+.b /* in selector */ > .c { color: blue }`, 0))
+}
+
+func TestPretty_CommentsOrder(t *testing.T) {
+	assert.Equal(t, `.a {
+  /* before nested */
+  .b {
+    color: red;
+  }
+  color: blue;
+  /* after blue */
+}
+`, PrintPretty(t, `.a { /* before nested */ .b { color: red } color: blue; /* after blue */ }`, 0))
+}
+
+func TestPretty_UnknownAtRules(t *testing.T) {
+	assert.Equal(t, `@foo bar {
+  baz { qux: 1px }
+}
+
+@media print {
+  @foo {
+    baz
+  }
+  @empty {}
+}
+`, PrintPretty(t, `@foo bar{baz { qux: 1px }}@media print{@foo{baz}@empty{}}`, 0))
+}
+
+func TestPretty_Idempotent(t *testing.T) {
+	in := `.a { color: red; &:hover { color: blue } @media print { color: black } }
+@page :first { margin: 1in; @top-left { content: "x" } }
+@foo bar { baz }`
+
+	once := PrintPretty(t, in, 0)
+	assert.Equal(t, once, PrintPretty(t, once, 0))
+}
@@ -1,13 +1,15 @@
 package printer
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"strings"
 
-	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/ast"
 	"github.com/stephen/cssc/internal/sources"
 )
 
@@ -15,29 +17,109 @@ type printer struct {
 	options Options
 	s       strings.Builder
 
-	sourceMappings   strings.Builder
+	// pretty is set if the output should be pretty printed, and depth
+	// is the current level of indentation.
+	pretty bool
+	depth  int
+
+	sourceMappings   bytes.Buffer
 	lastWritten      int
 	lastMappingState mappingState
+
+	// lastSegment is the offset in sourceMappings of the last segment, and
+	// prevMappingState is the state before it was written. They are used to
+	// replace the last segment if the next one is at the same generated position.
+	lastSegment      int
+	prevMappingState mappingState
+
+	// names is the list of names in the source map, and nameIndexes maps
+	// each name to its index in names.
+	names       []string
+	nameIndexes map[string]int32
+
+	// mappedSources is the list of sources in the source map, and sourceIndexes
+	// maps each source to its index in mappedSources. noContent is the set of
+	// sources from input source maps that did not include their content.
+	mappedSources []*sources.Source
+	sourceIndexes map[*sources.Source]int32
+	noContent     map[*sources.Source]struct{}
 }
 
 type mappingState struct {
 	generatedColumn int32
+	sourceIndex     int32
 	originalLine    int32
 	originalColumn  int32
+	nameIndex       int32
 }
 
 // Options is a set of options for printing.
 type Options struct {
 	OriginalSource *sources.Source
+
+	// Minify shortens the output where it is safe to do so. Comments are dropped
+	// unless they start with /*!, numbers lose leading and trailing zeros, hex colors
+	// are shortened, and quotes are dropped from url() and attribute selector values.
+	Minify bool
+
+	// Pretty prints the output with each rule and declaration on its own line,
+	// similar to a formatter. It is ignored if Minify is set.
+	Pretty bool
+
+	// Indent is the number of spaces to indent each level by when pretty printing.
+	// If it is zero, two spaces are used.
+	Indent int
+
+	// Sources maps the source index of each location to its source, for output
+	// that contains nodes from more than one source, e.g. from inlined imports. If
+	// it is nil, every location is in OriginalSource.
+	Sources map[int]*sources.Source
+
+	// SourceMapFile is the path of the output file. If it is set, it is used as the
+	// source map's file, and the paths of sources are written relative to it.
+	SourceMapFile string
+
+	// SourceRoot is the source map's sourceRoot, which is prepended to the
+	// paths of sources by source map consumers.
+	SourceRoot string
+
+	// ExcludeSourcesContent leaves the content of the sources out of the source map.
+	ExcludeSourcesContent bool
+
+	// Names maps nodes to their original names, e.g. for renamed classes or the
+	// values substituted for a var(). They are added to the source map's names.
+	Names map[ast.Node]string
+
+	// InputSourceMaps maps the index of a source to its source map, if it was
+	// generated by another tool. Mappings into the source are composed with it.
+	InputSourceMaps map[int]*InputSourceMap
 }
 
 // Print prints the input AST node into CSS. It should have deterministic
-// output.
+// output. If opts.OriginalSource is set, a source map is added to the
+// end of the output as a comment.
 func Print(in ast.Node, opts Options) (output string, err error) {
+	output, sourceMap, err := PrintWithSourceMap(in, opts)
+	if err != nil || opts.OriginalSource == nil {
+		return output, err
+	}
+
+	var b strings.Builder
+	b.WriteString(output)
+	b.WriteString("\n/*# sourceMappingURL=data:application/json;base64,")
+	// XXX: allocation.
+	b.WriteString(base64.StdEncoding.EncodeToString([]byte(sourceMap)))
+	b.WriteString(" */\n")
+	return b.String(), nil
+}
+
+// PrintWithSourceMap is like Print, but returns the source map separately instead
+// of adding it to the output. sourceMap is empty if opts.OriginalSource is not set.
+func PrintWithSourceMap(in ast.Node, opts Options) (output, sourceMap string, err error) {
 	defer func() {
 		if rErr := recover(); rErr != nil {
 			if errI, ok := rErr.(error); ok {
-				output, err = "", errI
+				output, sourceMap, err = "", "", errI
 				return
 			}
 
@@ -48,49 +130,168 @@ func Print(in ast.Node, opts Options) (output string, err error) {
 
 	p := printer{
 		options: opts,
+		pretty:  opts.Pretty && !opts.Minify,
+	}
+
+	if p.options.Indent == 0 {
+		p.options.Indent = 2
+	}
+
+	if opts.OriginalSource != nil {
+		p.sourceIndexes = make(map[*sources.Source]int32)
+		if _, ok := opts.InputSourceMaps[opts.OriginalSource.Index]; !ok {
+			p.sourceIndexes[opts.OriginalSource] = 0
+			p.mappedSources = []*sources.Source{opts.OriginalSource}
+		}
 	}
 
 	p.print(in)
-	p.printMapping()
+	if p.pretty && p.s.Len() > 0 {
+		p.s.WriteRune('\n')
+	}
+
+	return p.s.String(), p.sourceMap(), nil
+}
 
-	return p.s.String(), nil
+// sourceMapJSON is the json structure of a source map.
+// See: https://sourcemaps.info/spec.html.
+type sourceMapJSON struct {
+	Version        int       `json:"version"`
+	File           string    `json:"file"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent,omitempty"`
+	Names          []string  `json:"names"`
+	Mappings       string    `json:"mappings"`
 }
 
-func (p *printer) printMapping() {
+// sourceMap returns the source map for the printed output as json.
+func (p *printer) sourceMap() string {
 	if p.options.OriginalSource == nil {
-		return
+		return ""
+	}
+
+	m := sourceMapJSON{
+		Version:    3,
+		File:       p.options.OriginalSource.Path,
+		SourceRoot: p.options.SourceRoot,
+		Sources:    make([]string, 0, len(p.mappedSources)),
+		Names:      p.names,
+		Mappings:   p.sourceMappings.String(),
+	}
+
+	if p.options.SourceMapFile != "" {
+		m.File = filepath.Base(p.options.SourceMapFile)
+	}
+
+	if m.Names == nil {
+		m.Names = []string{}
 	}
 
-	b := strings.Builder{}
-	b.WriteString(`{"version": 3,"file":"`)
-	b.WriteString(p.options.OriginalSource.Path)
-	// XXX: sources content and names.
-	b.WriteString(`","sourceRoot":"", "sources": ["source.css"], "sourcesContent":[`)
-	out, err := json.Marshal(p.options.OriginalSource.Content)
+	for _, source := range p.mappedSources {
+		m.Sources = append(m.Sources, p.sourceMapPath(source))
+
+		if !p.options.ExcludeSourcesContent {
+			if _, ok := p.noContent[source]; ok {
+				m.SourcesContent = append(m.SourcesContent, nil)
+				continue
+			}
+
+			content := source.Content
+			m.SourcesContent = append(m.SourcesContent, &content)
+		}
+	}
+
+	out, err := json.Marshal(m)
 	if err != nil {
 		panic(err)
 	}
-	b.Write(out)
-	b.WriteString(`],"names":[],"mappings":"`)
-	b.WriteString(p.sourceMappings.String())
-	b.WriteString(`"}`)
-	p.s.WriteString("\n/*# sourceMappingURL=data:application/json;base64,")
-	// XXX: allocation.
-	p.s.WriteString(base64.StdEncoding.EncodeToString([]byte(b.String())))
-	p.s.WriteString(" */\n")
+
+	return string(out)
+}
+
+// sourceMapPath returns the path to write in the source map for a source.
+func (p *printer) sourceMapPath(source *sources.Source) string {
+	if p.options.SourceMapFile == "" || isURL(source.Path) {
+		return filepath.ToSlash(source.Path)
+	}
+
+	dir := filepath.Dir(p.options.SourceMapFile)
+	if filepath.IsAbs(source.Path) && !filepath.IsAbs(dir) {
+		// Output paths on disk may be relative to the working directory.
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+	}
+
+	rel, err := filepath.Rel(dir, source.Path)
+	if err != nil {
+		return filepath.ToSlash(source.Path)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// source returns the source of a location.
+func (p *printer) source(loc ast.Loc) *sources.Source {
+	if source, ok := p.options.Sources[loc.Source]; ok {
+		return source
+	}
+
+	return p.options.OriginalSource
 }
 
 // addMapping should be called from the printer
 // when a new symbol needs to be added to the sourcemap.
-func (p *printer) addMapping(loc ast.Loc) {
+func (p *printer) addMapping(node ast.Node) {
 	if p.options.OriginalSource == nil {
 		return
 	}
 
+	loc := node.Location()
+	source := p.source(loc)
+	line, col := source.LineAndCol(loc)
+	line, col = line-1, col-1
+
+	name, hasName := p.options.Names[node]
+	if m, ok := p.options.InputSourceMaps[source.Index]; ok {
+		segment, ok := m.find(line, col)
+		if !ok {
+			return
+		}
+
+		source = m.Sources[segment.source]
+		line, col = segment.originalLine, segment.originalColumn
+		if !hasName && segment.name >= 0 {
+			name, hasName = m.Names[segment.name], true
+		}
+
+		if !m.hasContent[segment.source] {
+			if p.noContent == nil {
+				p.noContent = make(map[*sources.Source]struct{})
+			}
+			p.noContent[source] = struct{}{}
+		}
+	}
+
 	newState := p.lastMappingState
 
-	line, col := p.options.OriginalSource.LineAndCol(loc)
-	newState.originalLine, newState.originalColumn = line-1, col-1
+	// If nothing has been printed since the last mapping, e.g. for a rule
+	// and its first selector, replace it with the more specific one.
+	if p.s.Len() == p.lastWritten && p.sourceMappings.Len() > p.lastSegment {
+		p.sourceMappings.Truncate(p.lastSegment)
+		p.lastMappingState = p.prevMappingState
+		newState.nameIndex = p.lastMappingState.nameIndex
+	}
+
+	index, ok := p.sourceIndexes[source]
+	if !ok {
+		index = int32(len(p.mappedSources))
+		p.sourceIndexes[source] = index
+		p.mappedSources = append(p.mappedSources, source)
+	}
+	newState.sourceIndex = index
+	newState.originalLine, newState.originalColumn = line, col
 
 	// Note that String() here does not reallocate the string.
 	for _, ch := range p.s.String()[p.lastWritten:] {
@@ -104,35 +305,198 @@ func (p *printer) addMapping(loc ast.Loc) {
 		newState.generatedColumn++
 	}
 
-	if p.s.Len() > 0 {
-		lastByte := p.sourceMappings.String()[p.sourceMappings.Len()-1]
+	p.lastSegment = p.sourceMappings.Len()
+	p.prevMappingState = p.lastMappingState
+
+	if p.sourceMappings.Len() > 0 {
+		lastByte := p.sourceMappings.Bytes()[p.sourceMappings.Len()-1]
 		if lastByte != ';' {
 			p.sourceMappings.WriteRune(',')
 		}
 	}
 
 	p.sourceMappings.Write(VLQEncode(newState.generatedColumn - p.lastMappingState.generatedColumn))
-	// XXX: figure out what to do for multiple sources.
-	p.sourceMappings.Write(VLQEncode(0))
+	p.sourceMappings.Write(VLQEncode(newState.sourceIndex - p.lastMappingState.sourceIndex))
 
 	p.sourceMappings.Write(VLQEncode(newState.originalLine - p.lastMappingState.originalLine))
 	p.sourceMappings.Write(VLQEncode(newState.originalColumn - p.lastMappingState.originalColumn))
-	// XXX: 5th item for "names" mapping
+
+	if hasName {
+		newState.nameIndex = p.nameIndex(name)
+		p.sourceMappings.Write(VLQEncode(newState.nameIndex - p.lastMappingState.nameIndex))
+	}
 
 	p.lastMappingState = newState
 	p.lastWritten = p.s.Len()
 }
 
+// nameIndex returns the index of name in the source map's names, adding it if needed.
+func (p *printer) nameIndex(name string) int32 {
+	if index, ok := p.nameIndexes[name]; ok {
+		return index
+	}
+
+	if p.nameIndexes == nil {
+		p.nameIndexes = make(map[string]int32)
+	}
+
+	index := int32(len(p.names))
+	p.nameIndexes[name] = index
+	p.names = append(p.names, name)
+	return index
+}
+
+// printValues prints a list of values, separated by spaces unless they are
+// separated by a comma.
+func (p *printer) printValues(values []ast.Value) {
+	if p.options.Minify {
+		values = withoutComments(values)
+	}
+
+	for i, val := range values {
+		p.printValue(val)
+
+		// Print space if we're not the last value and the previous or current
+		// value was not a comma.
+		if i+1 < len(values) {
+			if _, nextIsComma := values[i+1].(*ast.Comma); !nextIsComma {
+				if _, isComma := val.(*ast.Comma); !isComma {
+					p.s.WriteRune(' ')
+				}
+			}
+		}
+	}
+}
+
+// withoutComments returns values without the comments that are dropped when
+// minifying, so that they aren't separated by extra spaces.
+func withoutComments(values []ast.Value) []ast.Value {
+	rv := make([]ast.Value, 0, len(values))
+	for _, v := range values {
+		if c, ok := v.(*ast.Comment); ok && !strings.HasPrefix(c.Text, "!") {
+			continue
+		}
+		rv = append(rv, v)
+	}
+
+	return rv
+}
+
+// selectorParts returns the parts of a selector to print. When minifying, comments
+// are dropped along with the whitespace that separated them.
+func (p *printer) selectorParts(parts []ast.SelectorPart) []ast.SelectorPart {
+	if !p.options.Minify {
+		return parts
+	}
+
+	rv := make([]ast.SelectorPart, 0, len(parts))
+	for _, part := range parts {
+		if c, ok := part.(*ast.Comment); ok && !strings.HasPrefix(c.Text, "!") {
+			continue
+		}
+
+		if _, ok := part.(*ast.Whitespace); ok && len(rv) > 0 {
+			if _, prevOk := rv[len(rv)-1].(*ast.Whitespace); prevOk {
+				continue
+			}
+		}
+		rv = append(rv, part)
+	}
+
+	return rv
+}
+
+// rawTokens returns the raw tokens of an unknown at-rule to print. When minifying,
+// comments are dropped, along with whitespace that doesn't separate two tokens, e.g.
+// around braces and commas.
+func (p *printer) rawTokens(tokens []ast.RawToken) []ast.RawToken {
+	if !p.options.Minify {
+		return tokens
+	}
+
+	rv := make([]ast.RawToken, 0, len(tokens))
+	for _, t := range tokens {
+		if strings.HasPrefix(t.Text, "/*") && !strings.HasPrefix(t.Text, "/*!") {
+			continue
+		}
+
+		if t.Text == " " && (len(rv) == 0 || rv[len(rv)-1].Text == " " || isRawPunctuation(rv[len(rv)-1].Text)) {
+			continue
+		}
+
+		if isRawPunctuation(t.Text) && len(rv) > 0 && rv[len(rv)-1].Text == " " {
+			rv = rv[:len(rv)-1]
+		}
+		rv = append(rv, t)
+	}
+
+	if len(rv) > 0 && rv[len(rv)-1].Text == " " {
+		rv = rv[:len(rv)-1]
+	}
+
+	return rv
+}
+
+// isRawPunctuation returns whether or not a raw token never needs whitespace
+// around it.
+func isRawPunctuation(text string) bool {
+	switch text {
+	case "{", "}", ";", ",":
+		return true
+	}
+
+	return false
+}
+
+// printMathOperand prints an operand of a math expression, with parentheses if it
+// binds more loosely than the expression's operator, e.g. 2 * (1px + 1rem). The right
+// operand is also grouped if it has the same precedence, e.g. 1px - (2px - 1rem).
+func (p *printer) printMathOperand(expr *ast.MathExpression, operand ast.Value, right bool) {
+	inner, ok := operand.(*ast.MathExpression)
+	if !ok || !(precedence(inner.Operator) < precedence(expr.Operator) ||
+		(right && precedence(inner.Operator) == precedence(expr.Operator))) {
+		p.printValue(operand)
+		return
+	}
+
+	p.s.WriteRune('(')
+	p.printValue(operand)
+	p.s.WriteRune(')')
+}
+
+// precedence returns the precedence of a math operator.
+func precedence(operator string) int {
+	if operator == "*" || operator == "/" {
+		return 2
+	}
+
+	return 1
+}
+
+// printValue prints a value and adds a mapping for it.
+func (p *printer) printValue(v ast.Value) {
+	switch v.(type) {
+	case *ast.Comma:
+	default:
+		p.addMapping(v)
+	}
+
+	p.print(v)
+}
+
 // print prints the current ast node to the printer output.
 func (p *printer) print(in ast.Node) {
 	switch node := in.(type) {
 	case *ast.Stylesheet:
-		for _, n := range node.Nodes {
+		for i, n := range node.Nodes {
+			if i > 0 {
+				p.separateTopLevel(node.Nodes[i-1], n)
+			}
 			p.print(n)
 		}
 
 	case *ast.AtRule:
-		p.addMapping(node.Loc)
+		p.addMapping(node)
 		p.s.WriteRune('@')
 		p.s.WriteString(node.Name)
 		if len(node.Preludes) > 0 {
@@ -147,9 +511,7 @@ func (p *printer) print(in ast.Node) {
 		}
 
 		if node.Block != nil {
-			p.s.WriteRune('{')
-			p.print(node.Block)
-			p.s.WriteRune('}')
+			p.printBlock(node.Block)
 		} else {
 			p.s.WriteRune(';')
 		}
@@ -164,62 +526,84 @@ func (p *printer) print(in ast.Node) {
 		}
 
 	case *ast.KeyframeSelectorList:
-		for _, s := range node.Selectors {
+		for i, s := range node.Selectors {
 			p.print(s)
+
+			if i+1 < len(node.Selectors) {
+				p.s.WriteRune(',')
+				p.space()
+			}
 		}
 
 	case *ast.QualifiedRule:
-		p.addMapping(node.Location())
-		p.print(node.Prelude)
+		p.addMapping(node)
+		if selectors, ok := node.Prelude.(*ast.SelectorList); ok && p.pretty {
+			// Put each selector on its own line, like most formatters.
+			for i, s := range selectors.Selectors {
+				p.print(s)
+
+				if i+1 < len(selectors.Selectors) {
+					p.s.WriteRune(',')
+					p.newline()
+				}
+			}
+		} else {
+			p.print(node.Prelude)
+		}
 
-		p.s.WriteRune('{')
-		p.print(node.Block)
-		p.s.WriteRune('}')
+		p.printBlock(node.Block)
 
 	case *ast.QualifiedRuleBlock:
 		for _, r := range node.Rules {
+			p.newline()
 			p.print(r)
 		}
 
 	case *ast.DeclarationBlock:
-		for i, d := range node.Declarations {
+		nodes := node.Nodes()
+		for i, n := range nodes {
+			d, ok := n.(*ast.Declaration)
+			if !ok {
+				p.newline()
+				p.print(n)
+				continue
+			}
+
+			for _, c := range d.Comments {
+				p.newline()
+				p.print(c)
+			}
+
+			p.newline()
 			p.print(d)
 
-			if i+1 < len(node.Declarations) {
+			if p.pretty || i+1 < len(nodes) {
 				p.s.WriteRune(';')
 			}
 		}
 
 	case *ast.Declaration:
+		p.addMapping(node)
 		p.s.WriteString(node.Property)
 		p.s.WriteRune(':')
-		for i, val := range node.Values {
-			p.print(val)
-
-			// Print space if we're not the last value and the previous or current
-			// value was not a comma.
-			if i+1 < len(node.Values) {
-				if _, nextIsComma := node.Values[i+1].(*ast.Comma); !nextIsComma {
-					if _, isComma := val.(*ast.Comma); !isComma {
-						p.s.WriteRune(' ')
-					}
-				}
-			}
-		}
+		p.space()
+		p.printValues(node.Values)
 
 		if node.Important {
+			p.space()
 			p.s.WriteString("!important")
 		}
 
 	case *ast.Comma:
 		p.s.WriteRune(',')
+		p.space()
 
 	case *ast.Dimension:
-		p.s.WriteString(node.Value)
+		p.printNumber(node.Value)
 		p.s.WriteString(node.Unit)
 
 	case *ast.Percentage:
-		p.s.WriteString(node.Value)
+		p.printNumber(node.Value)
 		p.s.WriteRune('%')
 
 	case *ast.String:
@@ -230,33 +614,66 @@ func (p *printer) print(in ast.Node) {
 	case *ast.Identifier:
 		p.s.WriteString(node.Value)
 
+	case *ast.URL:
+		p.s.WriteString("url(")
+		p.s.WriteString(node.Value)
+		p.s.WriteRune(')')
+
 	case *ast.Function:
+		if p.options.Minify && node.Name == "url" && len(node.Arguments) == 1 {
+			if s, ok := node.Arguments[0].(*ast.String); ok && isSafeURL(s.Value) {
+				p.s.WriteString("url(")
+				p.s.WriteString(s.Value)
+				p.s.WriteRune(')')
+				break
+			}
+		}
+
 		p.s.WriteString(node.Name)
 		p.s.WriteRune('(')
-		for _, arg := range node.Arguments {
-			p.print(arg)
-		}
+		p.printValues(node.Arguments)
 		p.s.WriteRune(')')
 
 	case *ast.Comment:
+		if p.options.Minify && !strings.HasPrefix(node.Text, "!") {
+			break
+		}
+
 		p.s.WriteString("/*")
 		p.s.WriteString(node.Text)
 		p.s.WriteString("*/")
 
 	case *ast.MathExpression:
-		p.print(node.Left)
-		p.s.WriteString(node.Operator)
-		p.print(node.Right)
+		p.printMathOperand(node, node.Left, false)
+
+		// + and - must be surrounded by whitespace, even when minified.
+		// See: https://www.w3.org/TR/css-values-3/#calc-syntax.
+		if node.Operator == "+" || node.Operator == "-" {
+			p.s.WriteRune(' ')
+			p.s.WriteString(node.Operator)
+			p.s.WriteRune(' ')
+		} else {
+			p.space()
+			p.s.WriteString(node.Operator)
+			p.space()
+		}
+		p.printMathOperand(node, node.Right, true)
 
 	case *ast.Whitespace:
 		p.s.WriteRune(' ')
 
 	case *ast.Selector:
-		for i, part := range node.Parts {
-			if _, isWhitespace := part.(*ast.Whitespace); i+1 >= len(node.Parts) && isWhitespace {
+		p.addMapping(node)
+		parts := p.selectorParts(node.Parts)
+		for i, part := range parts {
+			_, isWhitespace := part.(*ast.Whitespace)
+			if i+1 >= len(parts) && isWhitespace {
 				continue
 			}
 
+			if !isWhitespace {
+				p.addMapping(part)
+			}
 			p.print(part)
 		}
 
@@ -265,7 +682,11 @@ func (p *printer) print(in ast.Node) {
 		p.s.WriteString(node.Property)
 		if node.Value != nil {
 			p.s.WriteRune('=')
-			p.print(node.Value)
+			if s, ok := node.Value.(*ast.String); ok && p.options.Minify && isSafeIdent(s.Value) {
+				p.s.WriteString(s.Value)
+			} else {
+				p.print(node.Value)
+			}
 		}
 		p.s.WriteRune(']')
 
@@ -283,12 +704,19 @@ func (p *printer) print(in ast.Node) {
 	case *ast.CombinatorSelector:
 		p.s.WriteString(node.Operator)
 
+	case *ast.NestingSelector:
+		p.s.WriteRune('&')
+
 	case *ast.PseudoElementSelector:
 		p.s.WriteRune(':')
 		p.print(node.Inner)
 
 	case *ast.HexColor:
 		p.s.WriteRune('#')
+		if p.options.Minify {
+			p.s.WriteString(minifyHexColor(node.RGBA))
+			break
+		}
 		p.s.WriteString(node.RGBA)
 
 	case *ast.PseudoClassSelector:
@@ -316,6 +744,7 @@ func (p *printer) print(in ast.Node) {
 
 			if i+1 < len(node.Queries) {
 				p.s.WriteRune(',')
+				p.space()
 			}
 		}
 
@@ -333,6 +762,7 @@ func (p *printer) print(in ast.Node) {
 		p.print(node.Property)
 		if node.Value != nil {
 			p.s.WriteRune(':')
+			p.space()
 			p.print(node.Value)
 		}
 		p.s.WriteRune(')')
@@ -350,8 +780,156 @@ func (p *printer) print(in ast.Node) {
 		}
 		p.s.WriteRune(')')
 
+	case *ast.RawPrelude:
+		for _, t := range p.rawTokens(node.Tokens) {
+			p.s.WriteString(t.Text)
+		}
+
+	case *ast.RawBlock:
+		tokens := p.rawTokens(node.Tokens)
+
+		// The tokens are kept on one line, since their structure isn't known.
+		if len(tokens) > 0 {
+			p.newline()
+		}
+		for _, t := range tokens {
+			p.s.WriteString(t.Text)
+		}
+
+	case *ast.PageSelectorList:
+		for i, s := range node.Selectors {
+			p.s.WriteString(s.Name)
+			for _, pseudo := range s.PseudoClasses {
+				p.s.WriteRune(':')
+				p.s.WriteString(pseudo)
+			}
+
+			if i+1 < len(node.Selectors) {
+				p.s.WriteRune(',')
+			}
+		}
+
+	case *ast.SupportsNot:
+		p.s.WriteString("not ")
+		p.print(node.Condition)
+
+	case *ast.SupportsCombination:
+		for i, c := range node.Conditions {
+			p.print(c)
+
+			if i+1 < len(node.Conditions) {
+				p.s.WriteRune(' ')
+				p.s.WriteString(node.Operator)
+				p.s.WriteRune(' ')
+			}
+		}
+
+	case *ast.SupportsInParens:
+		p.s.WriteRune('(')
+		p.print(node.Condition)
+		p.s.WriteRune(')')
+
+	case *ast.SupportsDeclaration:
+		p.s.WriteRune('(')
+		p.print(node.Declaration)
+		p.s.WriteRune(')')
+
+	case *ast.SupportsSelector:
+		p.s.WriteString("selector(")
+		p.print(node.Selector)
+		p.s.WriteRune(')')
+
+	case *ast.ImportLayer:
+		p.s.WriteString("layer")
+		if node.Name != "" {
+			p.s.WriteRune('(')
+			p.s.WriteString(node.Name)
+			p.s.WriteRune(')')
+		}
+
+	case *ast.ImportSupports:
+		p.s.WriteString("supports(")
+		if decl, ok := node.Condition.(*ast.SupportsDeclaration); ok {
+			p.print(decl.Declaration)
+		} else {
+			p.print(node.Condition)
+		}
+		p.s.WriteRune(')')
+
+	case *ast.SupportsGeneralEnclosed:
+		p.s.WriteString(node.Text)
+
 	default:
 		panic(fmt.Sprintf("unknown ast node: %s", reflect.TypeOf(in).String()))
 	}
 
 }
+
+// printNumber prints the string representation of a number.
+func (p *printer) printNumber(value string) {
+	if p.options.Minify {
+		p.s.WriteString(minifyNumber(value))
+		return
+	}
+
+	p.s.WriteString(value)
+}
+
+// printBlock prints a block of an at-rule or qualified rule, including
+// its curly braces.
+func (p *printer) printBlock(block ast.Node) {
+	p.space()
+	p.s.WriteRune('{')
+
+	p.depth++
+	start := p.s.Len()
+	p.print(block)
+	p.depth--
+
+	// Keep empty blocks on one line.
+	if p.s.Len() > start {
+		p.newline()
+	}
+	p.s.WriteRune('}')
+}
+
+// newline starts a new, indented line when pretty printing.
+func (p *printer) newline() {
+	if !p.pretty {
+		return
+	}
+
+	p.s.WriteRune('\n')
+	for i := 0; i < p.depth*p.options.Indent; i++ {
+		p.s.WriteRune(' ')
+	}
+}
+
+// space prints a space when pretty printing.
+func (p *printer) space() {
+	if p.pretty {
+		p.s.WriteRune(' ')
+	}
+}
+
+// separateTopLevel separates two top-level nodes when pretty printing. Rules
+// are separated by a blank line, except after a comment or between at-rules
+// without blocks, e.g. a list of @imports.
+func (p *printer) separateTopLevel(prev, next ast.Node) {
+	if !p.pretty {
+		return
+	}
+
+	p.newline()
+	if _, ok := prev.(*ast.Comment); ok {
+		return
+	}
+
+	prevAtRule, prevOk := prev.(*ast.AtRule)
+	nextAtRule, nextOk := next.(*ast.AtRule)
+	if prevOk && nextOk && prevAtRule.Block == nil && nextAtRule.Block == nil {
+		return
+	}
+
+	p.newline()
+}
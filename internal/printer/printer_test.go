@@ -53,7 +53,24 @@ func TestKeyframes(t *testing.T) {
 		Print(t, `@keyframes x { from { opacity: 0 } to { opacity: 1 } }`))
 }
 
+func TestFunction_SpaceSeparatedArguments(t *testing.T) {
+	assert.Equal(t, `.class{background:linear-gradient(to right,red 10%,blue)}`,
+		Print(t, `.class { background: linear-gradient(to right, red 10%, blue) }`))
+}
+
 func TestRule_NoSemicolon(t *testing.T) {
 	assert.Equal(t, `.class{width:2rem}`,
 		Print(t, `.class { width: 2rem }`))
 }
+
+func TestNesting(t *testing.T) {
+	assert.Equal(t, `.a{color:red;&:hover{color:blue}.b &{color:green}> .c{color:white}}`,
+		Print(t, `.a { color: red; &:hover { color: blue } .b & { color: green } > .c { color: white } }`))
+
+	assert.Equal(t, `.a{@media screen{color:blue;& .b{color:red}}}`,
+		Print(t, `.a { @media screen { color: blue; & .b { color: red } } }`))
+
+	// Nested rules can start with an identifier.
+	assert.Equal(t, `.a{div &{color:red}color:blue;img{width:1px}a:hover{color:green}}`,
+		Print(t, `.a { div & { color: red } color: blue; img { width: 1px } a:hover { color: green } }`))
+}
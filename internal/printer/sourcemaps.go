@@ -1,5 +1,14 @@
 package printer
 
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stephen/cssc/internal/sources"
+)
+
 var (
 	base64Forward = []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/")
 
@@ -144,3 +153,148 @@ func VLQDecode(in []byte) (value int32, len int32) {
 
 	return rv >> 1, read
 }
+
+// InputSourceMap is a source map of an input file that was generated by another
+// tool, e.g. a preprocessor. Mappings into the file are composed with it so that
+// they point to its original sources.
+type InputSourceMap struct {
+	// Sources are the original sources. Their paths are as written in the source map,
+	// joined with its sourceRoot, and should be resolved by the caller. A source's
+	// Content is empty if the source map did not include it.
+	Sources []*sources.Source
+
+	// Names is the list of names in the source map.
+	Names []string
+
+	// lines is the list of segments on each generated line, sorted by column.
+	lines [][]inputSegment
+
+	// hasContent is whether or not the source map included the content of each source.
+	hasContent []bool
+}
+
+// inputSegment is a decoded segment of an input source map. source is -1 if the
+// segment is unmapped, and name is -1 if it has no name.
+type inputSegment struct {
+	generatedColumn int32
+	source          int32
+	originalLine    int32
+	originalColumn  int32
+	name            int32
+}
+
+// ParseSourceMap parses a json source map.
+func ParseSourceMap(data []byte) (*InputSourceMap, error) {
+	var m struct {
+		Version        int       `json:"version"`
+		SourceRoot     string    `json:"sourceRoot"`
+		Sources        []string  `json:"sources"`
+		SourcesContent []*string `json:"sourcesContent"`
+		Names          []string  `json:"names"`
+		Mappings       string    `json:"mappings"`
+	}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid source map: %w", err)
+	}
+
+	if m.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version: %d", m.Version)
+	}
+
+	rv := &InputSourceMap{Names: m.Names}
+	for i, path := range m.Sources {
+		source := &sources.Source{Path: path}
+		if m.SourceRoot != "" && !isURL(path) {
+			source.Path = strings.TrimSuffix(m.SourceRoot, "/") + "/" + path
+		}
+
+		hasContent := i < len(m.SourcesContent) && m.SourcesContent[i] != nil
+		if hasContent {
+			source.Content = *m.SourcesContent[i]
+		}
+
+		rv.Sources = append(rv.Sources, source)
+		rv.hasContent = append(rv.hasContent, hasContent)
+	}
+
+	var state inputSegment
+	for _, line := range strings.Split(m.Mappings, ";") {
+		var segments []inputSegment
+		state.generatedColumn = 0
+
+		for _, s := range strings.Split(line, ",") {
+			if s == "" {
+				continue
+			}
+
+			for _, b := range []byte(s) {
+				if b >= 127 || (base64Reverse[b] == 0 && b != 'A') {
+					return nil, fmt.Errorf("invalid source map segment: %q", s)
+				}
+			}
+
+			var fields []int32
+			for in := []byte(s); len(in) > 0; {
+				val, n := VLQDecode(in)
+				fields = append(fields, val)
+				in = in[n:]
+			}
+
+			if len(fields) != 1 && len(fields) != 4 && len(fields) != 5 {
+				return nil, fmt.Errorf("invalid source map segment: %q", s)
+			}
+
+			state.generatedColumn += fields[0]
+			segment := inputSegment{generatedColumn: state.generatedColumn, source: -1, name: -1}
+			if len(fields) >= 4 {
+				state.source += fields[1]
+				state.originalLine += fields[2]
+				state.originalColumn += fields[3]
+
+				if int(state.source) < 0 || int(state.source) >= len(rv.Sources) {
+					return nil, fmt.Errorf("invalid source index in source map: %d", state.source)
+				}
+				segment.source, segment.originalLine, segment.originalColumn = state.source, state.originalLine, state.originalColumn
+			}
+
+			if len(fields) == 5 {
+				state.name += fields[4]
+				if int(state.name) >= 0 && int(state.name) < len(rv.Names) {
+					segment.name = state.name
+				}
+			}
+
+			segments = append(segments, segment)
+		}
+
+		sort.SliceStable(segments, func(i, j int) bool {
+			return segments[i].generatedColumn < segments[j].generatedColumn
+		})
+		rv.lines = append(rv.lines, segments)
+	}
+
+	return rv, nil
+}
+
+// find returns the segment that a 0-indexed line and column is in, if it is mapped.
+func (m *InputSourceMap) find(line, column int32) (inputSegment, bool) {
+	if int(line) >= len(m.lines) {
+		return inputSegment{}, false
+	}
+
+	segments := m.lines[line]
+	i := sort.Search(len(segments), func(i int) bool {
+		return segments[i].generatedColumn > column
+	})
+	if i == 0 || segments[i-1].source < 0 {
+		return inputSegment{}, false
+	}
+
+	return segments[i-1], true
+}
+
+// isURL returns whether or not a source path is a url, e.g. webpack://, rather than a file path.
+func isURL(path string) bool {
+	return strings.Contains(path, "://")
+}
@@ -1,9 +1,15 @@
 package printer
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/sources"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestVLQEncode(t *testing.T) {
@@ -49,6 +55,187 @@ func TestVLQDecode_Continuation(t *testing.T) {
 	assert.Equal(t, []int32{0, 123, 123456, 123456789}, values)
 }
 
+// segment is a decoded source map segment. Name is -1 if the segment has no name.
+type segment struct {
+	GeneratedLine, GeneratedColumn int32
+	OriginalLine, OriginalColumn   int32
+	Name                           int32
+}
+
+// decodeMappings decodes the mappings of a source map with a single source.
+func decodeMappings(t testing.TB, mappings string) []segment {
+	var rv []segment
+	var last segment
+	var name int32
+	for line, l := range strings.Split(mappings, ";") {
+		last.GeneratedColumn = 0
+		for _, s := range strings.Split(l, ",") {
+			if s == "" {
+				continue
+			}
+
+			in := []byte(s)
+			var fields []int32
+			for len(in) > 0 {
+				val, n := VLQDecode(in)
+				require.NotZero(t, n)
+				fields = append(fields, val)
+				in = in[n:]
+			}
+			require.Contains(t, []int{4, 5}, len(fields))
+
+			last.GeneratedLine = int32(line)
+			last.GeneratedColumn += fields[0]
+			last.OriginalLine += fields[2]
+			last.OriginalColumn += fields[3]
+			last.Name = -1
+			if len(fields) == 5 {
+				name += fields[4]
+				last.Name = name
+			}
+			rv = append(rv, last)
+		}
+	}
+
+	return rv
+}
+
+func printSourceMap(t testing.TB, content string, opts Options) (string, sourceMapJSON) {
+	source := &sources.Source{Path: "main.css", Content: content}
+	ss, err := parser.Parse(source)
+	require.NoError(t, err)
+
+	opts.OriginalSource = source
+	out, sourceMap, err := PrintWithSourceMap(ss, opts)
+	require.NoError(t, err)
+
+	var m sourceMapJSON
+	require.NoError(t, json.Unmarshal([]byte(sourceMap), &m))
+	return out, m
+}
+
+func TestSourceMap_Mappings(t *testing.T) {
+	out, m := printSourceMap(t, ".a, .b { color: red;\n  width: calc(1px + 2px) }", Options{Minify: true})
+	assert.Equal(t, ".a,.b{color:red;width:calc(1px + 2px)}", out)
+	assert.Equal(t, []segment{
+		{0, 0, 0, 0, -1},   // .a
+		{0, 3, 0, 4, -1},   // .b
+		{0, 6, 0, 9, -1},   // color
+		{0, 12, 0, 16, -1}, // red
+		{0, 16, 1, 2, -1},  // width
+		{0, 22, 1, 9, -1},  // calc(
+		{0, 27, 1, 14, -1}, // 1px
+		{0, 33, 1, 20, -1}, // 2px
+	}, decodeMappings(t, m.Mappings))
+}
+
+func TestSourceMap_MappingsPretty(t *testing.T) {
+	out, m := printSourceMap(t, "a > .b { margin: 0 auto }", Options{Pretty: true})
+	assert.Equal(t, "a > .b {\n  margin: 0 auto;\n}\n", out)
+	assert.Equal(t, []segment{
+		{0, 0, 0, 0, -1},   // a
+		{0, 2, 0, 2, -1},   // >
+		{0, 4, 0, 4, -1},   // .b
+		{1, 2, 0, 9, -1},   // margin
+		{1, 10, 0, 17, -1}, // 0
+		{1, 12, 0, 19, -1}, // auto
+	}, decodeMappings(t, m.Mappings))
+}
+
+func TestSourceMap_MappingsSelectors(t *testing.T) {
+	out, m := printSourceMap(t, ":root, a::before, [x=y] { color: red }", Options{})
+	assert.Equal(t, ":root,a::before,[x=y]{color:red}", out)
+	assert.Equal(t, []segment{
+		{0, 0, 0, 0, -1},   // :root
+		{0, 6, 0, 7, -1},   // a
+		{0, 7, 0, 8, -1},   // ::before
+		{0, 16, 0, 18, -1}, // [x=y]
+		{0, 22, 0, 26, -1}, // color
+		{0, 28, 0, 33, -1}, // red
+	}, decodeMappings(t, m.Mappings))
+}
+
+func TestSourceMap_MappingsAfterComment(t *testing.T) {
+	// Comments aren't mapped, so the first segment comes after other output.
+	out, m := printSourceMap(t, "/* header */ .a { color: red }", Options{})
+	assert.Equal(t, "/* header */.a{color:red}", out)
+	assert.Equal(t, []segment{
+		{0, 12, 0, 13, -1}, // .a
+		{0, 15, 0, 18, -1}, // color
+		{0, 21, 0, 25, -1}, // red
+	}, decodeMappings(t, m.Mappings))
+}
+
+func TestSourceMap_Names(t *testing.T) {
+	source := &sources.Source{Path: "main.css", Content: ".a { color: red; background: blue }"}
+	ss, err := parser.Parse(source)
+	require.NoError(t, err)
+
+	rule := ss.Nodes[0].(*ast.QualifiedRule)
+	class := rule.Prelude.(*ast.SelectorList).Selectors[0].Parts[0]
+	decls := rule.Block.(*ast.DeclarationBlock).Declarations
+
+	out, sourceMap, err := PrintWithSourceMap(ss, Options{
+		OriginalSource: source,
+		Minify:         true,
+		Names: map[ast.Node]string{
+			class:              "a",
+			decls[0].Values[0]: "--primary",
+			decls[1].Values[0]: "--primary",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ".a{color:red;background:blue}", out)
+
+	var m sourceMapJSON
+	require.NoError(t, json.Unmarshal([]byte(sourceMap), &m))
+	assert.Equal(t, []string{"a", "--primary"}, m.Names)
+	assert.Equal(t, []segment{
+		{0, 0, 0, 0, 0},
+		{0, 3, 0, 5, -1},
+		{0, 9, 0, 12, 1},
+		{0, 13, 0, 17, -1},
+		{0, 24, 0, 29, 1},
+	}, decodeMappings(t, m.Mappings))
+}
+
+func TestParseSourceMap(t *testing.T) {
+	m, err := ParseSourceMap([]byte(`{"version":3,"sourceRoot":"src/","sources":["a.scss"],"names":["x"],"mappings":"AAAA,IAAIA,E;;EACA"}`))
+	require.NoError(t, err)
+	require.Len(t, m.Sources, 1)
+	assert.Equal(t, "src/a.scss", m.Sources[0].Path)
+
+	for _, tc := range []struct {
+		line, column int32
+		found        bool
+		expected     inputSegment
+	}{
+		{0, 0, true, inputSegment{0, 0, 0, 0, -1}},
+		{0, 3, true, inputSegment{0, 0, 0, 0, -1}},
+		{0, 4, true, inputSegment{4, 0, 0, 4, 0}},
+		{0, 6, false, inputSegment{}},
+		{1, 0, false, inputSegment{}},
+		{2, 1, false, inputSegment{}},
+		{2, 2, true, inputSegment{2, 0, 1, 4, -1}},
+		{3, 0, false, inputSegment{}},
+	} {
+		segment, found := m.find(tc.line, tc.column)
+		assert.Equal(t, tc.found, found, "%d:%d", tc.line, tc.column)
+		assert.Equal(t, tc.expected, segment, "%d:%d", tc.line, tc.column)
+	}
+
+	for _, invalid := range []string{
+		`{"version":2,"sources":[],"mappings":""}`,
+		`{"version":3,"sources":[],"mappings":"AAAA"}`,
+		`{"version":3,"sources":["a.scss"],"mappings":"AA"}`,
+		`{"version":3,"sources":["a.scss"],"mappings":"A!AA"}`,
+		`not json`,
+	} {
+		_, err := ParseSourceMap([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
 func BenchmarkVLQEncode(b *testing.B) {
 	b.Run("short encode", func(b *testing.B) {
 		b.ReportAllocs()
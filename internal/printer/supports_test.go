@@ -0,0 +1,51 @@
+package printer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupports(t *testing.T) {
+	assert.Equal(t, `@supports (display:grid){.a{display:grid}}`,
+		Print(t, `@supports (display: grid) { .a { display: grid } }`))
+
+	assert.Equal(t, `@supports not (display:grid){.a{float:left}}`,
+		Print(t, `@supports not (display: grid) { .a { float: left } }`))
+
+	assert.Equal(t, `@supports (display:grid) and (gap:1rem) and (not (display:inline-grid)){}`,
+		Print(t, `@supports (display: grid) and (gap: 1rem) and (not (display: inline-grid)) {}`))
+
+	assert.Equal(t, `@supports ((display:flex) or (display:-webkit-box)) and (color:red){}`,
+		Print(t, `@supports ((display: flex) or (display: -webkit-box)) and (color: red) {}`))
+
+	assert.Equal(t, `@supports (--custom:value){}`,
+		Print(t, `@supports (--custom: value) {}`))
+}
+
+func TestSupports_Selector(t *testing.T) {
+	assert.Equal(t, `@supports selector(a > b){}`,
+		Print(t, `@supports selector(a > b) {}`))
+
+	assert.Equal(t, `@supports not selector(:is(a, b)){}`,
+		Print(t, `@supports not selector(:is(a, b)) {}`))
+}
+
+func TestSupports_GeneralEnclosed(t *testing.T) {
+	assert.Equal(t, `@supports font-tech(color-COLRv1) or (unknown stuff(here)){}`,
+		Print(t, `@supports font-tech(color-COLRv1) or (unknown stuff(here)) {}`))
+}
+
+func TestSupports_Nested(t *testing.T) {
+	assert.Equal(t, `@supports (display:grid){@media screen{.a{display:grid}}.b{color:red}}`,
+		Print(t, `@supports (display: grid) {
+	@media screen {
+		.a { display: grid }
+	}
+
+	.b { color: red }
+}`))
+
+	assert.Equal(t, `@media screen{@supports (display:grid){.a{display:grid}}}`,
+		Print(t, `@media screen { @supports (display: grid) { .a { display: grid } } }`))
+}
@@ -0,0 +1,55 @@
+package printer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/sources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownAtRule_RuleBlock(t *testing.T) {
+	RoundTrip(t, `@-moz-document url-prefix(){.a{color:red}}`,
+		`@-moz-document url-prefix() { .a { color: red } }`)
+
+	RoundTrip(t, `@scope (.card) to (.content){img{border:1px solid black}}`,
+		`@scope (.card) to (.content) {
+	img { border: 1px solid black }
+}`)
+
+	RoundTrip(t, `@starting-style{.a{opacity:0}}`, `@starting-style { .a { opacity: 0 } }`)
+	RoundTrip(t, `@layer base, components;@layer base{html{color:black}}`,
+		`@layer base, components;
+@layer base { html { color: black } }`)
+}
+
+func TestUnknownAtRule_DeclarationBlock(t *testing.T) {
+	RoundTrip(t, `@property --x{syntax:"<length>";inherits:false;initial-value:0px}`,
+		`@property --x {
+	syntax: "<length>";
+	inherits: false;
+	initial-value: 0px;
+}`)
+}
+
+func TestUnknownAtRule_Raw(t *testing.T) {
+	RoundTrip(t, `@future-thing foo [bar] (baz: 1px){anything { goes: here; } [ok]}`,
+		`@future-thing foo [bar] (baz: 1px) { anything { goes: here; } [ok] }`)
+
+	RoundTrip(t, `@statement "value" url(x.png);.a{color:red}`,
+		`@statement "value" url(x.png); .a { color: red }`)
+
+	RoundTrip(t, `@media screen{@future;.a{color:red}}`,
+		`@media screen { @future; .a { color: red } }`)
+
+	RoundTrip(t, `@media screen{@future;}`,
+		`@media screen { @future }`)
+}
+
+func TestUnknownAtRule_Mismatched(t *testing.T) {
+	_, err := parser.Parse(&sources.Source{
+		Path:    "main.css",
+		Content: `@future (]) {}`,
+	})
+	assert.EqualError(t, err, "main.css:1:9\nunexpected token: ], expected ):\n\t@future (]) {}\n\t         ~")
+}
@@ -3,7 +3,7 @@ package sources
 import (
 	"sort"
 
-	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/ast"
 )
 
 // Source is a container for a file and its contents.
@@ -14,6 +14,10 @@ type Source struct {
 	// Content is the content of the file.
 	Content string
 
+	// Index is the index of the source in its compilation. Locations in the
+	// source have the same index.
+	Index int
+
 	// Lines is the offset of the beginning of every line. This
 	// is useful for quickly finding the line and column for a
 	// given byte offset (ast.Loc) and is filled in by the lexer.
@@ -33,3 +33,14 @@ a:any-link, section, .Something {
 	color: red;
 }`))
 }
+
+func TestAnyLink_Nested(t *testing.T) {
+	assert.Equal(t, "@supports (color:red){@media screen{.test:visited,.test:link{color:red}}}", Transform(t, compileAnyLink, `
+@supports (color: red) {
+	@media screen {
+		.test:any-link {
+			color: red;
+		}
+	}
+}`))
+}
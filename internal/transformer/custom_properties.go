@@ -0,0 +1,395 @@
+package transformer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/transforms"
+)
+
+// customProperties is the set of custom property definitions in a stylesheet, for
+// CustomPropertiesTransformScoped and CustomPropertiesTransformRootPreserve.
+type customProperties struct {
+	// globals is the values of the custom properties defined in top-level :root rules.
+	globals map[string][]ast.Value
+
+	// scoped is the last definition of each custom property in each scope.
+	scoped map[string]map[string]*ast.Declaration
+
+	// blockScopes is the scopes of the style rule that each block belongs to.
+	blockScopes map[*ast.DeclarationBlock][]string
+
+	// defined is the set of custom properties that are defined anywhere.
+	defined map[string]struct{}
+
+	// cycles is the set of custom properties that were reported as cyclic, so
+	// that each is only reported once.
+	cycles map[string]struct{}
+}
+
+// rootScope is the scope of definitions in top-level :root rules.
+const rootScope = ":root"
+
+// definition is a custom property declaration and the scopes that it is in, one
+// for each selector of its rule.
+type definition struct {
+	decl   *ast.Declaration
+	scopes []string
+}
+
+// collectCustomProperties finds the custom properties defined in s. Definitions in
+// top-level :root rules apply everywhere. Definitions in any other rule, or inside of
+// an at-rule, can only be resolved in rules with the same selector, so with
+// CustomPropertiesTransformScoped, a warning is reported if they redefine a property
+// from another scope.
+func (t *transformer) collectCustomProperties(s *ast.Stylesheet) {
+	t.customProperties = &customProperties{
+		globals:     make(map[string][]ast.Value),
+		scoped:      make(map[string]map[string]*ast.Declaration),
+		blockScopes: make(map[*ast.DeclarationBlock][]string),
+		defined:     make(map[string]struct{}),
+		cycles:      make(map[string]struct{}),
+	}
+
+	var defs []definition
+	t.collectDefinitions(s.Nodes, "", &defs)
+
+	scopes := make(map[string]map[string]struct{})
+	for _, d := range defs {
+		if scopes[d.decl.Property] == nil {
+			scopes[d.decl.Property] = make(map[string]struct{})
+		}
+		t.customProperties.defined[d.decl.Property] = struct{}{}
+
+		for _, scope := range d.scopes {
+			scopes[d.decl.Property][scope] = struct{}{}
+			if t.customProperties.scoped[scope] == nil {
+				t.customProperties.scoped[scope] = make(map[string]*ast.Declaration)
+			}
+			t.customProperties.scoped[scope][d.decl.Property] = d.decl
+
+			if scope == rootScope {
+				t.customProperties.globals[d.decl.Property] = d.decl.Values
+			}
+		}
+	}
+
+	if t.CustomProperties != transforms.CustomPropertiesTransformScoped {
+		return
+	}
+
+	for _, d := range defs {
+		if !isRootDefinition(d) && len(scopes[d.decl.Property]) > 1 {
+			t.addWarn(d.decl.Location(), "custom property %s is redefined in a scope that can't be resolved statically", d.decl.Property)
+		}
+	}
+}
+
+// isRootDefinition returns whether or not d is in a top-level :root rule, e.g.
+// :root, .dark { ... }.
+func isRootDefinition(d definition) bool {
+	for _, scope := range d.scopes {
+		if scope == rootScope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectDefinitions adds the custom property declarations in nodes to defs. context
+// is the scope of the enclosing rules and at-rules.
+func (t *transformer) collectDefinitions(nodes []ast.Node, context string, defs *[]definition) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.QualifiedRule:
+			l, ok := n.Prelude.(*ast.SelectorList)
+			if !ok {
+				continue
+			}
+
+			block, ok := n.Block.(*ast.DeclarationBlock)
+			if !ok {
+				continue
+			}
+
+			scopes := selectorScopes(context, l)
+			t.customProperties.blockScopes[block] = scopes
+			for _, d := range block.Declarations {
+				if strings.HasPrefix(d.Property, "--") {
+					*defs = append(*defs, definition{d, scopes})
+				}
+			}
+
+			t.collectDefinitions(block.Rules, context+scopeKey(l)+" ", defs)
+
+		case *ast.AtRule:
+			if n.Name == "import" {
+				// Imports without conditions are in the same scope as the importing file.
+				if imported, ok := t.ImportReplacements[n]; ok {
+					if len(n.Preludes) > 1 {
+						t.collectDefinitions(imported.Nodes, fmt.Sprintf("%s@import %p ", context, n), defs)
+					} else {
+						t.collectDefinitions(imported.Nodes, context, defs)
+					}
+				}
+				continue
+			}
+
+			switch block := n.Block.(type) {
+			case *ast.QualifiedRuleBlock:
+				t.collectDefinitions(block.Rules, fmt.Sprintf("%s@%s %p ", context, n.Name, n), defs)
+			case *ast.DeclarationBlock:
+				t.collectDefinitions(block.Rules, fmt.Sprintf("%s@%s %p ", context, n.Name, n), defs)
+			}
+		}
+	}
+}
+
+// scopeKey returns a string that is the same for rules with equal selectors.
+func scopeKey(l *ast.SelectorList) string {
+	keys := make([]string, 0, len(l.Selectors))
+	for _, sel := range l.Selectors {
+		key := selectorKey(sel.Parts)
+		if key == "" {
+			return fmt.Sprintf("%p", l)
+		}
+		keys = append(keys, key)
+	}
+
+	return strings.Join(keys, ",")
+}
+
+// selectorScopes returns the scope of each selector in l. Selectors that can't be
+// compared get a scope of their own.
+func selectorScopes(context string, l *ast.SelectorList) []string {
+	scopes := make([]string, 0, len(l.Selectors))
+	for _, sel := range l.Selectors {
+		key := selectorKey(sel.Parts)
+		if key == "" {
+			key = fmt.Sprintf("%p", sel)
+		}
+		scopes = append(scopes, context+key)
+	}
+
+	return scopes
+}
+
+// addSubstitutedDeclarations adds a declaration with the values of block's var()
+// substituted before each declaration that uses var(), for browsers that don't support
+// custom properties. With CustomPropertiesTransformScoped, custom properties defined in
+// rules with the same selectors take precedence over :root ones.
+func (t *transformer) addSubstitutedDeclarations(block *ast.DeclarationBlock) []*ast.Declaration {
+	decls := block.Declarations
+	var local map[string][]ast.Value
+	if t.CustomProperties == transforms.CustomPropertiesTransformScoped {
+		local = t.localCustomProperties(block)
+	}
+
+	rv := make([]*ast.Declaration, 0, len(decls))
+	for _, d := range decls {
+		if strings.HasPrefix(d.Property, "--") || !hasVar(d.Values) {
+			rv = append(rv, d)
+			continue
+		}
+
+		if values, ok := t.substituteVars(d.Values, local, make(map[string]struct{})); ok {
+			rv = append(rv, &ast.Declaration{
+				Loc:       d.Loc,
+				Property:  d.Property,
+				Values:    values,
+				Important: d.Important,
+			})
+		}
+		rv = append(rv, d)
+	}
+
+	return rv
+}
+
+// localCustomProperties returns the values of the custom properties that are defined
+// in every scope of block. Blocks that aren't in a style rule, e.g. @font-face, only
+// use their own definitions.
+func (t *transformer) localCustomProperties(block *ast.DeclarationBlock) map[string][]ast.Value {
+	local := make(map[string][]ast.Value)
+	scopes, ok := t.customProperties.blockScopes[block]
+	if !ok {
+		for _, d := range block.Declarations {
+			if strings.HasPrefix(d.Property, "--") {
+				local[d.Property] = d.Values
+			}
+		}
+		return local
+	}
+
+	for property, decl := range t.customProperties.scoped[scopes[0]] {
+		shared := true
+		for _, scope := range scopes[1:] {
+			if t.customProperties.scoped[scope][property] != decl {
+				shared = false
+				break
+			}
+		}
+
+		if shared {
+			local[property] = decl.Values
+		}
+	}
+
+	return local
+}
+
+// substituteVars returns values with each var() replaced by the value of its custom
+// property, or its fallback. Properties in local are used before :root ones. resolving
+// is the set of properties being substituted, to detect cycles. It returns false if any
+// var() can't be resolved.
+func (t *transformer) substituteVars(values []ast.Value, local map[string][]ast.Value, resolving map[string]struct{}) ([]ast.Value, bool) {
+	rv := make([]ast.Value, 0, len(values))
+	for _, value := range values {
+		switch v := value.(type) {
+		case *ast.Function:
+			if v.Name == "var" {
+				vals, ok := t.substituteVar(v, local, resolving)
+				if !ok {
+					return nil, false
+				}
+				rv = append(rv, vals...)
+				continue
+			}
+
+			args, ok := t.substituteVars(v.Arguments, local, resolving)
+			if !ok {
+				return nil, false
+			}
+			rv = append(rv, &ast.Function{Loc: v.Loc, Name: v.Name, Arguments: args})
+
+		case *ast.MathExpression:
+			left, ok := t.substituteVars([]ast.Value{v.Left}, local, resolving)
+			if !ok || len(left) != 1 {
+				return nil, false
+			}
+
+			right, ok := t.substituteVars([]ast.Value{v.Right}, local, resolving)
+			if !ok || len(right) != 1 {
+				return nil, false
+			}
+
+			rv = append(rv, &ast.MathExpression{Loc: v.Loc, Operator: v.Operator, Left: left[0], Right: right[0]})
+
+		default:
+			// Values are copied so that the substituted ones can be named separately
+			// from the definitions they come from.
+			rv = append(rv, cloneValue(v))
+		}
+	}
+
+	return rv, true
+}
+
+// cloneValue copies a single value, e.g. a dimension. Functions and math expressions
+// are returned as they are, since substituteVars rebuilds them.
+func cloneValue(v ast.Value) ast.Value {
+	switch v := v.(type) {
+	case *ast.Dimension:
+		copy := *v
+		return &copy
+	case *ast.Identifier:
+		copy := *v
+		return &copy
+	case *ast.String:
+		copy := *v
+		return &copy
+	case *ast.HexColor:
+		copy := *v
+		return &copy
+	case *ast.URL:
+		copy := *v
+		return &copy
+	case *ast.Comma:
+		copy := *v
+		return &copy
+	case *ast.Comment:
+		copy := *v
+		return &copy
+	}
+
+	return v
+}
+
+// substituteVar returns the values of a single var(), including any var() in them.
+func (t *transformer) substituteVar(v *ast.Function, local map[string][]ast.Value, resolving map[string]struct{}) ([]ast.Value, bool) {
+	if len(v.Arguments) == 0 {
+		t.addError(v.Location(), "expected at least one argument to var()")
+		return nil, false
+	}
+
+	name, ok := v.Arguments[0].(*ast.Identifier)
+	if !ok {
+		t.addError(v.Location(), "expected identifier as argument to var()")
+		return nil, false
+	}
+
+	// :root properties can only refer to other :root properties.
+	vals, ok := local[name.Value]
+	scope := local
+	if !ok {
+		vals, ok = t.customProperties.globals[name.Value]
+		scope = nil
+	}
+
+	if ok {
+		if _, cyclic := resolving[name.Value]; cyclic {
+			if _, reported := t.customProperties.cycles[name.Value]; !reported {
+				t.customProperties.cycles[name.Value] = struct{}{}
+				t.addWarn(v.Location(), "custom property %s refers to itself", name.Value)
+			}
+			return nil, false
+		}
+
+		resolving[name.Value] = struct{}{}
+		substituted, ok := t.substituteVars(vals, scope, resolving)
+		delete(resolving, name.Value)
+
+		if ok {
+			// Values from a chain of custom properties keep the name of the one that
+			// defined them.
+			for _, val := range substituted {
+				if _, named := t.Names[val]; !named {
+					t.setOriginalName(val, name.Value)
+				}
+			}
+			return substituted, true
+		}
+	}
+
+	// The first argument is the value, the second is a comma.
+	if len(v.Arguments) > 2 {
+		return t.substituteVars(v.Arguments[2:], local, resolving)
+	}
+
+	if _, defined := t.customProperties.defined[name.Value]; !defined {
+		t.addWarn(v.Location(), "use of undefined variable without fallback: %s", name.Value)
+	}
+
+	return nil, false
+}
+
+// hasVar returns whether or not values has a var(), including inside of other functions.
+func hasVar(values []ast.Value) bool {
+	for _, value := range values {
+		switch v := value.(type) {
+		case *ast.Function:
+			if v.Name == "var" || hasVar(v.Arguments) {
+				return true
+			}
+
+		case *ast.MathExpression:
+			if hasVar([]ast.Value{v.Left, v.Right}) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
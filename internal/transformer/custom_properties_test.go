@@ -3,9 +3,13 @@ package transformer_test
 import (
 	"testing"
 
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/internal/sources"
 	"github.com/stephen/cssc/internal/transformer"
 	"github.com/stephen/cssc/transforms"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func compileCustomProperties(o *transformer.Options) {
@@ -44,3 +48,165 @@ func TestCustomProperties_Fallback(t *testing.T) {
 		font-family: var(--font, "Helvetica", sans-serif);
 	}`))
 }
+
+func compileScopedCustomProperties(o *transformer.Options) {
+	o.CustomProperties = transforms.CustomPropertiesTransformScoped
+}
+
+// transformScoped is like Transform with CustomPropertiesTransformScoped, but returns
+// the reported warnings instead of failing on them.
+func transformScoped(t testing.TB, s string) (string, []string) {
+	source := &sources.Source{
+		Path:    "main.css",
+		Content: s,
+	}
+	ss, err := parser.Parse(source)
+	require.NoError(t, err)
+
+	var errors collectingReporter
+	out, err := printer.Print(transformer.Transform(ss, transformer.Options{
+		OriginalSource: source,
+		Reporter:       &errors,
+		Options:        transforms.Options{CustomProperties: transforms.CustomPropertiesTransformScoped},
+	}), printer.Options{})
+	require.NoError(t, err)
+
+	var warnings []string
+	for _, err := range errors {
+		warnings = append(warnings, err.Error())
+	}
+	return out, warnings
+}
+
+func TestCustomProperties_Scoped(t *testing.T) {
+	assert.Equal(t, ":root{--color:red}.a{color:red;color:var(--color)}", Transform(t, compileScopedCustomProperties, `
+:root { --color: red; }
+.a { color: var(--color); }`))
+
+	// Definitions in the same rule are used before :root ones.
+	out, warnings := transformScoped(t, `
+.a { --color: blue; color: var(--color); }
+.b { color: var(--color); }
+:root { --color: red; }`)
+	assert.Equal(t, ".a{--color:blue;color:blue;color:var(--color)}.b{color:red;color:var(--color)}:root{--color:red}", out)
+	assert.Len(t, warnings, 1)
+
+	// Later :root definitions win.
+	assert.Equal(t, ":root{--a:1px}:root{--a:2px}.a{width:2px;width:var(--a)}", Transform(t, compileScopedCustomProperties, `
+:root { --a: 1px; }
+:root { --a: 2px; }
+.a { width: var(--a); }`))
+
+	assert.Equal(t, "@media (min-width:100px){.a{color:red;color:var(--color)}}:root{--color:red}", Transform(t, compileScopedCustomProperties, `
+@media (min-width: 100px) {
+	.a { color: var(--color); }
+}
+:root { --color: red; }`))
+
+	// Rules with the same selector are in the same scope.
+	out, warnings = transformScoped(t, `
+.x { --c: blue; }
+.x { color: var(--c); }`)
+	assert.Equal(t, ".x{--c:blue}.x{color:blue;color:var(--c)}", out)
+	assert.Empty(t, warnings)
+
+	// Each selector of a rule is a scope, so :root in a selector list is global.
+	out, warnings = transformScoped(t, `
+:root, .dark { --c: red; }
+.a { color: var(--c); }
+.dark { color: var(--c); }`)
+	assert.Equal(t, ":root,.dark{--c:red}.a{color:red;color:var(--c)}.dark{color:red;color:var(--c)}", out)
+	assert.Empty(t, warnings)
+
+	// Comments in the selector don't change the scope.
+	assert.Equal(t, ":root /* theme */{--color:red}.a{color:red;color:var(--color)}", Transform(t, compileScopedCustomProperties, `
+:root /* theme */ { --color: red; }
+.a { color: var(--color); }`))
+}
+
+func TestCustomProperties_ScopedNested(t *testing.T) {
+	assert.Equal(t, ":root{--red:#f00;--color:var(--red)}.a{color:#f00;color:var(--color)}", Transform(t, compileScopedCustomProperties, `
+:root { --red: #f00; --color: var(--red); }
+.a { color: var(--color); }`))
+
+	assert.Equal(t, ":root{--base:2px}.a{margin:0 2px;margin:0 var(--missing,var(--base))}", Transform(t, compileScopedCustomProperties, `
+:root { --base: 2px; }
+.a { margin: 0 var(--missing, var(--base)); }`))
+
+	assert.Equal(t, ":root{--x:1px}.a{width:calc(1px + 2px);width:calc(var(--x) + 2px)}", Transform(t, compileScopedCustomProperties, `
+:root { --x: 1px; }
+.a { width: calc(var(--x) + 2px); }`))
+
+	// Local definitions can refer to :root ones.
+	assert.Equal(t, ":root{--size:4px}.a{--gap:var(--size);gap:4px;gap:var(--gap)}", Transform(t, compileScopedCustomProperties, `
+:root { --size: 4px; }
+.a { --gap: var(--size); gap: var(--gap); }`))
+}
+
+func TestCustomProperties_ScopedCycles(t *testing.T) {
+	out, warnings := transformScoped(t, `
+:root { --a: var(--b); --b: var(--a); }
+.a { color: var(--a); }
+.b { color: var(--a, blue); }`)
+	assert.Equal(t, ":root{--a:var(--b);--b:var(--a)}.a{color:var(--a)}.b{color:blue;color:var(--a,blue)}", out)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "custom property --a refers to itself")
+}
+
+func TestCustomProperties_ScopedWarnings(t *testing.T) {
+	out, warnings := transformScoped(t, `
+:root { --color: red; }
+.dark { --color: blue; }
+@media (prefers-color-scheme: dark) {
+	:root { --color: black; }
+}
+.a { color: var(--color); }`)
+	assert.Equal(t, ":root{--color:red}.dark{--color:blue}@media (prefers-color-scheme:dark){:root{--color:black}}.a{color:red;color:var(--color)}", out)
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "custom property --color is redefined in a scope that can't be resolved statically")
+	assert.Contains(t, warnings[0], "main.css:3:")
+	assert.Contains(t, warnings[1], "main.css:5:")
+
+	out, warnings = transformScoped(t, `
+:root, .dark { --color: red; }
+.dark { --color: blue; }
+.a { color: var(--color); }`)
+	assert.Equal(t, ":root,.dark{--color:red}.dark{--color:blue}.a{color:red;color:var(--color)}", out)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "main.css:3:")
+
+	// Properties that are only defined in one scope are fine.
+	out, warnings = transformScoped(t, `
+.card { --gap: 4px; gap: var(--gap); }
+.other { gap: var(--gap); }`)
+	assert.Equal(t, ".card{--gap:4px;gap:4px;gap:var(--gap)}.other{gap:var(--gap)}", out)
+	assert.Empty(t, warnings)
+
+	_, warnings = transformScoped(t, `.a { color: var(--missing); }`)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "use of undefined variable without fallback: --missing")
+}
+
+func preserveCustomProperties(o *transformer.Options) {
+	o.CustomProperties = transforms.CustomPropertiesTransformRootPreserve
+}
+
+func TestCustomProperties_RootPreserve(t *testing.T) {
+	assert.Equal(t, ":root{--var-width:1rem 3rem}.class{margin:0rem 1rem 3rem;margin:0rem var(--var-width)}", Transform(t, preserveCustomProperties, `:root {
+	--var-width: 1rem 3rem;
+}
+
+.class {
+	margin: 0rem var(--var-width);
+}`))
+
+	assert.Equal(t, ".class{margin:0rem 2rem;margin:0rem var(--var-width,2rem)}", Transform(t, preserveCustomProperties, `.class {
+	margin: 0rem var(--var-width, 2rem);
+}`))
+
+	// Only :root properties are substituted, and other definitions aren't reported.
+	assert.Equal(t, ":root{--color:red}.a{--color:blue;color:red;color:var(--color)}.b{--gap:1px;gap:var(--gap)}", Transform(t, preserveCustomProperties, `
+:root { --color: red; }
+.a { --color: blue; color: var(--color); }
+.b { --gap: 1px; gap: var(--gap); }`))
+}
@@ -0,0 +1,166 @@
+package transformer
+
+import (
+	"github.com/stephen/cssc/ast"
+)
+
+// WrapImportConditions wraps the nodes of an imported stylesheet in rules that match
+// the conditions of the @import that imported them, i.e. @media for media queries,
+// @supports for supports() and @layer for layer. Media queries of @media rules at
+// the top-level of nodes are combined with the import's media queries where possible,
+// instead of nesting the rules.
+// See: https://www.w3.org/TR/css-cascade-5/#at-import.
+func WrapImportConditions(imp *ast.AtRule, nodes []ast.Node) []ast.Node {
+	if len(nodes) == 0 {
+		return nodes
+	}
+
+	// The conditions are in the order layer, supports(), then media queries, so they
+	// are wrapped from the innermost outwards. Media queries end up on the outside,
+	// where they can be combined with the media queries of nested imports.
+	for _, prelude := range imp.Preludes[1:] {
+		switch prelude := prelude.(type) {
+		case *ast.MediaQueryList:
+			nodes = wrapInMedia(prelude, nodes)
+
+		case *ast.ImportSupports:
+			nodes = []ast.Node{&ast.AtRule{
+				Loc:      prelude.Loc,
+				Name:     "supports",
+				Preludes: []ast.AtPrelude{prelude.Condition},
+				Block:    &ast.QualifiedRuleBlock{Loc: prelude.Loc, Rules: nodes},
+			}}
+
+		case *ast.ImportLayer:
+			layer := &ast.AtRule{
+				Loc:   prelude.Loc,
+				Name:  "layer",
+				Block: &ast.QualifiedRuleBlock{Loc: prelude.Loc, Rules: nodes},
+			}
+
+			if prelude.Name != "" {
+				layer.Preludes = []ast.AtPrelude{&ast.RawPrelude{
+					Loc:    prelude.Loc,
+					Tokens: []ast.RawToken{{Loc: prelude.Loc, Text: prelude.Name}},
+				}}
+			}
+			nodes = []ast.Node{layer}
+		}
+	}
+
+	return nodes
+}
+
+// wrapInMedia wraps nodes in @media rules for l. @media rules in nodes are combined
+// with l if possible, e.g. @media (min-width: 1px) wrapped in screen becomes
+// @media screen and (min-width: 1px). Other nodes are grouped into @media rules
+// in the same order.
+func wrapInMedia(l *ast.MediaQueryList, nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+
+	var pending []ast.Node
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+
+		rv = append(rv, &ast.AtRule{
+			Loc:      l.Loc,
+			Name:     "media",
+			Preludes: []ast.AtPrelude{l},
+			Block:    &ast.QualifiedRuleBlock{Loc: l.Loc, Rules: pending},
+		})
+		pending = nil
+	}
+
+	for _, n := range nodes {
+		media, ok := n.(*ast.AtRule)
+		if !ok || media.Name != "media" || len(media.Preludes) != 1 {
+			pending = append(pending, n)
+			continue
+		}
+
+		inner, ok := media.Preludes[0].(*ast.MediaQueryList)
+		if !ok {
+			pending = append(pending, n)
+			continue
+		}
+
+		combined := combineMediaQueryLists(l, inner)
+		if combined == nil {
+			pending = append(pending, n)
+			continue
+		}
+
+		flush()
+
+		// Make a new rule, since the imported stylesheet may be used elsewhere.
+		rv = append(rv, &ast.AtRule{
+			Loc:      media.Loc,
+			Name:     media.Name,
+			Preludes: []ast.AtPrelude{combined},
+			Block:    media.Block,
+		})
+	}
+	flush()
+
+	return rv
+}
+
+// combineMediaQueryLists returns a media query list that matches when both outer and
+// inner match. It returns nil if the lists cannot be combined, e.g. if inner has a
+// media type.
+func combineMediaQueryLists(outer, inner *ast.MediaQueryList) *ast.MediaQueryList {
+	// An empty list always matches.
+	if len(inner.Queries) == 0 {
+		return &ast.MediaQueryList{Loc: inner.Loc, Queries: outer.Queries}
+	}
+
+	l := &ast.MediaQueryList{
+		Loc: inner.Loc,
+	}
+
+	for _, o := range outer.Queries {
+		for _, i := range inner.Queries {
+			if !canCombineOuter(o) || !canCombineInner(i) {
+				return nil
+			}
+
+			parts := make([]ast.MediaQueryPart, 0, len(o.Parts)+len(i.Parts)+1)
+			parts = append(parts, o.Parts...)
+			parts = append(parts, &ast.Identifier{Value: "and"})
+			parts = append(parts, i.Parts...)
+
+			l.Queries = append(l.Queries, &ast.MediaQuery{
+				Loc:   i.Loc,
+				Parts: parts,
+			})
+		}
+	}
+
+	return l
+}
+
+// canCombineOuter returns whether or not more conditions can be added to
+// the end of q with and. A negated query or a query using or cannot be extended.
+func canCombineOuter(q *ast.MediaQuery) bool {
+	for _, p := range q.Parts {
+		if ident, ok := p.(*ast.Identifier); ok && (ident.Value == "not" || ident.Value == "or") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// canCombineInner returns whether or not q is a list of conditions joined by and,
+// which can be added to the end of another query.
+func canCombineInner(q *ast.MediaQuery) bool {
+	for _, p := range q.Parts {
+		if ident, ok := p.(*ast.Identifier); ok && ident.Value != "and" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,544 @@
+package transformer
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/stephen/cssc/ast"
+)
+
+// sum is a simplified math expression: a sum of dimensions, with at most one for
+// each unit, and terms that can't be reduced, like var().
+// See: https://drafts.csswg.org/css-values-4/#calc-simplification.
+type sum struct {
+	loc ast.Loc
+
+	// units is the units in values, in the order they were first added. The empty
+	// unit is for numbers.
+	units  []string
+	values map[string]float64
+
+	others []term
+}
+
+// term is a value in a sum that can't be reduced.
+type term struct {
+	value    ast.Value
+	negative bool
+}
+
+func newSum(loc ast.Loc) *sum {
+	return &sum{loc: loc, values: make(map[string]float64)}
+}
+
+func (s *sum) addValue(unit string, v float64) {
+	if _, ok := s.values[unit]; !ok {
+		s.units = append(s.units, unit)
+	}
+	s.values[unit] += v
+}
+
+// add adds other to s, or subtracts it if negative is set.
+func (s *sum) add(other *sum, negative bool) {
+	sign := 1.
+	if negative {
+		sign = -1
+	}
+
+	for _, unit := range other.units {
+		s.addValue(unit, sign*other.values[unit])
+	}
+
+	for _, o := range other.others {
+		s.others = append(s.others, term{o.value, o.negative != negative})
+	}
+}
+
+// scale multiplies s by n, or divides it if divide is set.
+func (s *sum) scale(n float64, divide bool) {
+	for unit, v := range s.values {
+		if divide {
+			s.values[unit] = v / n
+		} else {
+			s.values[unit] = v * n
+		}
+	}
+
+	op := "*"
+	if divide {
+		op = "/"
+	}
+
+	for i, o := range s.others {
+		s.others[i].value = &ast.MathExpression{
+			Loc:      o.value.Location(),
+			Left:     o.value,
+			Operator: op,
+			Right:    newDimension(o.value.Location(), n, ""),
+		}
+	}
+}
+
+// number returns the value of s if it is a plain number, e.g. the 2 in 2 * 5px.
+func (s *sum) number() (float64, bool) {
+	s.normalize()
+	if len(s.others) > 0 || len(s.units) != 1 || s.units[0] != "" {
+		return 0, false
+	}
+
+	return s.values[""], true
+}
+
+// single returns the only dimension in s, if it has exactly one.
+func (s *sum) single() (float64, string, bool) {
+	s.normalize()
+	if len(s.others) > 0 || len(s.units) != 1 {
+		return 0, "", false
+	}
+
+	return s.values[s.units[0]], s.units[0], true
+}
+
+// hasDimensions returns whether or not s has any values with units, including percentages.
+func (s *sum) hasDimensions() bool {
+	for _, unit := range s.units {
+		if unit != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalize converts units of the same type to their canonical unit, so that they
+// can be added, e.g. 1in + 4px becomes 100px. Units that are the only one of their
+// type are left as they are.
+func (s *sum) normalize() {
+	types := make(map[string]int)
+	for _, unit := range s.units {
+		if c, ok := conversions[strings.ToLower(unit)]; ok {
+			types[c.canonical]++
+		}
+	}
+
+	units := s.units
+	values := s.values
+	s.units, s.values = nil, make(map[string]float64)
+	for _, unit := range units {
+		c, ok := conversions[strings.ToLower(unit)]
+		if !ok || types[c.canonical] < 2 {
+			s.addValue(unit, values[unit])
+			continue
+		}
+
+		s.addValue(c.canonical, values[unit]*c.factor)
+	}
+}
+
+// value returns s as an ast.Value: a dimension if s has a single one, or a math
+// expression otherwise.
+func (s *sum) value() ast.Value {
+	s.normalize()
+
+	var terms []term
+	for _, unit := range s.units {
+		v := s.values[unit]
+		if v == 0 && (len(s.units) > 1 || len(s.others) > 0) {
+			continue
+		}
+
+		terms = append(terms, term{newDimension(s.loc, math.Abs(v), unit), v < 0})
+	}
+	terms = append(terms, s.others...)
+
+	if len(terms) == 0 {
+		return newDimension(s.loc, 0, "")
+	}
+
+	var rv ast.Value
+	for i, t := range terms {
+		if i == 0 {
+			rv = t.value
+			if t.negative {
+				if d, ok := t.value.(*ast.Dimension); ok {
+					d.Value = "-" + d.Value
+				} else {
+					rv = &ast.MathExpression{Loc: s.loc, Left: newDimension(s.loc, -1, ""), Operator: "*", Right: t.value}
+				}
+			}
+			continue
+		}
+
+		op := "+"
+		if t.negative {
+			op = "-"
+		}
+		rv = &ast.MathExpression{Loc: s.loc, Left: rv, Operator: op, Right: t.value}
+	}
+
+	return rv
+}
+
+// precision rounds simplified values to 6 decimal places, so that e.g. 0.1px + 0.2px
+// is 0.3px rather than 0.30000000000000004px.
+const precision = 1e6
+
+func newDimension(loc ast.Loc, v float64, unit string) *ast.Dimension {
+	v = math.Round(v*precision) / precision
+	if v == 0 {
+		// Avoid -0.
+		v = 0
+	}
+
+	return &ast.Dimension{Loc: loc, Value: strconv.FormatFloat(v, 'f', -1, 64), Unit: unit}
+}
+
+// conversion converts a unit to the canonical unit of its type.
+type conversion struct {
+	canonical string
+	factor    float64
+}
+
+// conversions is the conversion of each absolute unit to its canonical unit.
+// See: https://drafts.csswg.org/css-values-4/#absolute-lengths.
+var conversions = map[string]conversion{
+	"px": {"px", 1},
+	"in": {"px", 96},
+	"cm": {"px", 96 / 2.54},
+	"mm": {"px", 96 / 25.4},
+	"q":  {"px", 96 / 101.6},
+	"pt": {"px", 4. / 3},
+	"pc": {"px", 16},
+
+	"ms": {"ms", 1},
+	"s":  {"ms", 1000},
+
+	"deg":  {"deg", 1},
+	"grad": {"deg", 0.9},
+	"rad":  {"deg", 180 / math.Pi},
+	"turn": {"deg", 360},
+
+	"hz":  {"hz", 1},
+	"khz": {"hz", 1000},
+}
+
+// integerProperties is the set of properties whose numbers must be integers. Math
+// functions in them are rounded to the nearest integer.
+// See: https://drafts.csswg.org/css-values-4/#calc-range.
+var integerProperties = map[string]struct{}{
+	"z-index":            {},
+	"order":              {},
+	"column-count":       {},
+	"orphans":            {},
+	"widows":             {},
+	"line-clamp":         {},
+	"-webkit-line-clamp": {},
+	"counter-increment":  {},
+	"counter-reset":      {},
+	"counter-set":        {},
+	"grid-area":          {},
+	"grid-row":           {},
+	"grid-row-start":     {},
+	"grid-row-end":       {},
+	"grid-column":        {},
+	"grid-column-start":  {},
+	"grid-column-end":    {},
+}
+
+// reduceMath simplifies a math function, i.e. calc(), min(), max() or clamp(). It
+// returns a dimension if the function can be fully reduced, or the simplified function.
+func (t *transformer) reduceMath(f *ast.Function) ast.Value {
+	s, ok := t.simplifyFunction(f)
+	if !ok {
+		return f
+	}
+
+	v := s.value()
+	if d, ok := v.(*ast.Dimension); ok {
+		if t.integer && d.Unit == "" {
+			// Ties are rounded up, like round().
+			n, _ := strconv.ParseFloat(d.Value, 64)
+			return newDimension(d.Loc, math.Floor(n+0.5), "")
+		}
+		return v
+	}
+
+	// Math functions don't need to be wrapped in calc().
+	if fn, ok := v.(*ast.Function); ok && fn.IsMath() {
+		return fn
+	}
+
+	return &ast.Function{Loc: f.Loc, Name: "calc", Arguments: []ast.Value{v}}
+}
+
+// reduceMathArguments reduces the math functions in the arguments of another function,
+// e.g. translate(calc(1px + 2px)).
+func (t *transformer) reduceMathArguments(args []ast.Value) []ast.Value {
+	rv := make([]ast.Value, 0, len(args))
+	for _, arg := range args {
+		if f, ok := arg.(*ast.Function); ok {
+			if f.IsMath() {
+				arg = t.reduceMath(f)
+			} else if f.Name != "var" {
+				f.Arguments = t.reduceMathArguments(f.Arguments)
+			}
+		}
+		rv = append(rv, arg)
+	}
+
+	return rv
+}
+
+// simplifyFunction simplifies a math function. It returns false if the function's
+// arguments are invalid.
+func (t *transformer) simplifyFunction(f *ast.Function) (*sum, bool) {
+	if f.Name == "calc" {
+		if len(f.Arguments) != 1 {
+			t.addWarn(f.Location(), "expected single argument for calc()")
+			return nil, false
+		}
+
+		if f.Arguments[0] == nil {
+			t.addError(f.Location(), "expected value in calc()")
+			return nil, false
+		}
+
+		return t.simplify(f.Arguments[0])
+	}
+
+	var args []*sum
+	for i, arg := range f.Arguments {
+		if _, ok := arg.(*ast.Comma); ok {
+			continue
+		}
+
+		if arg == nil {
+			t.addError(f.Location(), "expected value in %s()", f.Name)
+			return nil, false
+		}
+
+		if i > 0 {
+			if _, ok := f.Arguments[i-1].(*ast.Comma); !ok {
+				t.addWarn(arg.Location(), "expected comma between arguments of %s()", f.Name)
+				return nil, false
+			}
+		}
+
+		s, ok := t.simplify(arg)
+		if !ok {
+			return nil, false
+		}
+		args = append(args, s)
+	}
+
+	if f.Name == "clamp" && len(args) != 3 {
+		t.addWarn(f.Location(), "expected 3 arguments for clamp()")
+		return nil, false
+	}
+
+	if len(args) == 0 {
+		t.addWarn(f.Location(), "expected at least one argument for %s()", f.Name)
+		return nil, false
+	}
+
+	if v, unit, ok := reduceComparison(f.Name, args); ok {
+		s := newSum(f.Loc)
+		s.addValue(unit, v)
+		return s, true
+	}
+
+	// Keep the function, but with its arguments simplified.
+	rv := &ast.Function{Loc: f.Loc, Name: f.Name}
+	for i, arg := range args {
+		if i > 0 {
+			rv.Arguments = append(rv.Arguments, &ast.Comma{Loc: arg.loc})
+		}
+		rv.Arguments = append(rv.Arguments, arg.value())
+	}
+
+	s := newSum(f.Loc)
+	s.others = append(s.others, term{value: rv})
+	return s, true
+}
+
+// reduceComparison reduces min(), max() and clamp() if all of their arguments are
+// dimensions of the same type.
+func reduceComparison(name string, args []*sum) (float64, string, bool) {
+	values := make([]float64, 0, len(args))
+	var unit string
+	for i, arg := range args {
+		v, u, ok := arg.single()
+		if !ok {
+			return 0, "", false
+		}
+
+		if i > 0 && u != unit {
+			// Convert both to their canonical unit, if they are the same type.
+			from, ok := conversions[strings.ToLower(u)]
+			to, ok2 := conversions[strings.ToLower(unit)]
+			if !ok || !ok2 || from.canonical != to.canonical {
+				return 0, "", false
+			}
+
+			for j := range values {
+				values[j] *= to.factor
+			}
+			v *= from.factor
+			u = from.canonical
+		}
+
+		values = append(values, v)
+		unit = u
+	}
+
+	switch name {
+	case "min":
+		rv := values[0]
+		for _, v := range values[1:] {
+			rv = math.Min(rv, v)
+		}
+		return rv, unit, true
+
+	case "max":
+		rv := values[0]
+		for _, v := range values[1:] {
+			rv = math.Max(rv, v)
+		}
+		return rv, unit, true
+
+	case "clamp":
+		return math.Max(values[0], math.Min(values[1], values[2])), unit, true
+	}
+
+	return 0, "", false
+}
+
+// simplify simplifies a value inside of a math function. It returns false if the
+// expression is invalid.
+func (t *transformer) simplify(v ast.Value) (*sum, bool) {
+	switch v := v.(type) {
+	case *ast.Dimension:
+		f, err := strconv.ParseFloat(v.Value, 64)
+		if err != nil {
+			t.addError(v.Location(), "could not parse dimension value: %s", v.Value)
+			return nil, false
+		}
+
+		s := newSum(v.Loc)
+		s.addValue(v.Unit, f)
+		return s, true
+
+	case *ast.MathExpression:
+		return t.simplifyExpression(v)
+
+	case *ast.Function:
+		if v.IsMath() {
+			return t.simplifyFunction(v)
+		}
+
+		if v.Name == "var" && t.variables != nil {
+			if substituted := t.transformValues([]ast.Value{v}); len(substituted) == 1 && substituted[0] != ast.Value(v) {
+				return t.simplify(substituted[0])
+			}
+		}
+	}
+
+	s := newSum(v.Location())
+	s.others = append(s.others, term{value: v})
+	return s, true
+}
+
+func (t *transformer) simplifyExpression(expr *ast.MathExpression) (*sum, bool) {
+	if expr.Left == nil || expr.Right == nil {
+		t.addError(expr.Location(), "expected value on both sides of %s", expr.Operator)
+		return nil, false
+	}
+
+	l, ok := t.simplify(expr.Left)
+	if !ok {
+		return nil, false
+	}
+
+	r, ok := t.simplify(expr.Right)
+	if !ok {
+		return nil, false
+	}
+
+	switch expr.Operator {
+	case "+", "-":
+		if len(l.others) == 0 && len(r.others) == 0 {
+			// Numbers can't be mixed with dimensions, e.g. 2 + 5rem.
+			_, lNumber := l.values[""]
+			_, rNumber := r.values[""]
+			if lNumber && r.hasDimensions() {
+				t.addError(expr.Left.Location(), "cannot add number type and %s type together", firstUnit(r))
+				return nil, false
+			}
+			if rNumber && l.hasDimensions() {
+				t.addError(expr.Left.Location(), "cannot add number type and %s type together", firstUnit(l))
+				return nil, false
+			}
+		}
+
+		l.add(r, expr.Operator == "-")
+		return l, true
+
+	case "*":
+		if n, ok := r.number(); ok {
+			l.scale(n, false)
+			return l, true
+		}
+
+		if n, ok := l.number(); ok {
+			r.scale(n, false)
+			return r, true
+		}
+
+		if len(l.others) == 0 && len(r.others) == 0 {
+			t.addError(expr.Left.Location(), "one side of multiplication must be a number (non-percentage/dimension)")
+			return nil, false
+		}
+
+	case "/":
+		if n, ok := r.number(); ok {
+			if n == 0 {
+				t.addError(expr.Left.Location(), "cannot divide by zero")
+				return nil, false
+			}
+
+			l.scale(n, true)
+			return l, true
+		}
+
+		if len(r.others) == 0 {
+			t.addError(expr.Left.Location(), "right side of division must be a number (non-percentage/dimension)")
+			return nil, false
+		}
+
+	default:
+		t.addError(expr.Left.Location(), "unknown op: %s", expr.Operator)
+		return nil, false
+	}
+
+	// The product can't be reduced, e.g. var(--x) * var(--y).
+	s := newSum(expr.Loc)
+	s.others = append(s.others, term{value: &ast.MathExpression{
+		Loc:      expr.Loc,
+		Left:     l.value(),
+		Operator: expr.Operator,
+		Right:    r.value(),
+	}})
+	return s, true
+}
+
+// firstUnit returns the first unit in s that isn't a number.
+func firstUnit(s *sum) string {
+	for _, unit := range s.units {
+		if unit != "" {
+			return unit
+		}
+	}
+
+	return ""
+}
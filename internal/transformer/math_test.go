@@ -3,9 +3,13 @@ package transformer_test
 import (
 	"testing"
 
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/sources"
 	"github.com/stephen/cssc/internal/transformer"
 	"github.com/stephen/cssc/transforms"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func compileMath(o *transformer.Options) {
@@ -15,7 +19,7 @@ func compileMath(o *transformer.Options) {
 func TestMath(t *testing.T) {
 	assert.Equal(t, `.class{width:3px}`, Transform(t, compileMath, `.class { width: calc(1px + 2px) }`))
 	assert.Equal(t, `.class{width:-1px}`, Transform(t, compileMath, `.class { width: calc(1px - 2px) }`))
-	assert.Equal(t, `.class{width:calc(1px+2rem)}`, Transform(t, compileMath, `.class { width: calc(1px + 2rem) }`))
+	assert.Equal(t, `.class{width:calc(1px + 2rem)}`, Transform(t, compileMath, `.class { width: calc(1px + 2rem) }`))
 	assert.Equal(t, `.class{width:17%}`, Transform(t, compileMath, `.class { width: calc(22% - 5%) }`))
 	assert.Panics(t, func() { Transform(t, compileMath, `.class { width: calc(2 + 25%) }`) })
 
@@ -25,8 +29,7 @@ func TestMath(t *testing.T) {
 
 	assert.Equal(t, `.class{width:5%}`, Transform(t, compileMath, `.class { width: calc(10% / 2) }`))
 
-	// XXX: fix precision in below
-	assert.Equal(t, `.class{width:3.3333333333333335%}`, Transform(t, compileMath, `.class { width: calc(10% / 3) }`))
+	assert.Equal(t, `.class{width:3.333333%}`, Transform(t, compileMath, `.class { width: calc(10% / 3) }`))
 	assert.Equal(t, `.class{width:4px}`, Transform(t, compileMath, `.class { width: calc(20px / 5) }`))
 	assert.Equal(t, `.class{width:20}`, Transform(t, compileMath, `.class { width: calc(20 / 1) }`))
 	assert.Panics(t, func() { Transform(t, compileMath, `.class { width: calc(2% / 25%) }`) })
@@ -34,15 +37,94 @@ func TestMath(t *testing.T) {
 
 	assert.Equal(t, `.class{width:3px}`, Transform(t, compileMath, `.class { width: calc(1px + 4px / 2) }`))
 
-	assert.Equal(t, `.class{width:calc(1px+2px)}`, Transform(t, nil, `.class { width: calc(1px + 2px) }`))
+	assert.Equal(t, `.class{width:calc(1px + 2px)}`, Transform(t, nil, `.class { width: calc(1px + 2px) }`))
 
-	// XXX: this should work but doesn't. because we treat math as left-associative and binary but
-	// 22% - 1rem cannot be reduced into something that can be added to 8rem.
-	// assert.Equal(t, `.class{width:calc(22%+7rem)}`, Transform(t, compileMath, `.class { width: calc(22% - 1rem + 8rem) }`))
+	assert.Equal(t, `.class{width:calc(22% + 7rem)}`, Transform(t, compileMath, `.class { width: calc(22% - 1rem + 8rem) }`))
+}
+
+func TestMath_Sums(t *testing.T) {
+	assert.Equal(t, `.class{width:calc(4px + 2rem)}`, Transform(t, compileMath, `.class { width: calc(1px + 2rem + 3px) }`))
+	assert.Equal(t, `.class{width:calc(10% - 2rem + 1px)}`, Transform(t, compileMath, `.class { width: calc(10% - 3rem + 1px + 1rem) }`))
+	assert.Equal(t, `.class{width:0px}`, Transform(t, compileMath, `.class { width: calc(1px - 1px) }`))
+	assert.Equal(t, `.class{width:calc(2rem - 1px)}`, Transform(t, compileMath, `.class { width: calc(2rem + 1px - 2px) }`))
+}
+
+func TestMath_Nested(t *testing.T) {
+	assert.Equal(t, `.class{width:6px}`, Transform(t, compileMath, `.class { width: calc(calc(1px + 2px) * 2) }`))
+	assert.Equal(t, `.class{width:calc(2px + 2rem)}`, Transform(t, compileMath, `.class { width: calc(calc(1px + 1rem) * 2) }`))
+	assert.Equal(t, `.class{width:calc(1px + var(--x))}`, Transform(t, compileMath, `.class { width: calc(1px + calc(var(--x))) }`))
+	assert.Equal(t, `.class{width:calc(2px + var(--x)*2)}`, Transform(t, compileMath, `.class { width: calc(calc(1px + var(--x)) * 2) }`))
+	assert.Equal(t, `.class{width:calc((1px + var(--x))*var(--y))}`, Transform(t, compileMath, `.class { width: calc(calc(1px + var(--x)) * var(--y)) }`))
+	assert.Equal(t, `.class{transform:translate(3px,calc(1px + 1rem))}`, Transform(t, compileMath, `.class { transform: translate(calc(1px + 2px), calc(1px + 1rem)) }`))
+}
+
+func TestMath_Parentheses(t *testing.T) {
+	assert.Equal(t, `.class{width:calc(2px + 2rem)}`, Transform(t, compileMath, `.class { width: calc(2 * (1px + 1rem)) }`))
+	assert.Equal(t, `.class{width:calc(100% - 20px + 1rem)}`, Transform(t, compileMath, `.class { width: calc(100% - (20px - 1rem)) }`))
+	assert.Equal(t, `.class{width:9px}`, Transform(t, compileMath, `.class { width: calc((1px + 2px) * 3) }`))
+	assert.Equal(t, `.class{width:1px}`, Transform(t, compileMath, `.class { width: calc(((1px))) }`))
+	assert.Equal(t, `.class{width:calc((1px + var(--x))*var(--y))}`, Transform(t, compileMath, `.class { width: calc((1px + var(--x)) * var(--y)) }`))
+
+	// Without reduction, the parentheses are kept.
+	assert.Equal(t, `.class{width:calc(100% - (20px - 1rem))}`, Transform(t, nil, `.class { width: calc(100% - (20px - 1rem)) }`))
+	assert.Equal(t, `.class{width:calc((1px + 2px)*3/(4*5))}`, Transform(t, nil, `.class { width: calc((1px + 2px) * 3 / (4 * 5)) }`))
+}
+
+func TestMath_Comparisons(t *testing.T) {
+	assert.Equal(t, `.class{width:1px}`, Transform(t, compileMath, `.class { width: min(1px, 2px, 3px) }`))
+	assert.Equal(t, `.class{width:5px}`, Transform(t, compileMath, `.class { width: max(1px, calc(2px + 3px)) }`))
+	assert.Equal(t, `.class{width:4px}`, Transform(t, compileMath, `.class { width: clamp(1px, 4px, 10px) }`))
+	assert.Equal(t, `.class{width:10px}`, Transform(t, compileMath, `.class { width: clamp(1px, 20px, 10px) }`))
+	assert.Equal(t, `.class{width:96px}`, Transform(t, compileMath, `.class { width: max(1in, 10px) }`))
+	assert.Equal(t, `.class{width:min(10%,3px)}`, Transform(t, compileMath, `.class { width: min(10%, 1px + 2px) }`))
+	assert.Equal(t, `.class{width:clamp(1rem,10vw,2rem)}`, Transform(t, compileMath, `.class { width: clamp(1rem, 10vw, 2rem) }`))
+	assert.Panics(t, func() { Transform(t, compileMath, `.class { width: clamp(1px, 2px) }`) })
+	assert.Panics(t, func() { Transform(t, compileMath, `.class { width: min() }`) })
+	assert.Equal(t, `.class{width:calc(2px + min(1rem,10%))}`, Transform(t, compileMath, `.class { width: calc(1px + min(1rem, 10%) + 1px) }`))
+}
+
+func TestMath_Units(t *testing.T) {
+	assert.Equal(t, `.class{width:100px}`, Transform(t, compileMath, `.class { width: calc(1in + 4px) }`))
+	assert.Equal(t, `.class{width:2in}`, Transform(t, compileMath, `.class { width: calc(1in + 1in) }`))
+	assert.Equal(t, `.class{width:32px}`, Transform(t, compileMath, `.class { width: calc(1pc + 12pt) }`))
+	assert.Equal(t, `.class{transition-duration:1500ms}`, Transform(t, compileMath, `.class { transition-duration: calc(1s + 500ms) }`))
+	assert.Equal(t, `.class{transform:rotate(450deg)}`, Transform(t, compileMath, `.class { transform: rotate(calc(1turn + 90deg)) }`))
+	assert.Equal(t, `.class{width:calc(1in + 1rem)}`, Transform(t, compileMath, `.class { width: calc(1in + 1rem) }`))
+	assert.Equal(t, `.class{width:41.574803px}`, Transform(t, compileMath, `.class { width: calc(1cm + 1mm) }`))
+	assert.Equal(t, `.class{width:0.3px}`, Transform(t, compileMath, `.class { width: calc(0.1px + 0.2px) }`))
+}
+
+func TestMath_Integers(t *testing.T) {
+	// Properties that take integers are rounded, like browsers do.
+	assert.Equal(t, `.class{z-index:2}`, Transform(t, compileMath, `.class { z-index: calc(3 / 2) }`))
+	assert.Equal(t, `.class{order:-1}`, Transform(t, compileMath, `.class { order: calc(-4 / 3) }`))
+	assert.Equal(t, `.class{grid-row-start:3}`, Transform(t, compileMath, `.class { grid-row-start: calc(5 / 2) }`))
+	assert.Equal(t, `.class{z-index:calc(var(--x)/2)}`, Transform(t, compileMath, `.class { z-index: calc(var(--x) / 2) }`))
+
+	// Other numbers are kept as they are.
+	assert.Equal(t, `.class{flex-grow:1.5;opacity:0.5}`, Transform(t, compileMath, `.class { flex-grow: calc(3 / 2); opacity: calc(1 / 2) }`))
+}
+
+func TestMath_MissingOperand(t *testing.T) {
+	source := &sources.Source{
+		Path:    "main.css",
+		Content: `.class { width: calc(1px + 2px) }`,
+	}
+	ss, err := parser.Parse(source)
+	require.NoError(t, err)
+
+	// The parser doesn't produce expressions without operands, but plugins can.
+	decl := ss.Nodes[0].(*ast.QualifiedRule).Block.(*ast.DeclarationBlock).Declarations[0]
+	decl.Values[0].(*ast.Function).Arguments[0].(*ast.MathExpression).Right = nil
 
-	// XXX: does this one work?
-	// calc(var(--x) + 5px)
-	// ((5px + 22%) + 5px)
-	// (((5px + 22%) + 5px) + 5px) = 22% + 15px
-	// (((5px + 22%) + 22%) + 5px) = 44% + 10px
+	var errs collectingReporter
+	assert.NotPanics(t, func() {
+		transformer.Transform(ss, transformer.Options{
+			OriginalSource: source,
+			Reporter:       &errs,
+			Options:        transforms.Options{CalcReduction: transforms.CalcReductionReduce},
+		})
+	})
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "expected value on both sides of +")
 }
@@ -0,0 +1,309 @@
+package transformer
+
+import (
+	"github.com/stephen/cssc/ast"
+)
+
+// hasNestedRules returns whether or not the rule has nested style rules
+// or conditional group rules in its block.
+func hasNestedRules(rule *ast.QualifiedRule) bool {
+	block, ok := rule.Block.(*ast.DeclarationBlock)
+	if !ok {
+		return false
+	}
+
+	for _, r := range block.Rules {
+		if isNestedRule(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNestedRule returns whether or not the node is a style rule or conditional
+// group rule nested inside of a style rule.
+func isNestedRule(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.QualifiedRule:
+		return true
+
+	case *ast.AtRule:
+		if n.Name != "media" && n.Name != "supports" {
+			return false
+		}
+
+		_, ok := n.Block.(*ast.DeclarationBlock)
+		return ok
+	}
+
+	return false
+}
+
+// flattenNesting flattens the nested rules inside of rule. The rule itself is
+// returned first, followed by the nested style rules with their selectors
+// composed with the parent's. Nested conditional group rules are turned into
+// top-level rules that wrap a copy of the parent's selectors.
+// See: https://www.w3.org/TR/css-nesting-1/.
+func (t *transformer) flattenNesting(rule *ast.QualifiedRule) []ast.Node {
+	parents, ok := rule.Prelude.(*ast.SelectorList)
+	if !ok {
+		return []ast.Node{rule}
+	}
+
+	block, ok := rule.Block.(*ast.DeclarationBlock)
+	if !ok {
+		return []ast.Node{rule}
+	}
+
+	rv := []ast.Node{rule}
+	kept := make([]ast.Node, 0, len(block.Rules))
+	for _, r := range block.Rules {
+		if !isNestedRule(r) {
+			kept = append(kept, r)
+			continue
+		}
+
+		switch nested := r.(type) {
+		case *ast.QualifiedRule:
+			selectors, ok := nested.Prelude.(*ast.SelectorList)
+			if !ok {
+				t.addError(nested.Location(), "expected selector list for nested rule")
+				continue
+			}
+
+			nested.Prelude = composeSelectors(parents, selectors)
+			rv = append(rv, t.flattenNesting(nested)...)
+
+		case *ast.AtRule:
+			inner := &ast.QualifiedRule{
+				Loc:     nested.Loc,
+				Prelude: cloneSelectorList(parents),
+				Block:   nested.Block,
+			}
+
+			nested.Block = &ast.QualifiedRuleBlock{
+				Loc:   nested.Block.Location(),
+				Rules: t.flattenNesting(inner),
+			}
+			rv = append(rv, nested)
+		}
+	}
+	block.Rules = kept
+
+	// Drop the parent if only comments are left in it.
+	if len(block.Declarations) == 0 && onlyComments(kept) {
+		return rv[1:]
+	}
+
+	return rv
+}
+
+// onlyComments returns whether or not every node is a comment.
+func onlyComments(nodes []ast.Node) bool {
+	for _, n := range nodes {
+		if _, ok := n.(*ast.Comment); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// composeSelectors replaces the nesting selectors in selectors with parents. Selectors
+// without a nesting selector are treated as descendants of the parents. If the
+// parents can be substituted in place, one selector is created for each parent.
+// Otherwise, the nesting selectors are replaced with :is(parents).
+func composeSelectors(parents, selectors *ast.SelectorList) *ast.SelectorList {
+	l := &ast.SelectorList{
+		Loc: selectors.Loc,
+	}
+
+	for _, s := range selectors.Selectors {
+		parts := s.Parts
+		if !hasNestingSelector(parts) {
+			parts = append([]ast.SelectorPart{&ast.NestingSelector{Loc: s.Loc}, &ast.Whitespace{Loc: s.Loc}}, parts...)
+		}
+
+		if !canSubstitute(parents, parts) {
+			is := &ast.PseudoClassSelector{
+				Loc:       s.Loc,
+				Name:      "is",
+				Arguments: cloneSelectorList(parents),
+			}
+
+			l.Selectors = append(l.Selectors, &ast.Selector{
+				Loc:   s.Loc,
+				Parts: replaceNestingSelector(parts, []ast.SelectorPart{is}),
+			})
+			continue
+		}
+
+		for _, parent := range parents.Selectors {
+			l.Selectors = append(l.Selectors, &ast.Selector{
+				Loc:   s.Loc,
+				Parts: replaceNestingSelector(parts, trimWhitespace(parent.Parts)),
+			})
+		}
+	}
+
+	return l
+}
+
+// canSubstitute returns whether or not each parent can replace the nesting
+// selectors in parts without changing the meaning of the selector, e.g.
+// because a complex parent would be combined with the selectors before it.
+func canSubstitute(parents *ast.SelectorList, parts []ast.SelectorPart) bool {
+	count := 0
+	for _, p := range parts {
+		if _, ok := p.(*ast.NestingSelector); ok {
+			count++
+		}
+	}
+
+	// Multiple nesting selectors can match different parents.
+	if count > 1 && len(parents.Selectors) > 1 {
+		return false
+	}
+
+	for i, p := range parts {
+		if _, ok := p.(*ast.NestingSelector); !ok {
+			continue
+		}
+
+		// A type selector must come first in a compound selector, e.g. &div.
+		if i+1 < len(parts) {
+			if _, ok := parts[i+1].(*ast.TypeSelector); ok {
+				return false
+			}
+		}
+
+		for _, parent := range parents.Selectors {
+			parentParts := trimWhitespace(parent.Parts)
+			if i > 0 && isComplex(parentParts) {
+				return false
+			}
+
+			if i > 0 && len(parentParts) > 0 && !isCombinator(parts[i-1]) {
+				if _, ok := parentParts[0].(*ast.TypeSelector); ok {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func hasNestingSelector(parts []ast.SelectorPart) bool {
+	for _, p := range parts {
+		if _, ok := p.(*ast.NestingSelector); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replaceNestingSelector returns a copy of parts with each nesting selector
+// replaced with replacement. A type selector right after a nesting selector
+// is moved in front of the replacement, since it must come first in its
+// compound selector.
+func replaceNestingSelector(parts, replacement []ast.SelectorPart) []ast.SelectorPart {
+	rv := make([]ast.SelectorPart, 0, len(parts)+len(replacement))
+	for i := 0; i < len(parts); i++ {
+		if _, ok := parts[i].(*ast.NestingSelector); !ok {
+			rv = append(rv, parts[i])
+			continue
+		}
+
+		if i+1 < len(parts) {
+			if _, ok := parts[i+1].(*ast.TypeSelector); ok {
+				rv = append(rv, parts[i+1])
+				i++
+			}
+		}
+		rv = append(rv, replacement...)
+	}
+
+	return rv
+}
+
+// isComplex returns whether or not parts contains a combinator.
+func isComplex(parts []ast.SelectorPart) bool {
+	for _, p := range parts {
+		if isCombinator(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCombinator returns whether or not p is a combinator, including the
+// descendant (whitespace) combinator.
+func isCombinator(p ast.SelectorPart) bool {
+	switch p.(type) {
+	case *ast.Whitespace, *ast.CombinatorSelector:
+		return true
+	}
+
+	return false
+}
+
+// trimWhitespace removes leading and trailing whitespace from parts.
+func trimWhitespace(parts []ast.SelectorPart) []ast.SelectorPart {
+	for len(parts) > 0 {
+		if _, ok := parts[0].(*ast.Whitespace); !ok {
+			break
+		}
+		parts = parts[1:]
+	}
+
+	for len(parts) > 0 {
+		if _, ok := parts[len(parts)-1].(*ast.Whitespace); !ok {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	return parts
+}
+
+// withoutComments returns parts without comments, keeping the whitespace around
+// each comment once.
+func withoutComments(parts []ast.SelectorPart) []ast.SelectorPart {
+	rv := make([]ast.SelectorPart, 0, len(parts))
+	for _, part := range parts {
+		if _, ok := part.(*ast.Comment); ok {
+			continue
+		}
+
+		if _, ok := part.(*ast.Whitespace); ok && len(rv) > 0 {
+			if _, prevOk := rv[len(rv)-1].(*ast.Whitespace); prevOk {
+				continue
+			}
+		}
+		rv = append(rv, part)
+	}
+
+	return rv
+}
+
+// cloneSelectorList makes a copy of l so that the copy's selectors can be
+// transformed separately.
+func cloneSelectorList(l *ast.SelectorList) *ast.SelectorList {
+	rv := &ast.SelectorList{
+		Loc:       l.Loc,
+		Selectors: make([]*ast.Selector, 0, len(l.Selectors)),
+	}
+
+	for _, s := range l.Selectors {
+		rv.Selectors = append(rv.Selectors, &ast.Selector{
+			Loc:   s.Loc,
+			Parts: append([]ast.SelectorPart(nil), s.Parts...),
+		})
+	}
+
+	return rv
+}
@@ -0,0 +1,102 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+)
+
+func compileNesting(o *transformer.Options) {
+	o.Nesting = transforms.NestingTransform
+}
+
+func TestNesting(t *testing.T) {
+	assert.Equal(t, ".a{color:red}.a:hover{color:blue}.a .b{color:green}.a > .c{color:white}", Transform(t, compileNesting, `
+.a {
+	color: red;
+	&:hover { color: blue; }
+	.b { color: green; }
+	> .c { color: white; }
+}`))
+
+	assert.Equal(t, ".a .b .c{color:red}.d :is(.a .b){color:blue}", Transform(t, compileNesting, `
+.a .b {
+	& .c { color: red; }
+	.d & { color: blue; }
+}`))
+
+	assert.Equal(t, ".a .b{color:red}.a .b .c{color:blue}", Transform(t, compileNesting, `
+.a {
+	& .b {
+		color: red;
+		& .c { color: blue; }
+	}
+}`))
+
+	assert.Equal(t, "div .a{color:red}.card img{width:1px}", Transform(t, compileNesting, `
+.a {
+	div & { color: red; }
+}
+.card {
+	img { width: 1px; }
+}`))
+
+	// Parents with only comments left are dropped.
+	assert.Equal(t, ".a .b{color:red}", Transform(t, compileNesting, `
+.a {
+	/* c */
+	.b { color: red; }
+}`))
+
+	assert.Equal(t, ".a{color:red;&:hover{color:blue}}", Transform(t, nil, `
+.a {
+	color: red;
+	&:hover { color: blue; }
+}`))
+}
+
+func TestNesting_SelectorLists(t *testing.T) {
+	assert.Equal(t, ".a:hover,.b:hover,.a:focus,.b:focus{color:red}", Transform(t, compileNesting, `
+.a, .b {
+	&:hover, &:focus { color: red; }
+}`))
+
+	assert.Equal(t, ".c :is(.a .b,.d){color:red}", Transform(t, compileNesting, `
+.a .b, .d {
+	.c & { color: red; }
+}`))
+
+	assert.Equal(t, "div:is(.a){color:red}", Transform(t, compileNesting, `
+.a {
+	&div { color: red; }
+}`))
+
+	assert.Equal(t, ":is(.a,.b) + :is(.a,.b){color:red}", Transform(t, compileNesting, `
+.a, .b {
+	& + & { color: red; }
+}`))
+}
+
+func TestNesting_ConditionalRules(t *testing.T) {
+	assert.Equal(t, ".a{color:red}@media screen{.a{color:blue}.a:hover{color:green}}", Transform(t, compileNesting, `
+.a {
+	color: red;
+	@media screen {
+		color: blue;
+		&:hover { color: green; }
+	}
+}`))
+
+	assert.Equal(t, "@supports (display:grid){@media screen{.a .b{display:grid}}}", Transform(t, compileNesting, `
+@supports (display: grid) {
+	.a {
+		& .b {
+			@media screen {
+				display: grid;
+			}
+		}
+	}
+}`))
+}
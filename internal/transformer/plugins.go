@@ -0,0 +1,218 @@
+package transformer
+
+import (
+	"fmt"
+
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/transforms"
+)
+
+// pluginsForStage returns the plugins that run in stage, in order.
+func (t *transformer) pluginsForStage(stage transforms.PluginStage) []transforms.Plugin {
+	var rv []transforms.Plugin
+	for _, p := range t.Plugins {
+		if p.Stage == stage {
+			rv = append(rv, p)
+		}
+	}
+
+	return rv
+}
+
+// runPlugins runs the hooks of plugins over the stylesheet.
+func (t *transformer) runPlugins(plugins []transforms.Plugin, s *ast.Stylesheet) {
+	if len(plugins) == 0 {
+		return
+	}
+
+	r := &pluginRunner{transformer: t, plugins: plugins}
+	for _, p := range plugins {
+		if p.Stylesheet != nil {
+			p.Stylesheet(s, r.context(p))
+		}
+	}
+
+	s.Nodes = r.nodes(s.Nodes)
+}
+
+// pluginRunner visits an AST, calling the hooks of each plugin in order.
+type pluginRunner struct {
+	*transformer
+
+	plugins []transforms.Plugin
+}
+
+func (r *pluginRunner) context(p transforms.Plugin) transforms.PluginContext {
+	return &pluginContext{transformer: r.transformer, name: p.Name}
+}
+
+func (r *pluginRunner) nodes(nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+	for _, node := range nodes {
+		// Inlined imports were already transformed with their own file.
+		if _, ok := r.inlined[node]; ok {
+			rv = append(rv, node)
+			continue
+		}
+
+		current := []ast.Node{node}
+		for _, p := range r.plugins {
+			if p.Rule == nil && p.AtRule == nil {
+				continue
+			}
+
+			next := make([]ast.Node, 0, len(current))
+			for _, n := range current {
+				switch n := n.(type) {
+				case *ast.QualifiedRule:
+					if p.Rule != nil {
+						next = append(next, p.Rule(n, r.context(p))...)
+						continue
+					}
+
+				case *ast.AtRule:
+					if p.AtRule != nil {
+						next = append(next, p.AtRule(n, r.context(p))...)
+						continue
+					}
+				}
+				next = append(next, n)
+			}
+			current = next
+		}
+
+		for _, n := range current {
+			switch n := n.(type) {
+			case *ast.QualifiedRule:
+				if selectors, ok := n.Prelude.(*ast.SelectorList); ok {
+					selectors.Selectors = r.selectors(selectors.Selectors)
+				}
+				r.block(n.Block)
+
+			case *ast.AtRule:
+				r.block(n.Block)
+			}
+		}
+
+		rv = append(rv, current...)
+	}
+
+	return rv
+}
+
+func (r *pluginRunner) block(block ast.Block) {
+	switch block := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		block.Rules = r.nodes(block.Rules)
+
+	case *ast.DeclarationBlock:
+		block.Declarations = r.declarations(block.Declarations)
+		block.Rules = r.nodes(block.Rules)
+	}
+}
+
+func (r *pluginRunner) declarations(decls []*ast.Declaration) []*ast.Declaration {
+	for _, p := range r.plugins {
+		if p.Declaration == nil {
+			continue
+		}
+
+		next := make([]*ast.Declaration, 0, len(decls))
+		for _, d := range decls {
+			next = append(next, p.Declaration(d, r.context(p))...)
+		}
+		decls = next
+	}
+
+	for _, d := range decls {
+		d.Values = r.values(d.Values)
+	}
+
+	return decls
+}
+
+func (r *pluginRunner) values(values []ast.Value) []ast.Value {
+	for _, p := range r.plugins {
+		if p.Value == nil {
+			continue
+		}
+
+		next := make([]ast.Value, 0, len(values))
+		for _, v := range values {
+			next = append(next, p.Value(v, r.context(p))...)
+		}
+		values = next
+	}
+
+	for _, v := range values {
+		switch v := v.(type) {
+		case *ast.Function:
+			v.Arguments = r.values(v.Arguments)
+
+		case *ast.MathExpression:
+			v.Left = r.operand(v.Left)
+			v.Right = r.operand(v.Right)
+		}
+	}
+
+	return values
+}
+
+// operand runs the value hooks on an operand of a math expression, which must be
+// replaced with a single value.
+func (r *pluginRunner) operand(v ast.Value) ast.Value {
+	values := r.values([]ast.Value{v})
+	if len(values) != 1 {
+		r.addError(v.Location(), "expected plugins to replace math operand with a single value, but got %d", len(values))
+		return v
+	}
+
+	return values[0]
+}
+
+func (r *pluginRunner) selectors(selectors []*ast.Selector) []*ast.Selector {
+	for _, p := range r.plugins {
+		if p.Selector == nil {
+			continue
+		}
+
+		next := make([]*ast.Selector, 0, len(selectors))
+		for _, s := range selectors {
+			next = append(next, p.Selector(s, r.context(p))...)
+		}
+		selectors = next
+	}
+
+	return selectors
+}
+
+// pluginContext implements transforms.PluginContext for a single plugin.
+type pluginContext struct {
+	*transformer
+
+	name string
+}
+
+// Path implements transforms.PluginContext.
+func (c *pluginContext) Path() string {
+	if c.OriginalSource == nil {
+		return ""
+	}
+
+	return c.OriginalSource.Path
+}
+
+// Errorf implements transforms.PluginContext.
+func (c *pluginContext) Errorf(node ast.Node, format string, args ...interface{}) {
+	c.addError(node.Location(), "%s: %s", c.name, fmt.Sprintf(format, args...))
+}
+
+// Warnf implements transforms.PluginContext.
+func (c *pluginContext) Warnf(node ast.Node, format string, args ...interface{}) {
+	c.addWarn(node.Location(), "%s: %s", c.name, fmt.Sprintf(format, args...))
+}
+
+// SetOriginalName implements transforms.PluginContext.
+func (c *pluginContext) SetOriginalName(node ast.Node, name string) {
+	c.setOriginalName(node, name)
+}
@@ -0,0 +1,185 @@
+package transformer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/sources"
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixClasses is a plugin that prefixes every class name.
+var prefixClasses = transforms.Plugin{
+	Name: "prefix-classes",
+	Selector: func(sel *ast.Selector, ctx transforms.PluginContext) []*ast.Selector {
+		for _, p := range sel.Parts {
+			if c, ok := p.(*ast.ClassSelector); ok {
+				c.Name = "x-" + c.Name
+			}
+		}
+		return []*ast.Selector{sel}
+	},
+}
+
+func TestPlugins(t *testing.T) {
+	dropColor := transforms.Plugin{
+		Name: "drop-color",
+		Declaration: func(decl *ast.Declaration, ctx transforms.PluginContext) []*ast.Declaration {
+			if decl.Property == "color" {
+				return nil
+			}
+			return []*ast.Declaration{decl}
+		},
+	}
+
+	upperIdents := transforms.Plugin{
+		Name: "upper-idents",
+		Value: func(value ast.Value, ctx transforms.PluginContext) []ast.Value {
+			if ident, ok := value.(*ast.Identifier); ok {
+				ident.Value = strings.ToUpper(ident.Value)
+			}
+			return []ast.Value{value}
+		},
+	}
+
+	assert.Equal(t, ".x-a{display:BLOCK;width:min(AUTO,10px)}@media screen{.x-b{margin:AUTO}}", Transform(t, func(o *transformer.Options) {
+		o.Plugins = []transforms.Plugin{prefixClasses, dropColor, upperIdents}
+	}, `.a { color: red; display: block; width: min(auto, 10px) } @media screen { .b { margin: auto } }`))
+}
+
+func TestPlugins_MathValues(t *testing.T) {
+	pxToRem := transforms.Plugin{
+		Name: "px-to-rem",
+		Value: func(value ast.Value, ctx transforms.PluginContext) []ast.Value {
+			if d, ok := value.(*ast.Dimension); ok && d.Unit == "px" {
+				d.Unit = "rem"
+			}
+			return []ast.Value{value}
+		},
+	}
+
+	assert.Equal(t, ".a{width:calc(1rem + 2rem*3);margin:min(1rem,calc(2rem - 10%))}", Transform(t, func(o *transformer.Options) {
+		o.Plugins = []transforms.Plugin{pxToRem}
+	}, `.a { width: calc(1px + 2px * 3); margin: min(1px, calc(2px - 10%)) }`))
+
+	dropDimensions := transforms.Plugin{
+		Name: "drop-dimensions",
+		Value: func(value ast.Value, ctx transforms.PluginContext) []ast.Value {
+			if _, ok := value.(*ast.Dimension); ok {
+				return nil
+			}
+			return []ast.Value{value}
+		},
+	}
+
+	var errors collectingReporter
+	source := &sources.Source{Path: "main.css", Content: `.a { width: calc(1px + 2px) }`}
+	ss, err := parser.Parse(source)
+	require.NoError(t, err)
+	transformer.Transform(ss, transformer.Options{
+		OriginalSource: source,
+		Reporter:       &errors,
+		Options:        transforms.Options{Plugins: []transforms.Plugin{dropDimensions}},
+	})
+	require.Len(t, errors, 2)
+	assert.Contains(t, errors[0].Error(), "expected plugins to replace math operand with a single value, but got 0")
+}
+
+func TestPlugins_Rules(t *testing.T) {
+	removePrint := transforms.Plugin{
+		Name: "remove-print",
+		AtRule: func(rule *ast.AtRule, ctx transforms.PluginContext) []ast.Node {
+			if rule.Name == "media" {
+				if l, ok := rule.Preludes[0].(*ast.MediaQueryList); ok {
+					if ident, ok := l.Queries[0].Parts[0].(*ast.Identifier); ok && ident.Value == "print" {
+						return nil
+					}
+				}
+			}
+			return []ast.Node{rule}
+		},
+	}
+
+	duplicate := transforms.Plugin{
+		Name: "duplicate",
+		Rule: func(rule *ast.QualifiedRule, ctx transforms.PluginContext) []ast.Node {
+			return []ast.Node{rule, rule}
+		},
+	}
+
+	assert.Equal(t, ".a{color:red}.a{color:red}", Transform(t, func(o *transformer.Options) {
+		o.Plugins = []transforms.Plugin{removePrint, duplicate}
+	}, `.a { color: red } @media print { .b { color: blue } }`))
+}
+
+func TestPlugins_Stage(t *testing.T) {
+	// A plugin that adds :any-link before the built-in transforms has its output
+	// transformed, but one that runs after does not.
+	addAnyLink := func(stage transforms.PluginStage) transforms.Plugin {
+		return transforms.Plugin{
+			Name:  "add-any-link",
+			Stage: stage,
+			Selector: func(sel *ast.Selector, ctx transforms.PluginContext) []*ast.Selector {
+				sel.Parts = append(sel.Parts, &ast.PseudoClassSelector{Name: "any-link"})
+				return []*ast.Selector{sel}
+			},
+		}
+	}
+
+	assert.Equal(t, "a:visited,a:link{color:red}", Transform(t, func(o *transformer.Options) {
+		o.AnyLink = transforms.AnyLinkTransform
+		o.Plugins = []transforms.Plugin{addAnyLink(transforms.PluginStageBefore)}
+	}, `a{ color: red }`))
+
+	assert.Equal(t, "a:any-link{color:red}", Transform(t, func(o *transformer.Options) {
+		o.AnyLink = transforms.AnyLinkTransform
+		o.Plugins = []transforms.Plugin{addAnyLink(transforms.PluginStageAfter)}
+	}, `a{ color: red }`))
+}
+
+type collectingReporter []error
+
+func (r *collectingReporter) AddError(err error) {
+	*r = append(*r, err)
+}
+
+func TestPlugins_Errors(t *testing.T) {
+	source := &sources.Source{
+		Path:    "main.css",
+		Content: `.a { float: left; }`,
+	}
+	ss, err := parser.Parse(source)
+	require.NoError(t, err)
+
+	var stylesheets int
+	var errors collectingReporter
+	transformer.Transform(ss, transformer.Options{
+		OriginalSource: source,
+		Reporter:       &errors,
+		Options: transforms.Options{
+			Plugins: []transforms.Plugin{{
+				Name: "no-float",
+				Stylesheet: func(ss *ast.Stylesheet, ctx transforms.PluginContext) {
+					assert.Equal(t, "main.css", ctx.Path())
+					stylesheets++
+				},
+				Declaration: func(decl *ast.Declaration, ctx transforms.PluginContext) []*ast.Declaration {
+					if decl.Property == "float" {
+						ctx.Errorf(decl, "float is not allowed")
+					}
+					return []*ast.Declaration{decl}
+				},
+			}},
+		},
+	})
+
+	assert.Equal(t, 1, stylesheets)
+	require.Len(t, errors, 1)
+	assert.Contains(t, errors[0].Error(), "no-float: float is not allowed")
+	assert.Contains(t, errors[0].Error(), "main.css:1:5")
+}
@@ -0,0 +1,431 @@
+package transformer
+
+import (
+	"math"
+
+	"github.com/stephen/cssc/transforms"
+)
+
+// prefixed is a vendor-prefixed form of a property, value, selector or at-rule.
+type prefixed struct {
+	// name is the vendor prefix, e.g. -webkit-, for properties and functions, and
+	// the whole prefixed name for values, selectors and at-rules, e.g. -ms-flexbox,
+	// ::-moz-selection or -webkit-keyframes.
+	name string
+
+	// until is the first version of each browser that no longer needs the prefix.
+	// Browsers that never needed it are left out.
+	until map[transforms.Browser]transforms.Version
+}
+
+// never is used in until for browsers that still need a prefix.
+var never = transforms.Version{Major: math.MaxInt32}
+
+// The data below is from caniuse.com, MDN and autoprefixer. Versions of Android
+// before 37 are the version of Android, rather than of its Chromium.
+
+var (
+	transformPrefixes = []prefixed{
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 36},
+			transforms.Safari:    {Major: 9},
+			transforms.IOSSafari: {Major: 9},
+			transforms.Opera:     {Major: 23},
+			transforms.Android:   {Major: 37},
+		}},
+		{"-ms-", map[transforms.Browser]transforms.Version{
+			transforms.IE: {Major: 10},
+		}},
+	}
+
+	transform3DPrefixes = []prefixed{
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 36},
+			transforms.Safari:    {Major: 9},
+			transforms.IOSSafari: {Major: 9},
+			transforms.Opera:     {Major: 23},
+			transforms.Android:   {Major: 37},
+		}},
+	}
+
+	transitionPrefixes = []prefixed{
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 26},
+			transforms.Safari:    {Major: 6, Minor: 1},
+			transforms.IOSSafari: {Major: 7},
+			transforms.Android:   {Major: 4, Minor: 4},
+		}},
+	}
+
+	animationPrefixes = []prefixed{
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 43},
+			transforms.Safari:    {Major: 9},
+			transforms.IOSSafari: {Major: 9},
+			transforms.Opera:     {Major: 30},
+			transforms.Android:   {Major: 43},
+		}},
+	}
+
+	flexboxPrefixes = []prefixed{
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 29},
+			transforms.Safari:    {Major: 9},
+			transforms.IOSSafari: {Major: 9},
+			transforms.Android:   {Major: 4, Minor: 4},
+		}},
+	}
+
+	maskPrefixes = []prefixed{
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 120},
+			transforms.Edge:      {Major: 120},
+			transforms.Safari:    {Major: 15, Minor: 4},
+			transforms.IOSSafari: {Major: 15, Minor: 4},
+			transforms.Opera:     {Major: 106},
+			transforms.Samsung:   {Major: 25},
+			transforms.Android:   {Major: 120},
+		}},
+	}
+
+	intrinsicSizePrefixes = []prefixed{
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 46},
+			transforms.Safari:    {Major: 11},
+			transforms.IOSSafari: {Major: 11},
+			transforms.Opera:     {Major: 33},
+			transforms.Android:   {Major: 46},
+		}},
+		{"-moz-", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 66},
+		}},
+	}
+
+	gradientPrefixes = []prefixed{
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 26},
+			transforms.Safari:    {Major: 6, Minor: 1},
+			transforms.IOSSafari: {Major: 7},
+			transforms.Android:   {Major: 4, Minor: 4},
+		}},
+		{"-moz-", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 16},
+		}},
+	}
+)
+
+// propertyPrefixes is the prefixes of each property, in the order they are added.
+var propertyPrefixes = map[string][]prefixed{
+	"user-select": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 54},
+			transforms.Safari:    never,
+			transforms.IOSSafari: never,
+			transforms.Opera:     {Major: 41},
+			transforms.Samsung:   {Major: 6, Minor: 2},
+			transforms.Android:   {Major: 54},
+		}},
+		{"-moz-", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 69},
+		}},
+		{"-ms-", map[transforms.Browser]transforms.Version{
+			transforms.Edge: {Major: 79},
+			transforms.IE:   never,
+		}},
+	},
+	"appearance": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 84},
+			transforms.Edge:      {Major: 84},
+			transforms.Safari:    {Major: 15, Minor: 4},
+			transforms.IOSSafari: {Major: 15, Minor: 4},
+			transforms.Opera:     {Major: 70},
+			transforms.Samsung:   {Major: 14},
+			transforms.Android:   {Major: 84},
+		}},
+		{"-moz-", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 80},
+		}},
+	},
+	"backdrop-filter": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Safari:    {Major: 18},
+			transforms.IOSSafari: {Major: 18},
+		}},
+	},
+	"text-size-adjust": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Safari:    never,
+			transforms.IOSSafari: never,
+		}},
+	},
+	"hyphens": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Safari:    {Major: 17},
+			transforms.IOSSafari: {Major: 17},
+		}},
+		{"-moz-", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 43},
+		}},
+		{"-ms-", map[transforms.Browser]transforms.Version{
+			transforms.Edge: {Major: 79},
+			transforms.IE:   never,
+		}},
+	},
+	"tab-size": {
+		{"-moz-", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 91},
+		}},
+	},
+	"box-decoration-break": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 130},
+			transforms.Edge:      {Major: 130},
+			transforms.Safari:    never,
+			transforms.IOSSafari: never,
+			transforms.Opera:     {Major: 116},
+			transforms.Android:   {Major: 130},
+		}},
+	},
+	"clip-path": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 55},
+			transforms.Safari:    {Major: 13, Minor: 1},
+			transforms.IOSSafari: {Major: 13},
+			transforms.Opera:     {Major: 42},
+			transforms.Samsung:   {Major: 6, Minor: 2},
+			transforms.Android:   {Major: 55},
+		}},
+	},
+	"print-color-adjust": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 136},
+			transforms.Edge:      {Major: 136},
+			transforms.Safari:    {Major: 15, Minor: 4},
+			transforms.IOSSafari: {Major: 15, Minor: 4},
+			transforms.Opera:     never,
+			transforms.Samsung:   never,
+			transforms.Android:   {Major: 136},
+		}},
+	},
+	"border-radius": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 5},
+			transforms.Safari:    {Major: 5},
+			transforms.IOSSafari: {Major: 4},
+			transforms.Android:   {Major: 2, Minor: 2},
+		}},
+		{"-moz-", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 4},
+		}},
+	},
+	"box-shadow": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 10},
+			transforms.Safari:    {Major: 5, Minor: 1},
+			transforms.IOSSafari: {Major: 5},
+			transforms.Android:   {Major: 4},
+		}},
+		{"-moz-", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 4},
+		}},
+	},
+
+	"transform":           transformPrefixes,
+	"transform-origin":    transformPrefixes,
+	"transform-style":     transform3DPrefixes,
+	"perspective":         transform3DPrefixes,
+	"perspective-origin":  transform3DPrefixes,
+	"backface-visibility": transform3DPrefixes,
+
+	"transition":                 transitionPrefixes,
+	"transition-property":        transitionPrefixes,
+	"transition-duration":        transitionPrefixes,
+	"transition-timing-function": transitionPrefixes,
+	"transition-delay":           transitionPrefixes,
+
+	"animation":                 animationPrefixes,
+	"animation-name":            animationPrefixes,
+	"animation-duration":        animationPrefixes,
+	"animation-timing-function": animationPrefixes,
+	"animation-delay":           animationPrefixes,
+	"animation-iteration-count": animationPrefixes,
+	"animation-direction":       animationPrefixes,
+	"animation-fill-mode":       animationPrefixes,
+	"animation-play-state":      animationPrefixes,
+
+	"flex":            flexboxPrefixes,
+	"flex-grow":       flexboxPrefixes,
+	"flex-shrink":     flexboxPrefixes,
+	"flex-basis":      flexboxPrefixes,
+	"flex-direction":  flexboxPrefixes,
+	"flex-wrap":       flexboxPrefixes,
+	"flex-flow":       flexboxPrefixes,
+	"justify-content": flexboxPrefixes,
+	"align-items":     flexboxPrefixes,
+	"align-self":      flexboxPrefixes,
+	"align-content":   flexboxPrefixes,
+	"order":           flexboxPrefixes,
+
+	"mask":          maskPrefixes,
+	"mask-image":    maskPrefixes,
+	"mask-size":     maskPrefixes,
+	"mask-position": maskPrefixes,
+	"mask-repeat":   maskPrefixes,
+	"mask-origin":   maskPrefixes,
+	"mask-clip":     maskPrefixes,
+}
+
+// valuePrefixes is the prefixed forms of identifier values, for the properties
+// they are prefixed in. The names are the whole prefixed value.
+var valuePrefixes = map[string]map[string][]prefixed{
+	"display": {
+		"flex": {
+			{"-webkit-flex", flexboxPrefixes[0].until},
+			{"-ms-flexbox", map[transforms.Browser]transforms.Version{transforms.IE: {Major: 11}}},
+		},
+		"inline-flex": {
+			{"-webkit-inline-flex", flexboxPrefixes[0].until},
+			{"-ms-inline-flexbox", map[transforms.Browser]transforms.Version{transforms.IE: {Major: 11}}},
+		},
+	},
+	"position": {
+		"sticky": {
+			{"-webkit-sticky", map[transforms.Browser]transforms.Version{
+				transforms.Safari:    {Major: 13},
+				transforms.IOSSafari: {Major: 13},
+			}},
+		},
+	},
+}
+
+// intrinsicSizeProperties is the set of properties that take min-content,
+// max-content and fit-content values.
+var intrinsicSizeProperties = []string{"width", "min-width", "max-width", "height", "min-height", "max-height", "flex-basis", "inline-size", "block-size"}
+
+func init() {
+	for _, property := range intrinsicSizeProperties {
+		values := make(map[string][]prefixed)
+		for _, value := range []string{"min-content", "max-content", "fit-content"} {
+			for _, p := range intrinsicSizePrefixes {
+				until := p.until
+				if value == "fit-content" && p.name == "-moz-" {
+					until = map[transforms.Browser]transforms.Version{transforms.Firefox: {Major: 94}}
+				}
+				values[value] = append(values[value], prefixed{p.name + value, until})
+			}
+		}
+		valuePrefixes[property] = values
+	}
+}
+
+// functionPrefixes is the prefixes of each function.
+var functionPrefixes = map[string][]prefixed{
+	"linear-gradient":           gradientPrefixes,
+	"repeating-linear-gradient": gradientPrefixes,
+	"image-set": {
+		{"-webkit-", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 113},
+			transforms.Edge:      {Major: 113},
+			transforms.Safari:    {Major: 17},
+			transforms.IOSSafari: {Major: 17},
+			transforms.Opera:     {Major: 99},
+			transforms.Samsung:   {Major: 23},
+			transforms.Android:   {Major: 113},
+		}},
+	},
+}
+
+// selectorPrefixes is the prefixed forms of pseudo-classes and pseudo-elements.
+// Names start with : for pseudo-classes, and :: for pseudo-elements.
+var selectorPrefixes = map[string][]prefixed{
+	"::selection": {
+		{"::-moz-selection", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 62},
+		}},
+	},
+	"::placeholder": {
+		{"::-webkit-input-placeholder", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 57},
+			transforms.Safari:    {Major: 10, Minor: 1},
+			transforms.IOSSafari: {Major: 10, Minor: 3},
+			transforms.Opera:     {Major: 44},
+			transforms.Samsung:   {Major: 7},
+			transforms.Android:   {Major: 57},
+		}},
+		{"::-moz-placeholder", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 51},
+		}},
+		{":-ms-input-placeholder", map[transforms.Browser]transforms.Version{
+			transforms.IE: never,
+		}},
+		{"::-ms-input-placeholder", map[transforms.Browser]transforms.Version{
+			transforms.Edge: {Major: 79},
+		}},
+	},
+	":fullscreen": {
+		{":-webkit-full-screen", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 71},
+			transforms.Safari:    {Major: 16, Minor: 4},
+			transforms.IOSSafari: {Major: 16, Minor: 4},
+			transforms.Opera:     {Major: 58},
+			transforms.Samsung:   {Major: 10},
+			transforms.Android:   {Major: 71},
+		}},
+		{":-moz-full-screen", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 64},
+		}},
+		{":-ms-fullscreen", map[transforms.Browser]transforms.Version{
+			transforms.Edge: {Major: 79},
+			transforms.IE:   never,
+		}},
+	},
+	":read-only": {
+		{":-moz-read-only", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 78},
+		}},
+	},
+	":read-write": {
+		{":-moz-read-write", map[transforms.Browser]transforms.Version{
+			transforms.Firefox: {Major: 78},
+		}},
+	},
+	"::file-selector-button": {
+		{"::-webkit-file-upload-button", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 89},
+			transforms.Edge:      {Major: 89},
+			transforms.Safari:    {Major: 14, Minor: 1},
+			transforms.IOSSafari: {Major: 14, Minor: 5},
+			transforms.Opera:     {Major: 75},
+			transforms.Samsung:   {Major: 15},
+			transforms.Android:   {Major: 89},
+		}},
+	},
+	":autofill": {
+		{":-webkit-autofill", map[transforms.Browser]transforms.Version{
+			transforms.Chrome:    {Major: 110},
+			transforms.Edge:      {Major: 110},
+			transforms.Safari:    {Major: 15},
+			transforms.IOSSafari: {Major: 15},
+			transforms.Opera:     {Major: 96},
+			transforms.Samsung:   {Major: 21},
+			transforms.Android:   {Major: 110},
+		}},
+	},
+	"::backdrop": {
+		{"::-webkit-backdrop", map[transforms.Browser]transforms.Version{
+			transforms.Safari:    {Major: 15, Minor: 4},
+			transforms.IOSSafari: {Major: 15, Minor: 4},
+		}},
+		{"::-ms-backdrop", map[transforms.Browser]transforms.Version{
+			transforms.IE: never,
+		}},
+	},
+}
+
+// atRulePrefixes is the prefixed forms of at-rules.
+var atRulePrefixes = map[string][]prefixed{
+	"keyframes": {
+		{"-webkit-keyframes", animationPrefixes[0].until},
+	},
+}
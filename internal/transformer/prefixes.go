@@ -0,0 +1,711 @@
+package transformer
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/stephen/cssc/ast"
+)
+
+// addPrefixes adds the vendor-prefixed forms of nodes that the targets need. Prefixed
+// rules and declarations are added before the unprefixed ones, so that the standard
+// syntax wins in browsers that support both.
+func (t *transformer) addPrefixes(nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+	for _, node := range nodes {
+		// Inlined imports were already prefixed with their own file.
+		if _, ok := t.inlined[node]; ok {
+			rv = append(rv, node)
+			continue
+		}
+
+		switch node := node.(type) {
+		case *ast.QualifiedRule:
+			t.prefixBlock(node.Block)
+			if selectors, ok := node.Prelude.(*ast.SelectorList); ok {
+				rv = append(rv, t.prefixedRules(node, selectors)...)
+			}
+
+		case *ast.AtRule:
+			// The prefixed copies are made first, so that they only get their own prefix.
+			rv = append(rv, t.prefixedAtRules(node, nodes)...)
+			t.prefixBlock(node.Block)
+		}
+
+		rv = append(rv, node)
+	}
+
+	return rv
+}
+
+func (t *transformer) prefixBlock(block ast.Block) {
+	switch block := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		block.Rules = t.addPrefixes(block.Rules)
+
+	case *ast.DeclarationBlock:
+		block.Declarations = t.prefixDeclarations(block.Declarations)
+		block.Rules = t.addPrefixes(block.Rules)
+	}
+}
+
+// neededPrefixes returns the prefixed forms that any of the targets need.
+func (t *transformer) neededPrefixes(forms []prefixed) []prefixed {
+	var rv []prefixed
+	for _, p := range forms {
+		if t.onlyPrefix != "" && vendorPrefix(p.name) != t.onlyPrefix {
+			continue
+		}
+
+		if t.needs(p) {
+			rv = append(rv, p)
+		}
+	}
+
+	return rv
+}
+
+// needs returns whether or not any of the targets need p.
+func (t *transformer) needs(p prefixed) bool {
+	for b, v := range t.Targets {
+		if until, ok := p.until[b]; ok && v.Less(until) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// vendorPrefix returns the vendor prefix of a name, e.g. -webkit- for -webkit-keyframes
+// or ::-webkit-backdrop, or an empty string if it has none.
+func vendorPrefix(name string) string {
+	name = strings.TrimLeft(name, ":")
+	if !strings.HasPrefix(name, "-") {
+		return ""
+	}
+
+	if i := strings.IndexByte(name[1:], '-'); i > 0 {
+		return name[:i+2]
+	}
+
+	return ""
+}
+
+// prefixDeclarations adds the prefixed forms of each declaration's property
+// and values before it.
+func (t *transformer) prefixDeclarations(decls []*ast.Declaration) []*ast.Declaration {
+	rv := make([]*ast.Declaration, 0, len(decls))
+	for _, d := range decls {
+		for _, p := range t.neededPrefixes(propertyPrefixes[d.Property]) {
+			property := p.name + d.Property
+			if hasDeclaration(decls, property, "") {
+				continue
+			}
+
+			rv = append(rv, &ast.Declaration{
+				Loc:       d.Loc,
+				Property:  property,
+				Values:    append([]ast.Value(nil), d.Values...),
+				Important: d.Important,
+			})
+		}
+
+		rv = append(rv, t.prefixedValues(d, decls)...)
+		rv = append(rv, d)
+	}
+
+	return rv
+}
+
+// prefixedValues returns copies of d with its values replaced by their prefixed forms,
+// one for each vendor prefix that is needed.
+func (t *transformer) prefixedValues(d *ast.Declaration, decls []*ast.Declaration) []*ast.Declaration {
+	var vendors []string
+	byVendor := make(map[string]map[int]ast.Value)
+
+	for i, v := range d.Values {
+		switch v := v.(type) {
+		case *ast.Identifier:
+			for _, p := range t.neededPrefixes(valuePrefixes[d.Property][v.Value]) {
+				vendor := vendorPrefix(p.name)
+				if hasDeclaration(decls, d.Property, p.name) {
+					continue
+				}
+
+				if byVendor[vendor] == nil {
+					vendors = append(vendors, vendor)
+					byVendor[vendor] = make(map[int]ast.Value)
+				}
+				byVendor[vendor][i] = &ast.Identifier{Loc: v.Loc, Value: p.name}
+			}
+
+		case *ast.Function:
+			for _, p := range t.neededPrefixes(functionPrefixes[v.Name]) {
+				name := p.name + v.Name
+				if hasDeclaration(decls, d.Property, name) {
+					continue
+				}
+
+				f, ok := prefixedFunction(v, name)
+				if !ok {
+					continue
+				}
+
+				if byVendor[p.name] == nil {
+					vendors = append(vendors, p.name)
+					byVendor[p.name] = make(map[int]ast.Value)
+				}
+				byVendor[p.name][i] = f
+			}
+		}
+	}
+
+	rv := make([]*ast.Declaration, 0, len(vendors))
+	for _, vendor := range vendors {
+		values := append([]ast.Value(nil), d.Values...)
+		for i, v := range byVendor[vendor] {
+			values[i] = v
+		}
+
+		rv = append(rv, &ast.Declaration{
+			Loc:       d.Loc,
+			Property:  d.Property,
+			Values:    values,
+			Important: d.Important,
+		})
+	}
+
+	return rv
+}
+
+// hasDeclaration returns whether or not decls has a declaration of property. If
+// value is set, the declaration must also have an identifier or function named value.
+func hasDeclaration(decls []*ast.Declaration, property, value string) bool {
+	for _, d := range decls {
+		if d.Property != property {
+			continue
+		}
+
+		if value == "" {
+			return true
+		}
+
+		for _, v := range d.Values {
+			switch v := v.(type) {
+			case *ast.Identifier:
+				if v.Value == value {
+					return true
+				}
+
+			case *ast.Function:
+				if v.Name == value {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// prefixedFunction returns f renamed to name. Gradients are converted to the legacy
+// syntax that prefixed gradients use, which returns false if that isn't possible.
+func prefixedFunction(f *ast.Function, name string) (*ast.Function, bool) {
+	rv := &ast.Function{Loc: f.Loc, Name: name, Arguments: f.Arguments}
+	if !strings.HasSuffix(f.Name, "linear-gradient") || len(f.Arguments) == 0 {
+		return rv, true
+	}
+
+	args := f.Arguments
+	switch first := args[0].(type) {
+	case *ast.Identifier:
+		if first.Value != "to" {
+			break
+		}
+
+		// The legacy syntax has the side that the gradient starts from, rather than
+		// the one it goes to, e.g. to right becomes left.
+		var sides []ast.Value
+		i := 1
+		for ; i < len(args); i++ {
+			side, ok := args[i].(*ast.Identifier)
+			if !ok {
+				break
+			}
+
+			opposite, ok := oppositeSides[side.Value]
+			if !ok {
+				return nil, false
+			}
+			sides = append(sides, &ast.Identifier{Loc: side.Loc, Value: opposite})
+		}
+
+		if len(sides) == 0 {
+			return nil, false
+		}
+		rv.Arguments = append(sides, args[i:]...)
+
+	case *ast.Dimension:
+		if first.Unit != "deg" {
+			return nil, false
+		}
+
+		// The legacy syntax measures angles counter-clockwise from the right, rather
+		// than clockwise from the top.
+		deg, err := strconv.ParseFloat(first.Value, 64)
+		if err != nil {
+			return nil, false
+		}
+		deg = math.Mod(450-deg, 360)
+		if deg < 0 {
+			deg += 360
+		}
+
+		rv.Arguments = append([]ast.Value{&ast.Dimension{
+			Loc:   first.Loc,
+			Value: strconv.FormatFloat(deg, 'f', -1, 64),
+			Unit:  "deg",
+		}}, args[1:]...)
+	}
+
+	return rv, true
+}
+
+var oppositeSides = map[string]string{
+	"top":    "bottom",
+	"bottom": "top",
+	"left":   "right",
+	"right":  "left",
+}
+
+// prefixedRules returns copies of rule for each prefixed form of the pseudo-classes
+// and pseudo-elements in its selectors. They are separate rules, since browsers drop
+// a whole rule if they don't recognize one of its selectors.
+func (t *transformer) prefixedRules(rule *ast.QualifiedRule, selectors *ast.SelectorList) []ast.Node {
+	var names []string
+	byName := make(map[string]*ast.SelectorList)
+
+	for _, sel := range selectors.Selectors {
+		for i, part := range sel.Parts {
+			for _, p := range t.neededPrefixes(selectorPrefixes[pseudoName(part)]) {
+				parts := append([]ast.SelectorPart(nil), sel.Parts...)
+				parts[i] = newPseudo(p.name, part.Location())
+
+				l, ok := byName[p.name]
+				if !ok {
+					l = &ast.SelectorList{Loc: selectors.Loc}
+					byName[p.name] = l
+					names = append(names, p.name)
+				}
+				l.Selectors = append(l.Selectors, &ast.Selector{Loc: sel.Loc, Parts: parts})
+			}
+		}
+	}
+
+	rv := make([]ast.Node, 0, len(names))
+	for _, name := range names {
+		rv = append(rv, &ast.QualifiedRule{
+			Loc:     rule.Loc,
+			Prelude: byName[name],
+			Block:   cloneBlock(rule.Block),
+		})
+	}
+
+	return rv
+}
+
+// pseudoName returns the name of a pseudo-class, starting with :, or pseudo-element,
+// starting with ::. It returns an empty string for other parts and functional pseudo-classes.
+func pseudoName(part ast.SelectorPart) string {
+	switch p := part.(type) {
+	case *ast.PseudoClassSelector:
+		if p.Arguments == nil {
+			return ":" + p.Name
+		}
+
+	case *ast.PseudoElementSelector:
+		if p.Inner != nil && p.Inner.Arguments == nil {
+			return "::" + p.Inner.Name
+		}
+	}
+
+	return ""
+}
+
+// newPseudo returns a pseudo-class or pseudo-element for a name from pseudoName.
+func newPseudo(name string, loc ast.Loc) ast.SelectorPart {
+	if strings.HasPrefix(name, "::") {
+		return &ast.PseudoElementSelector{
+			Loc:   loc,
+			Inner: &ast.PseudoClassSelector{Loc: loc, Name: name[2:]},
+		}
+	}
+
+	return &ast.PseudoClassSelector{Loc: loc, Name: name[1:]}
+}
+
+// prefixedAtRules returns the prefixed copies of rule that the targets need, unless
+// siblings already has them.
+func (t *transformer) prefixedAtRules(rule *ast.AtRule, siblings []ast.Node) []ast.Node {
+	var rv []ast.Node
+	for _, p := range t.neededPrefixes(atRulePrefixes[rule.Name]) {
+		if hasAtRule(siblings, p.name, atRuleName(rule)) {
+			continue
+		}
+
+		prefixed := &ast.AtRule{
+			Loc:      rule.Loc,
+			Name:     p.name,
+			Preludes: rule.Preludes,
+			Block:    cloneBlock(rule.Block),
+		}
+
+		// Only add the at-rule's own prefix inside of it, e.g. -webkit-transform inside
+		// of @-webkit-keyframes.
+		only := t.onlyPrefix
+		t.onlyPrefix = vendorPrefix(p.name)
+		t.prefixBlock(prefixed.Block)
+		t.onlyPrefix = only
+
+		rv = append(rv, prefixed)
+	}
+
+	return rv
+}
+
+// hasAtRule returns whether or not nodes has an at-rule with the given name, whose
+// first prelude is named prelude, e.g. @-webkit-keyframes fade.
+func hasAtRule(nodes []ast.Node, name, prelude string) bool {
+	for _, n := range nodes {
+		if r, ok := n.(*ast.AtRule); ok && r.Name == name && atRuleName(r) == prelude {
+			return true
+		}
+	}
+
+	return false
+}
+
+// atRuleName returns the first prelude of an at-rule if it is a name, e.g. the
+// name of @keyframes.
+func atRuleName(r *ast.AtRule) string {
+	if len(r.Preludes) == 0 {
+		return ""
+	}
+
+	switch p := r.Preludes[0].(type) {
+	case *ast.Identifier:
+		return p.Value
+	case *ast.String:
+		return p.Value
+	}
+
+	return ""
+}
+
+// cloneBlock makes a copy of a block and the rules and declarations in it, so that
+// they can be transformed separately. Values are not copied.
+func cloneBlock(block ast.Block) ast.Block {
+	switch block := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		return &ast.QualifiedRuleBlock{
+			Loc:   block.Loc,
+			Rules: cloneNodes(block.Rules),
+		}
+
+	case *ast.DeclarationBlock:
+		rv := &ast.DeclarationBlock{
+			Loc:          block.Loc,
+			Declarations: make([]*ast.Declaration, 0, len(block.Declarations)),
+			Rules:        cloneNodes(block.Rules),
+		}
+
+		for _, d := range block.Declarations {
+			copy := *d
+			copy.Values = append([]ast.Value(nil), d.Values...)
+			rv.Declarations = append(rv.Declarations, &copy)
+		}
+
+		return rv
+	}
+
+	return block
+}
+
+func cloneNodes(nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.QualifiedRule:
+			copy := *n
+			if l, ok := n.Prelude.(*ast.SelectorList); ok {
+				copy.Prelude = cloneSelectorList(l)
+			}
+			copy.Block = cloneBlock(n.Block)
+			rv = append(rv, &copy)
+
+		case *ast.AtRule:
+			copy := *n
+			copy.Block = cloneBlock(n.Block)
+			rv = append(rv, &copy)
+
+		default:
+			rv = append(rv, n)
+		}
+	}
+
+	return rv
+}
+
+// removePrefixes removes vendor-prefixed declarations, rules and at-rules that none
+// of the targets need, if their unprefixed forms are next to them.
+func (t *transformer) removePrefixes(nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+	for _, node := range nodes {
+		// Inlined imports were already handled with their own file.
+		if _, ok := t.inlined[node]; ok {
+			rv = append(rv, node)
+			continue
+		}
+
+		switch node := node.(type) {
+		case *ast.QualifiedRule:
+			if selectors, ok := node.Prelude.(*ast.SelectorList); ok {
+				if name, ok := t.outdatedRule(selectors, nodes); ok {
+					t.warnRemoved(node, name)
+					continue
+				}
+			}
+			t.removeBlockPrefixes(node.Block)
+
+		case *ast.AtRule:
+			if t.outdatedAtRule(node, nodes) {
+				t.warnRemoved(node, "@"+node.Name)
+				continue
+			}
+			t.removeBlockPrefixes(node.Block)
+		}
+
+		rv = append(rv, node)
+	}
+
+	return rv
+}
+
+func (t *transformer) removeBlockPrefixes(block ast.Block) {
+	switch block := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		block.Rules = t.removePrefixes(block.Rules)
+
+	case *ast.DeclarationBlock:
+		decls := make([]*ast.Declaration, 0, len(block.Declarations))
+		for _, d := range block.Declarations {
+			if name, ok := t.outdatedDeclaration(d, block.Declarations); ok {
+				t.warnRemoved(d, name)
+				continue
+			}
+			decls = append(decls, d)
+		}
+
+		block.Declarations = decls
+		block.Rules = t.removePrefixes(block.Rules)
+	}
+}
+
+func (t *transformer) warnRemoved(node ast.Node, name string) {
+	if t.WarnRemovedPrefixes {
+		t.addWarn(node.Location(), "removed outdated vendor prefix: %s", name)
+	}
+}
+
+// outdatedDeclaration returns whether or not d has a prefixed property or values that
+// the targets don't need, and decls has the unprefixed form. It also returns the
+// prefixed name.
+func (t *transformer) outdatedDeclaration(d *ast.Declaration, decls []*ast.Declaration) (string, bool) {
+	if vendor := vendorPrefix(d.Property); vendor != "" {
+		property := strings.TrimPrefix(d.Property, vendor)
+		p, ok := findPrefix(propertyPrefixes[property], vendor)
+		return d.Property, ok && !t.needs(p) && hasDeclaration(decls, property, "")
+	}
+
+	// Otherwise, look for prefixed values, e.g. display: -webkit-flex. All of them
+	// must be outdated.
+	var name string
+	for _, v := range d.Values {
+		var unprefixed string
+		switch v := v.(type) {
+		case *ast.Identifier:
+			if vendorPrefix(v.Value) == "" {
+				continue
+			}
+
+			u, p, ok := findPrefixed(valuePrefixes[d.Property], v.Value)
+			if !ok || t.needs(p) {
+				return "", false
+			}
+			unprefixed, name = u, v.Value
+
+		case *ast.Function:
+			vendor := vendorPrefix(v.Name)
+			if vendor == "" {
+				continue
+			}
+
+			u := strings.TrimPrefix(v.Name, vendor)
+			p, ok := findPrefix(functionPrefixes[u], vendor)
+			if !ok || t.needs(p) {
+				return "", false
+			}
+			unprefixed, name = u, v.Name
+
+		default:
+			continue
+		}
+
+		if !hasDeclaration(decls, d.Property, unprefixed) {
+			return "", false
+		}
+	}
+
+	return name, name != ""
+}
+
+// outdatedRule returns whether or not every selector in selectors has prefixed
+// pseudo-classes or pseudo-elements that the targets don't need, and siblings has a
+// rule with the unprefixed selector. It also returns the prefixed name.
+func (t *transformer) outdatedRule(selectors *ast.SelectorList, siblings []ast.Node) (string, bool) {
+	var name string
+	for _, sel := range selectors.Selectors {
+		parts := append([]ast.SelectorPart(nil), sel.Parts...)
+		prefixed := false
+		for i, part := range parts {
+			pseudo := pseudoName(part)
+			if vendorPrefix(pseudo) == "" {
+				continue
+			}
+
+			unprefixed, p, ok := findPrefixed(selectorPrefixes, pseudo)
+			if !ok || t.needs(p) {
+				return "", false
+			}
+
+			parts[i] = newPseudo(unprefixed, part.Location())
+			name, prefixed = pseudo, true
+		}
+
+		if !prefixed || !hasSelector(siblings, selectorKey(parts)) {
+			return "", false
+		}
+	}
+
+	return name, name != ""
+}
+
+// outdatedAtRule returns whether or not r is a prefixed at-rule that the targets
+// don't need, and siblings has the unprefixed one.
+func (t *transformer) outdatedAtRule(r *ast.AtRule, siblings []ast.Node) bool {
+	vendor := vendorPrefix(r.Name)
+	if vendor == "" {
+		return false
+	}
+
+	name := strings.TrimPrefix(r.Name, vendor)
+	p, ok := findPrefix(atRulePrefixes[name], r.Name)
+	return ok && !t.needs(p) && hasAtRule(siblings, name, atRuleName(r))
+}
+
+// findPrefix returns the form in forms with the given name.
+func findPrefix(forms []prefixed, name string) (prefixed, bool) {
+	for _, p := range forms {
+		if p.name == name {
+			return p, true
+		}
+	}
+
+	return prefixed{}, false
+}
+
+// findPrefixed returns the unprefixed key in m that has a form with the given name.
+func findPrefixed(m map[string][]prefixed, name string) (string, prefixed, bool) {
+	for unprefixed, forms := range m {
+		if p, ok := findPrefix(forms, name); ok {
+			return unprefixed, p, true
+		}
+	}
+
+	return "", prefixed{}, false
+}
+
+// hasSelector returns whether or not nodes has a rule with a selector that has the
+// given key from selectorKey.
+func hasSelector(nodes []ast.Node, key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for _, n := range nodes {
+		r, ok := n.(*ast.QualifiedRule)
+		if !ok {
+			continue
+		}
+
+		l, ok := r.Prelude.(*ast.SelectorList)
+		if !ok {
+			continue
+		}
+
+		for _, sel := range l.Selectors {
+			if selectorKey(sel.Parts) == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// selectorKey returns a string that is the same for equal selectors, or an
+// empty string if the selector has parts that can't be compared, like functional
+// pseudo-classes.
+func selectorKey(parts []ast.SelectorPart) string {
+	parts = trimWhitespace(withoutComments(parts))
+
+	var b strings.Builder
+	for i, part := range parts {
+		switch p := part.(type) {
+		case *ast.TypeSelector:
+			b.WriteString(p.Name)
+		case *ast.ClassSelector:
+			b.WriteString("." + p.Name)
+		case *ast.IDSelector:
+			b.WriteString("#" + p.Name)
+		case *ast.CombinatorSelector:
+			b.WriteString(p.Operator)
+		case *ast.NestingSelector:
+			b.WriteString("&")
+		case *ast.Whitespace:
+			// Whitespace around combinators doesn't matter.
+			if !isOperator(parts, i-1) && !isOperator(parts, i+1) {
+				b.WriteString(" ")
+			}
+		default:
+			name := pseudoName(part)
+			if name == "" {
+				return ""
+			}
+			b.WriteString(name)
+		}
+	}
+
+	return b.String()
+}
+
+// isOperator returns whether or not parts[i] is a combinator like > or +.
+func isOperator(parts []ast.SelectorPart, i int) bool {
+	if i < 0 || i >= len(parts) {
+		return false
+	}
+
+	_, ok := parts[i].(*ast.CombinatorSelector)
+	return ok
+}
@@ -0,0 +1,135 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addPrefixes(t *testing.T, queries string) func(o *transformer.Options) {
+	targets, err := transforms.ParseTargets(queries)
+	require.NoError(t, err)
+
+	return func(o *transformer.Options) {
+		o.Prefixes = transforms.PrefixesAdd
+		o.Targets = targets
+	}
+}
+
+func TestPrefixes_Properties(t *testing.T) {
+	assert.Equal(t, ".a{-webkit-user-select:none;-moz-user-select:none;-ms-user-select:none;user-select:none}", Transform(t, addPrefixes(t, "safari >= 15, firefox >= 60, ie 11"), `.a { user-select: none; }`))
+	assert.Equal(t, ".a{-webkit-user-select:none;user-select:none}", Transform(t, addPrefixes(t, "safari >= 15"), `.a { user-select: none; }`))
+	assert.Equal(t, ".a{user-select:none}", Transform(t, addPrefixes(t, "chrome >= 100"), `.a { user-select: none; }`))
+
+	// Prefixes that are already there aren't added again.
+	assert.Equal(t, ".a{-webkit-transform:scale(2);transform:scale(2)}", Transform(t, addPrefixes(t, "chrome >= 30"), `.a { -webkit-transform: scale(2); transform: scale(2); }`))
+
+	assert.Equal(t, ".a{-webkit-transition:opacity 1s!important;transition:opacity 1s!important}", Transform(t, addPrefixes(t, "safari >= 6"), `.a { transition: opacity 1s !important; }`))
+}
+
+func TestPrefixes_Values(t *testing.T) {
+	assert.Equal(t, ".a{display:-webkit-flex;display:-ms-flexbox;display:flex}", Transform(t, addPrefixes(t, "safari >= 8, ie 10"), `.a { display: flex; }`))
+	assert.Equal(t, ".a{position:-webkit-sticky;position:sticky}", Transform(t, addPrefixes(t, "ios_saf >= 12"), `.a { position: sticky; }`))
+	assert.Equal(t, ".a{width:-moz-fit-content;width:fit-content}", Transform(t, addPrefixes(t, "firefox >= 90"), `.a { width: fit-content; }`))
+	assert.Equal(t, ".a{position:sticky}", Transform(t, addPrefixes(t, "safari >= 13"), `.a { position: sticky; }`))
+
+	assert.Equal(t, ".a{position:-webkit-sticky;position:sticky}", Transform(t, addPrefixes(t, "safari >= 12"), `.a { position: -webkit-sticky; position: sticky; }`))
+}
+
+func TestPrefixes_Gradients(t *testing.T) {
+	assert.Equal(t, ".a{background:-webkit-linear-gradient(left,red,blue);background:-moz-linear-gradient(left,red,blue);background:linear-gradient(to right,red,blue)}", Transform(t, addPrefixes(t, "safari >= 5, firefox >= 10"), `.a { background: linear-gradient(to right, red, blue); }`))
+	assert.Equal(t, ".a{background:-webkit-linear-gradient(bottom right,red,blue);background:linear-gradient(to top left,red,blue)}", Transform(t, addPrefixes(t, "safari >= 5"), `.a { background: linear-gradient(to top left, red, blue); }`))
+	assert.Equal(t, ".a{background:-webkit-linear-gradient(0deg,red,blue),url(a.png);background:linear-gradient(90deg,red,blue),url(a.png)}", Transform(t, addPrefixes(t, "safari >= 5"), `.a { background: linear-gradient(90deg, red, blue), url(a.png); }`))
+	assert.Equal(t, ".a{background:-webkit-linear-gradient(red,blue);background:linear-gradient(red,blue)}", Transform(t, addPrefixes(t, "safari >= 5"), `.a { background: linear-gradient(red, blue); }`))
+
+	// Angles in other units can't be converted, so they're left unprefixed.
+	assert.Equal(t, ".a{background:linear-gradient(.25turn,red,blue)}", Transform(t, addPrefixes(t, "safari >= 5"), `.a { background: linear-gradient(.25turn, red, blue); }`))
+}
+
+func TestPrefixes_Selectors(t *testing.T) {
+	assert.Equal(t, "::-moz-selection{color:red}::selection{color:red}", Transform(t, addPrefixes(t, "firefox >= 60"), `::selection { color: red; }`))
+	assert.Equal(t, "input::-webkit-input-placeholder{color:gray}input::-moz-placeholder{color:gray}input:-ms-input-placeholder{color:gray}input::placeholder{color:gray}", Transform(t, addPrefixes(t, "chrome >= 50, firefox >= 50, ie 11"), `input::placeholder { color: gray; }`))
+	assert.Equal(t, ".a:-webkit-full-screen,.b:-webkit-full-screen{color:red}.a:fullscreen,.b:fullscreen{color:red}", Transform(t, addPrefixes(t, "safari >= 15"), `.a:fullscreen, .b:fullscreen { color: red; }`))
+
+	// Declarations in the copies are prefixed too.
+	assert.Equal(t, "::-moz-selection{-moz-user-select:none;user-select:none}::selection{-moz-user-select:none;user-select:none}", Transform(t, addPrefixes(t, "firefox >= 60"), `::selection { user-select: none; }`))
+}
+
+func TestPrefixes_Keyframes(t *testing.T) {
+	assert.Equal(t, "@-webkit-keyframes spin{from{-webkit-transform:rotate(0deg);transform:rotate(0deg)}to{-webkit-transform:rotate(360deg);transform:rotate(360deg)}}@keyframes spin{from{-webkit-transform:rotate(0deg);-ms-transform:rotate(0deg);transform:rotate(0deg)}to{-webkit-transform:rotate(360deg);-ms-transform:rotate(360deg);transform:rotate(360deg)}}", Transform(t, addPrefixes(t, "safari >= 8, ie 9"), `
+@keyframes spin {
+	from { transform: rotate(0deg); }
+	to { transform: rotate(360deg); }
+}`))
+
+	assert.Equal(t, "@-webkit-keyframes spin{from{opacity:0}}@keyframes spin{from{opacity:1}}", Transform(t, addPrefixes(t, "safari >= 8"), `
+@-webkit-keyframes spin { from { opacity: 0; } }
+@keyframes spin { from { opacity: 1; } }`))
+}
+
+func TestPrefixes_Nested(t *testing.T) {
+	assert.Equal(t, "@media (min-width:100px){.a{-webkit-box-shadow:none;box-shadow:none}}", Transform(t, addPrefixes(t, "chrome >= 9"), `
+@media (min-width: 100px) {
+	.a { box-shadow: none; }
+}`))
+}
+
+func TestPrefixes_Passthrough(t *testing.T) {
+	assert.Equal(t, ".a{user-select:none}", Transform(t, func(o *transformer.Options) {
+		o.Targets = transforms.Targets{transforms.Safari: {Major: 15}}
+	}, `.a { user-select: none; }`))
+
+	assert.Equal(t, ".a{user-select:none}", Transform(t, func(o *transformer.Options) {
+		o.Prefixes = transforms.PrefixesAdd
+	}, `.a { user-select: none; }`))
+}
+
+func removePrefixes(t *testing.T, queries string) func(o *transformer.Options) {
+	targets, err := transforms.ParseTargets(queries)
+	require.NoError(t, err)
+
+	return func(o *transformer.Options) {
+		o.Prefixes = transforms.PrefixesRemove
+		o.Targets = targets
+	}
+}
+
+func TestPrefixes_Remove(t *testing.T) {
+	modern := removePrefixes(t, "chrome >= 120, firefox >= 120, safari >= 17")
+
+	assert.Equal(t, ".a{border-radius:2px;box-shadow:none}", Transform(t, modern, `.a { -webkit-border-radius: 2px; border-radius: 2px; -moz-box-shadow: none; box-shadow: none; }`))
+	assert.Equal(t, ".a{display:flex;position:sticky}", Transform(t, modern, `.a { display: -webkit-flex; display: flex; position: -webkit-sticky; position: sticky; }`))
+	assert.Equal(t, ".a{background:linear-gradient(red,blue)}", Transform(t, modern, `.a { background: -webkit-linear-gradient(red, blue); background: linear-gradient(red, blue); }`))
+	assert.Equal(t, "::selection{color:red}input::placeholder{color:gray}", Transform(t, modern, `::-moz-selection { color: red; } ::selection { color: red; } input::-moz-placeholder { color: gray; } input::placeholder { color: gray; }`))
+	assert.Equal(t, "@keyframes spin{to{transform:rotate(360deg)}}", Transform(t, modern, `@-webkit-keyframes spin { to { -webkit-transform: rotate(360deg); } } @keyframes spin { to { transform: rotate(360deg); } }`))
+
+	// Whitespace before the block doesn't change the selector.
+	assert.Equal(t, "::selection{color:red}.a>::placeholder{color:gray}", Transform(t, modern, `::-moz-selection{ color: red; } ::selection { color: red; } .a > ::-moz-placeholder{ color: gray; } .a>::placeholder { color: gray; }`))
+
+	// Prefixes without an unprefixed form next to them are kept.
+	assert.Equal(t, ".a{-webkit-border-radius:2px}.b{border-radius:2px}", Transform(t, modern, `.a { -webkit-border-radius: 2px; } .b { border-radius: 2px; }`))
+	assert.Equal(t, "::-moz-selection{color:red}.a::selection{color:red}", Transform(t, modern, `::-moz-selection { color: red; } .a::selection { color: red; }`))
+	assert.Equal(t, "@-webkit-keyframes a{}@keyframes b{}", Transform(t, modern, `@-webkit-keyframes a {} @keyframes b {}`))
+
+	// Prefixes that aren't in the dataset are kept.
+	assert.Equal(t, ".a{-webkit-font-smoothing:auto;font-smoothing:auto;display:-webkit-box;display:flex}", Transform(t, modern, `.a { -webkit-font-smoothing: auto; font-smoothing: auto; display: -webkit-box; display: flex; }`))
+
+	// Prefixes that the targets need are kept.
+	assert.Equal(t, ".a{-webkit-user-select:none;user-select:none}", Transform(t, modern, `.a { -webkit-user-select: none; -moz-user-select: none; user-select: none; }`))
+	assert.Equal(t, ".a{-webkit-transform:none;transform:none}", Transform(t, removePrefixes(t, "chrome >= 30"), `.a { -webkit-transform: none; transform: none; }`))
+
+	// Without targets, nothing is removed.
+	assert.Equal(t, ".a{-webkit-border-radius:2px;border-radius:2px}", Transform(t, func(o *transformer.Options) {
+		o.Prefixes = transforms.PrefixesRemove
+	}, `.a { -webkit-border-radius: 2px; border-radius: 2px; }`))
+}
+
+func TestPrefixes_AddAndRemove(t *testing.T) {
+	assert.Equal(t, ".a{-webkit-user-select:none;user-select:none;border-radius:2px}", Transform(t, func(o *transformer.Options) {
+		o.Prefixes = transforms.PrefixesAddAndRemove
+		o.Targets = transforms.Targets{transforms.Safari: {Major: 17}}
+	}, `.a { -moz-user-select: none; user-select: none; -webkit-border-radius: 2px; border-radius: 2px; }`))
+}
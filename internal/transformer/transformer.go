@@ -6,7 +6,7 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/ast"
 	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/internal/sources"
 	"github.com/stephen/cssc/transforms"
@@ -27,11 +27,16 @@ type Options struct {
 	// ImportReplacements is the set of import references to inline. ImportReplacements must be non-nil
 	// if ImportRules is set to ImportRulesInline.
 	ImportReplacements map[*ast.AtRule]*ast.Stylesheet
+
+	// Names, if set, is filled with the original name of each node that was renamed or
+	// substituted, e.g. the values of a var(), so that they can be added to the source map.
+	Names map[ast.Node]string
 }
 
 // Transform takes a pass over the input AST and runs various
 // transforms.
 func Transform(s *ast.Stylesheet, opts Options) *ast.Stylesheet {
+	opts.Options = opts.Options.WithTargets()
 	t := &transformer{
 		Options: opts,
 	}
@@ -40,7 +45,7 @@ func Transform(s *ast.Stylesheet, opts Options) *ast.Stylesheet {
 		t.Reporter = logging.DefaultReporter
 	}
 
-	if opts.CustomProperties != transforms.CustomPropertiesPassthrough {
+	if opts.CustomProperties == transforms.CustomPropertiesTransformRoot {
 		t.variables = make(map[string][]ast.Value)
 	}
 
@@ -52,7 +57,22 @@ func Transform(s *ast.Stylesheet, opts Options) *ast.Stylesheet {
 		t.Reporter.AddError(fmt.Errorf("ImportRules is set to ImportRulesInline, but ImportReplacements is not set"))
 	}
 
+	if len(opts.Plugins) > 0 || opts.Prefixes != transforms.PrefixesPassthrough {
+		t.inlined = make(map[ast.Node]struct{})
+	}
+
+	t.runPlugins(t.pluginsForStage(transforms.PluginStageBefore), s)
+	if opts.CustomProperties == transforms.CustomPropertiesTransformScoped || opts.CustomProperties == transforms.CustomPropertiesTransformRootPreserve {
+		t.collectCustomProperties(s)
+	}
 	s.Nodes = t.transformNodes(s.Nodes)
+	if (t.Prefixes == transforms.PrefixesRemove || t.Prefixes == transforms.PrefixesAddAndRemove) && len(t.Targets) > 0 {
+		s.Nodes = t.removePrefixes(s.Nodes)
+	}
+	if t.Prefixes == transforms.PrefixesAdd || t.Prefixes == transforms.PrefixesAddAndRemove {
+		s.Nodes = t.addPrefixes(s.Nodes)
+	}
+	t.runPlugins(t.pluginsForStage(transforms.PluginStageAfter), s)
 
 	return s
 }
@@ -64,6 +84,23 @@ type transformer struct {
 
 	variables   map[string][]ast.Value
 	customMedia map[string]*ast.MediaQuery
+
+	// customProperties is only set for CustomPropertiesTransformScoped and
+	// CustomPropertiesTransformRootPreserve.
+	customProperties *customProperties
+
+	// inlined is the set of nodes inlined from imports, which plugins and prefixing
+	// skip, since they were already run on the imported file. It is only set if there
+	// are plugins or prefixing is on.
+	inlined map[ast.Node]struct{}
+
+	// onlyPrefix, if set, limits the vendor prefixes that are added to one vendor,
+	// e.g. -webkit- inside of @-webkit-keyframes.
+	onlyPrefix string
+
+	// integer is set while transforming the values of a property that only accepts
+	// integers, e.g. z-index, so that math functions are rounded.
+	integer bool
 }
 
 func (t *transformer) addError(loc ast.Loc, fmt string, args ...interface{}) {
@@ -76,6 +113,15 @@ func (t *transformer) addWarn(loc ast.Loc, fmt string, args ...interface{}) {
 	t.Reporter.AddError(logging.LocationWarnf(t.OriginalSource, loc.Location().Position, loc.Location().Position+1, fmt, args...))
 }
 
+// setOriginalName records name as the original name of node, if Names is set.
+func (t *transformer) setOriginalName(node ast.Node, name string) {
+	if t.Names == nil {
+		return
+	}
+
+	t.Names[node] = name
+}
+
 func (t *transformer) transformSelectors(nodes []*ast.Selector) []*ast.Selector {
 	newNodes := make([]*ast.Selector, 0, len(nodes))
 	for _, n := range nodes {
@@ -91,12 +137,12 @@ func (t *transformer) transformSelectors(nodes []*ast.Selector) []*ast.Selector
 				// Replace one of them with :link.
 				newParts = append(
 					newParts,
-					&ast.PseudoClassSelector{Name: "link"},
+					&ast.PseudoClassSelector{Loc: part.Loc, Name: "link"},
 				)
 
 				// Make a duplicate with :visited.
 				duplicate := *n
-				duplicate.Parts[index] = &ast.PseudoClassSelector{Name: "visited"}
+				duplicate.Parts[index] = &ast.PseudoClassSelector{Loc: part.Loc, Name: "visited"}
 				newNodes = append(newNodes, &duplicate)
 
 			default:
@@ -115,6 +161,11 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 	for _, value := range nodes {
 		switch node := value.(type) {
 		case *ast.QualifiedRule:
+			if t.Nesting == transforms.NestingTransform && hasNestedRules(node) {
+				rv = append(rv, t.transformNodes(t.flattenNesting(node))...)
+				continue
+			}
+
 			func() {
 				selList, ok := node.Prelude.(*ast.SelectorList)
 				if !ok {
@@ -160,11 +211,15 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 				declBlock.Declarations = newDecls
 			}()
 
-			selList, ok := node.Prelude.(*ast.SelectorList)
-			if !ok {
+			switch prelude := node.Prelude.(type) {
+			case *ast.SelectorList:
+				prelude.Selectors = t.transformSelectors(prelude.Selectors)
+
+			case *ast.KeyframeSelectorList:
+
+			default:
 				t.addError(node.Prelude.Location(), "expected selector list for qualified rule")
 			}
-			selList.Selectors = t.transformSelectors(selList.Selectors)
 			node.Block = t.transformBlock(node.Block)
 
 			if node.Block == nil {
@@ -177,6 +232,7 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 			case "import":
 				if t.ImportReplacements == nil {
 					rv = append(rv, node)
+					break
 				}
 
 				imported, ok := t.ImportReplacements[node]
@@ -185,11 +241,19 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 					break
 				}
 
-				if len(node.Preludes) > 1 {
-					t.addWarn(node.Location(), "@import transform does not yet support @supports or media queries")
+				for _, prelude := range node.Preludes {
+					if mq, ok := prelude.(*ast.MediaQueryList); ok {
+						mq.Queries = t.transformMediaQueries(mq.Queries)
+					}
 				}
 
-				rv = append(rv, imported.Nodes...)
+				nodes := WrapImportConditions(node, imported.Nodes)
+				if t.inlined != nil {
+					for _, n := range nodes {
+						t.inlined[n] = struct{}{}
+					}
+				}
+				rv = append(rv, nodes...)
 
 			case "custom-media":
 				func() {
@@ -215,11 +279,16 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 				}()
 
 			case "media":
-				mq := node.Preludes[0].(*ast.MediaQueryList)
-				mq.Queries = t.transformMediaQueries(mq.Queries)
+				if len(node.Preludes) > 0 {
+					if mq, ok := node.Preludes[0].(*ast.MediaQueryList); ok && mq != nil {
+						mq.Queries = t.transformMediaQueries(mq.Queries)
+					}
+				}
+				t.transformAtRuleBlock(node)
 				rv = append(rv, node)
 
 			default:
+				t.transformAtRuleBlock(node)
 				rv = append(rv, node)
 			}
 
@@ -230,6 +299,20 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 	return rv
 }
 
+// transformAtRuleBlock transforms the contents of an at-rule's block, e.g. rules
+// inside of @media or declarations inside of @font-face. The block is kept even if
+// it ends up empty.
+func (t *transformer) transformAtRuleBlock(node *ast.AtRule) {
+	switch block := node.Block.(type) {
+	case *ast.QualifiedRuleBlock:
+		block.Rules = t.transformNodes(block.Rules)
+
+	case *ast.DeclarationBlock:
+		block.Declarations = t.transformDeclarations(block)
+		block.Rules = t.transformNodes(block.Rules)
+	}
+}
+
 func (t *transformer) transformMediaQueries(queries []*ast.MediaQuery) []*ast.MediaQuery {
 	newQueries := make([]*ast.MediaQuery, 0, len(queries))
 	for _, q := range queries {
@@ -253,7 +336,7 @@ func (t *transformer) addToValue(v ast.Value, diff float64) ast.Value {
 			t.addError(oldValue.Location(), "could not parse dimension value to lower media range: %s", oldValue.Value)
 			return oldValue
 		}
-		return &ast.Dimension{Value: strconv.FormatFloat(f+diff, 'f', -1, 64), Unit: oldValue.Unit}
+		return &ast.Dimension{Loc: oldValue.Loc, Value: strconv.FormatFloat(f+diff, 'f', -1, 64), Unit: oldValue.Unit}
 
 	default:
 		t.addError(oldValue.Location(), "tried to modify non-numeric value. expected dimension, percentage, or number, but got: %s", reflect.TypeOf(v).String())
@@ -357,16 +440,15 @@ func (t *transformer) transformMediaQueryParts(parts []ast.MediaQueryPart) []ast
 func (t *transformer) transformBlock(block ast.Block) ast.Block {
 	switch node := block.(type) {
 	case *ast.QualifiedRuleBlock:
-		// 	for _, d := range node.Rules {
-		// 		// t.transform(d)
-		// 	}
+		node.Rules = t.transformNodes(node.Rules)
 		if len(node.Rules) == 0 {
 			return nil
 		}
 
 	case *ast.DeclarationBlock:
-		node.Declarations = t.transformDeclarations(node.Declarations)
-		if len(node.Declarations) == 0 {
+		node.Declarations = t.transformDeclarations(node)
+		node.Rules = t.transformNodes(node.Rules)
+		if len(node.Declarations) == 0 && len(node.Rules) == 0 {
 			return nil
 		}
 
@@ -377,12 +459,19 @@ func (t *transformer) transformBlock(block ast.Block) ast.Block {
 	return block
 }
 
-func (t *transformer) transformDeclarations(decls []*ast.Declaration) []*ast.Declaration {
+func (t *transformer) transformDeclarations(block *ast.DeclarationBlock) []*ast.Declaration {
+	decls := block.Declarations
+	if t.customProperties != nil {
+		decls = t.addSubstitutedDeclarations(block)
+	}
+
 	newDecls := make([]*ast.Declaration, 0, len(decls))
 	for _, d := range decls {
+		_, t.integer = integerProperties[d.Property]
 		d.Values = t.transformValues(d.Values)
 		newDecls = append(newDecls, d)
 	}
+	t.integer = false
 
 	return newDecls
 }
@@ -426,50 +515,19 @@ func (t *transformer) transformValues(values []ast.Value) []ast.Value {
 				}
 
 				newValues = vals
-			}()
-
-			func() {
-				if v.Name != "calc" {
-					return
-				}
-
-				if t.CalcReduction == transforms.CalcReductionPassthrough {
-					return
-				}
-
-				if len(v.Arguments) != 1 {
-					t.addWarn(v.Location(), "expected single argument for calc()")
-					return
-				}
-
-				args := t.transformValues([]ast.Value{v.Arguments[0]})
-				if len(args) != 1 {
-					t.addWarn(v.Location(), "expected single argument for calc()")
-					return
-				}
-
-				arg, ok := args[0].(*ast.MathExpression)
-				if !ok {
-					return
-				}
-
-				l, r := t.transformValues([]ast.Value{arg.Left}), t.transformValues([]ast.Value{arg.Right})
-				if len(l) != 1 {
-					t.addWarn(arg.Left.Location(), "expected left-hand side of math expression to be a single value")
-					return
-				}
-				if len(r) != 1 {
-					t.addWarn(arg.Right.Location(), "expected right-hand side of math expression to be a single value")
-					return
+				for _, val := range vals {
+					t.setOriginalName(val, varName.Value)
 				}
+			}()
 
-				newValue := t.evaluateMathExpression(l[0], r[0], arg.Operator)
-				if newValue == nil {
-					return
+			if t.CalcReduction == transforms.CalcReductionReduce {
+				switch {
+				case v.IsMath():
+					newValues = []ast.Value{t.reduceMath(v)}
+				case v.Name != "var":
+					v.Arguments = t.reduceMathArguments(v.Arguments)
 				}
-
-				newValues = []ast.Value{newValue}
-			}()
+			}
 
 			rv = append(rv, newValues...)
 
@@ -480,158 +538,3 @@ func (t *transformer) transformValues(values []ast.Value) []ast.Value {
 
 	return rv
 }
-
-func (t *transformer) doMath(left, right, op string) (float64, error) {
-	leftValue, err := strconv.ParseFloat(left, 10)
-	if err != nil {
-		return 0, fmt.Errorf("could not parse dimension value: %s", left)
-	}
-
-	rightValue, err := strconv.ParseFloat(right, 10)
-	if err != nil {
-		return 0, fmt.Errorf("could not parse dimension value: %s", right)
-	}
-	switch op {
-	case "+":
-		return leftValue + rightValue, nil
-	case "-":
-		return leftValue - rightValue, nil
-	case "*":
-		return leftValue * rightValue, nil
-	case "/":
-		if rightValue == 0 {
-			return 0, fmt.Errorf("cannot divide by zero")
-		}
-		return leftValue / rightValue, nil
-	default:
-		return 0, fmt.Errorf("unknown op: %s", op)
-	}
-}
-
-// evaluateMathExpression attempts to add l + r. If sub is true, subtraction will be applied
-// instead. For sum to succeed, both l and r must be of the same type.
-// See notes from https://www.w3.org/TR/css-values-3/#calc-type-checking.
-func (t *transformer) evaluateMathExpression(l, r ast.Value, op string) ast.Value {
-	if expr, ok := l.(*ast.MathExpression); ok {
-		if evaluated := t.evaluateMathExpression(expr.Left, expr.Right, expr.Operator); evaluated != nil {
-			l = evaluated
-		}
-	}
-
-	if expr, ok := r.(*ast.MathExpression); ok {
-		if evaluated := t.evaluateMathExpression(expr.Left, expr.Right, expr.Operator); evaluated != nil {
-			r = evaluated
-		}
-	}
-
-	switch op {
-	case "+", "-":
-		switch left := l.(type) {
-		case *ast.Dimension:
-			right, ok := r.(*ast.Dimension)
-			if !ok {
-				return nil
-			}
-
-			if left.Unit != right.Unit {
-				if left.Unit == "" && right.Unit != "" {
-					// Invalid, because we cannot mix number types and lengths, e.g. (2 + 5rem).
-					t.addError(left.Location(), "cannot add number type and %s type together", right.Unit)
-				}
-
-				if left.Unit != "" && right.Unit == "" {
-					// Invalid, because we cannot mix number types and lengths, e.g. (5rem + 2).
-					t.addError(left.Location(), "cannot add number type and %s type together", left.Unit)
-				}
-
-				// Valid css, but we cannot reduce (e.g. 2px + 3rem).
-				return nil
-			}
-
-			newValue, err := t.doMath(left.Value, right.Value, op)
-			if err != nil {
-				t.addError(l.Location(), err.Error())
-				return nil
-			}
-
-			return &ast.Dimension{
-				Value: strconv.FormatFloat(newValue, 'f', -1, 64),
-				Unit:  left.Unit,
-			}
-
-		// case *ast.MathExpression:
-		// if left.op is not + or -, return nil
-
-		// We know left.Right is an ast.Dimension because of the structure of the parse tree
-		// We know Right is an ast.Dimension
-		// If left.Right.Unit != right.Unit and left.Left.Unit != right.Unit, return nil
-		// left.Right.Unit case: doMath on left.Right.Value and right.Value and return left with Right = [the result of doMath]
-		// left.Left.Unit case: doMath on left.Left.Value and right.Value and return left with Left = [the result of doMath]
-
-		default:
-			t.addError(l.Location(), "cannot perform %s on this type", op)
-			return nil
-		}
-
-	case "*":
-		leftAsDimension, leftIsDimension := l.(*ast.Dimension)
-		rightAsDimension, rightIsDimension := r.(*ast.Dimension)
-		if !leftIsDimension || !rightIsDimension {
-			return nil
-		}
-
-		if leftAsDimension.Unit != "" && rightAsDimension.Unit != "" {
-			t.addError(l.Location(), "one side of multiplication must be a number (non-percentage/dimension)")
-			return nil
-		}
-
-		maybeWithUnit, number := leftAsDimension, rightAsDimension
-		if leftAsDimension.Unit == "" {
-			maybeWithUnit, number = rightAsDimension, leftAsDimension
-		}
-
-		// XXX: handle cases like calc(var(--x) * 2 * 2)
-
-		newValue, err := t.doMath(maybeWithUnit.Value, number.Value, op)
-		if err != nil {
-			t.addError(l.Location(), err.Error())
-			return nil
-		}
-
-		return &ast.Dimension{
-			Value: strconv.FormatFloat(newValue, 'f', -1, 64),
-			Unit:  maybeWithUnit.Unit,
-		}
-
-	case "/":
-		rightAsDimension, rightIsDimension := r.(*ast.Dimension)
-		if !rightIsDimension || rightAsDimension.Unit != "" {
-			t.addError(l.Location(), "right side of division must be a number (non-percentage/dimension)")
-			return nil
-		}
-
-		switch left := l.(type) {
-		case *ast.Dimension:
-			newValue, err := t.doMath(left.Value, rightAsDimension.Value, op)
-			if err != nil {
-				t.addError(l.Location(), err.Error())
-				return nil
-			}
-
-			return &ast.Dimension{
-				Value: strconv.FormatFloat(newValue, 'f', -1, 64),
-				Unit:  left.Unit,
-			}
-
-			// case *ast.MathExpression
-
-		default:
-			t.addError(l.Location(), "cannot perform %s on this type", op)
-			return nil
-		}
-
-	default:
-		t.addError(l.Location(), "unknown op: %s", op)
-		return nil
-	}
-}
@@ -0,0 +1,38 @@
+package cssc
+
+import (
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/internal/sources"
+)
+
+// Parse parses a stylesheet without transforming it or loading its imports.
+// path is only used in diagnostics. Parsing recovers from errors, so the stylesheet
+// contains every rule that could be parsed, even if there are diagnostics.
+func Parse(source, path string) (*ast.Stylesheet, []Diagnostic) {
+	reporter := &diagnosticReporter{}
+	ss := parser.ParseWithReporter(&sources.Source{
+		Path:    path,
+		Content: source,
+	}, reporter)
+
+	return ss, reporter.diagnostics
+}
+
+// PrintOptions is the set of options to pass to Print.
+type PrintOptions struct {
+	// Minify, Pretty and Indent control printing. See Options.
+	Minify bool
+	Pretty bool
+	Indent int
+}
+
+// Print prints a node, usually an *ast.Stylesheet, as css.
+func Print(node ast.Node, opts PrintOptions) (string, error) {
+	return printer.Print(node, printer.Options{
+		Minify: opts.Minify,
+		Pretty: opts.Pretty,
+		Indent: opts.Indent,
+	})
+}
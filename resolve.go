@@ -0,0 +1,360 @@
+package cssc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NodeResolver resolves @import specifiers the way node and most css bundlers
+// do. Its Resolve method can be used as Options.Resolve:
+//
+//	resolver := &cssc.NodeResolver{IncludePaths: []string{"styles"}}
+//	cssc.Compile(cssc.Options{Entry: entries, Resolve: resolver.Resolve})
+//
+// A specifier is resolved by trying, in order:
+//  1. Alias, if the specifier starts with one of its keys.
+//  2. The path relative to the importing file, which is how browsers resolve
+//     @import. This is skipped for specifiers starting with ~, e.g. "~bootstrap".
+//  3. The package in each node_modules directory from the importing file's directory
+//     up to the root. Packages can point at their stylesheet with the "style" or
+//     "exports" fields of package.json.
+//  4. The path relative to each of IncludePaths.
+//
+// Each path is tried as-is, with a .css extension, and as a directory with a
+// package.json or an index.css.
+type NodeResolver struct {
+	// FS is the file system to resolve in. It must be the same as Options.FS.
+	FS fs.FS
+
+	// Alias maps specifier prefixes to paths, e.g. "@theme" to "src/theme" resolves
+	// "@theme/colors.css" to "src/theme/colors.css". Relative paths are relative to
+	// the working directory, or the root of FS.
+	Alias map[string]string
+
+	// IncludePaths is the list of directories to look for specifiers in if they
+	// can't be found elsewhere. Relative paths are relative to the working directory,
+	// or the root of FS.
+	IncludePaths []string
+}
+
+// Resolve returns the path of the file that specifier refers to. If it can't be found,
+// the error lists every location that was tried.
+func (r *NodeResolver) Resolve(specifier, importer string) (string, error) {
+	var tried []string
+
+	if target, rest, ok := r.alias(specifier); ok {
+		if p, ok := r.tryPath(r.join(target, rest), &tried); ok {
+			return p, nil
+		}
+		return "", notFoundError(specifier, tried)
+	}
+
+	module := strings.HasPrefix(specifier, "~")
+	specifier = strings.TrimPrefix(specifier, "~")
+
+	if !module {
+		if p, ok := r.tryPath(r.join(r.dir(importer), specifier), &tried); ok {
+			return p, nil
+		}
+	}
+
+	if !isRelative(specifier) {
+		name, subpath := splitPackageName(specifier)
+		for dir := r.dir(importer); ; dir = r.dir(dir) {
+			if p, ok := r.tryPackage(r.join(dir, "node_modules", name), subpath, &tried); ok {
+				return p, nil
+			}
+
+			if r.dir(dir) == dir {
+				break
+			}
+		}
+	}
+
+	for _, include := range r.IncludePaths {
+		if p, ok := r.tryPath(r.join(include, specifier), &tried); ok {
+			return p, nil
+		}
+	}
+
+	return "", notFoundError(specifier, tried)
+}
+
+// alias returns the target of the longest alias that matches specifier, along
+// with the rest of the specifier after the alias.
+func (r *NodeResolver) alias(specifier string) (target, rest string, ok bool) {
+	keys := make([]string, 0, len(r.Alias))
+	for key := range r.Alias {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+
+	for _, key := range keys {
+		if specifier == key {
+			return r.Alias[key], "", true
+		}
+
+		if strings.HasPrefix(specifier, key+"/") {
+			return r.Alias[key], strings.TrimPrefix(specifier, key+"/"), true
+		}
+	}
+
+	return "", "", false
+}
+
+// tryPath returns p, p with a .css extension, or the stylesheet of the package
+// in the directory p, whichever exists first.
+func (r *NodeResolver) tryPath(p string, tried *[]string) (string, bool) {
+	if r.isFile(p, tried) {
+		return p, true
+	}
+
+	if !strings.HasSuffix(p, ".css") && r.isFile(p+".css", tried) {
+		return p + ".css", true
+	}
+
+	if !r.isDir(p) {
+		return "", false
+	}
+	return r.tryPackage(p, "", tried)
+}
+
+// tryPackage returns the file that subpath refers to in the package in dir. If
+// subpath is empty, the package's main stylesheet is returned. dir is added to tried
+// if it doesn't exist.
+func (r *NodeResolver) tryPackage(dir, subpath string, tried *[]string) (string, bool) {
+	if !r.isDir(dir) {
+		*tried = append(*tried, dir)
+		return "", false
+	}
+
+	pkg := r.readPackageJSON(dir)
+	if target, ok := pkg.export(subpath); ok {
+		if p := r.join(dir, target); r.isFile(p, tried) {
+			return p, true
+		}
+	}
+
+	if subpath != "" {
+		return r.tryPath(r.join(dir, subpath), tried)
+	}
+
+	if pkg.Style != "" {
+		if p := r.join(dir, pkg.Style); r.isFile(p, tried) {
+			return p, true
+		}
+	}
+
+	if strings.HasSuffix(pkg.Main, ".css") {
+		if p := r.join(dir, pkg.Main); r.isFile(p, tried) {
+			return p, true
+		}
+	}
+
+	if p := r.join(dir, "index.css"); r.isFile(p, tried) {
+		return p, true
+	}
+
+	return "", false
+}
+
+// packageJSON is the part of a package.json that is used to find stylesheets.
+type packageJSON struct {
+	Style   string          `json:"style"`
+	Main    string          `json:"main"`
+	Exports json.RawMessage `json:"exports"`
+}
+
+// readPackageJSON reads the package.json in dir. If it can't be read, an empty
+// packageJSON is returned, so that the usual file names are tried instead.
+func (r *NodeResolver) readPackageJSON(dir string) packageJSON {
+	var pkg packageJSON
+
+	p := r.join(dir, "package.json")
+	var content []byte
+	var err error
+	if r.FS != nil {
+		content, err = fs.ReadFile(r.FS, p)
+	} else {
+		content, err = ioutil.ReadFile(p)
+	}
+	if err != nil {
+		return pkg
+	}
+
+	json.Unmarshal(content, &pkg)
+	return pkg
+}
+
+// exportConditions are the conditions that are matched in the "exports" field of
+// package.json, in order of preference.
+var exportConditions = []string{"style", "default"}
+
+// export returns the target of subpath in the package's "exports" field.
+// See: https://nodejs.org/api/packages.html#package-entry-points.
+func (p packageJSON) export(subpath string) (string, bool) {
+	if len(p.Exports) == 0 {
+		return "", false
+	}
+
+	var exports interface{}
+	if err := json.Unmarshal(p.Exports, &exports); err != nil {
+		return "", false
+	}
+
+	key := "."
+	if subpath != "" {
+		key = "./" + subpath
+	}
+
+	if m, ok := exports.(map[string]interface{}); ok && isSubpathMap(m) {
+		if target, ok := m[key]; ok {
+			return exportTarget(target, "")
+		}
+
+		// Patterns have one *, which is replaced with the rest of the subpath. Like
+		// Node, the pattern with the longest prefix wins, then the longest pattern.
+		var best, bestPrefix, bestSuffix string
+		for pattern := range m {
+			prefix, suffix, ok := cut(pattern, "*")
+			if !ok || !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, suffix) || len(key) < len(prefix)+len(suffix) {
+				continue
+			}
+
+			if best == "" || len(prefix) > len(bestPrefix) || (len(prefix) == len(bestPrefix) && len(pattern) > len(best)) {
+				best, bestPrefix, bestSuffix = pattern, prefix, suffix
+			}
+		}
+
+		if best == "" {
+			return "", false
+		}
+		return exportTarget(m[best], key[len(bestPrefix):len(key)-len(bestSuffix)])
+	}
+
+	// Exports without subpaths are the exports of the package's root.
+	if key != "." {
+		return "", false
+	}
+	return exportTarget(exports, "")
+}
+
+// exportTarget returns the path of an export target, which is either a path or
+// a map of conditions to targets. Any * in the path is replaced with match.
+func exportTarget(target interface{}, match string) (string, bool) {
+	switch t := target.(type) {
+	case string:
+		return strings.ReplaceAll(t, "*", match), true
+
+	case map[string]interface{}:
+		for _, condition := range exportConditions {
+			if inner, ok := t[condition]; ok {
+				if p, ok := exportTarget(inner, match); ok {
+					return p, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// isSubpathMap returns whether or not the keys of an "exports" map are subpaths,
+// rather than conditions.
+func isSubpathMap(m map[string]interface{}) bool {
+	for key := range m {
+		if strings.HasPrefix(key, ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cut slices s around the first instance of sep.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// splitPackageName splits a specifier into the package name and the path inside
+// of the package, e.g. "@scope/pkg/file.css" is split into "@scope/pkg" and "file.css".
+func splitPackageName(specifier string) (name, subpath string) {
+	parts := strings.SplitN(specifier, "/", 3)
+	if strings.HasPrefix(specifier, "@") && len(parts) > 1 {
+		name = parts[0] + "/" + parts[1]
+		if len(parts) > 2 {
+			subpath = parts[2]
+		}
+		return name, subpath
+	}
+
+	name, subpath, _ = cut(specifier, "/")
+	return name, subpath
+}
+
+// isRelative returns whether or not specifier is a relative or absolute path,
+// rather than a package name.
+func isRelative(specifier string) bool {
+	return specifier == "." || specifier == ".." || strings.HasPrefix(specifier, "./") ||
+		strings.HasPrefix(specifier, "../") || strings.HasPrefix(specifier, "/")
+}
+
+// isFile returns whether or not p is a file, adding it to tried if it isn't.
+func (r *NodeResolver) isFile(p string, tried *[]string) bool {
+	info, err := r.stat(p)
+	if err != nil || info.IsDir() {
+		*tried = append(*tried, p)
+		return false
+	}
+
+	return true
+}
+
+func (r *NodeResolver) isDir(p string) bool {
+	info, err := r.stat(p)
+	return err == nil && info.IsDir()
+}
+
+func (r *NodeResolver) stat(p string) (fs.FileInfo, error) {
+	if r.FS != nil {
+		return fs.Stat(r.FS, p)
+	}
+
+	return os.Stat(p)
+}
+
+// join joins path elements with the separator for FS, or for disk if FS is nil.
+func (r *NodeResolver) join(elem ...string) string {
+	if r.FS != nil {
+		return path.Join(elem...)
+	}
+
+	return filepath.Join(elem...)
+}
+
+func (r *NodeResolver) dir(p string) string {
+	if r.FS != nil {
+		return path.Dir(p)
+	}
+
+	return filepath.Dir(p)
+}
+
+// notFoundError returns an error for a specifier that couldn't be resolved.
+func notFoundError(specifier string, tried []string) error {
+	if len(tried) == 0 {
+		return fmt.Errorf("could not find %s", specifier)
+	}
+
+	return fmt.Errorf("could not find %s, tried:\n\t%s", specifier, strings.Join(tried, "\n\t"))
+}
@@ -0,0 +1,93 @@
+package cssc_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stephen/cssc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeResolver(t *testing.T) {
+	resolver := &cssc.NodeResolver{
+		FS: fstest.MapFS{
+			"src/index.css":                                 {},
+			"src/local.css":                                 {},
+			"src/theme/colors.css":                          {},
+			"src/theme/index.css":                           {},
+			"styles/include.css":                            {},
+			"node_modules/normalize.css/normalize.css":      {},
+			"node_modules/normalize.css/package.json":       {Data: []byte(`{"main": "normalize.css"}`)},
+			"node_modules/bootstrap/dist/css/bootstrap.css": {},
+			"node_modules/bootstrap/package.json":           {Data: []byte(`{"main": "dist/js/bootstrap.js", "style": "dist/css/bootstrap.css"}`)},
+			"node_modules/@scope/exports/package.json": {Data: []byte(`{
+				"exports": {
+					".": {"style": "./lib/main.css", "default": "./lib/main.js"},
+					"./*": "./lib/other/*.css",
+					"./themes/*": "./lib/themes/*.css",
+					"./themes/legacy/*": "./lib/legacy/*.css"
+				}
+			}`)},
+			"node_modules/@scope/exports/lib/main.css":        {},
+			"node_modules/@scope/exports/lib/themes/dark.css": {},
+			"node_modules/@scope/exports/lib/legacy/old.css":  {},
+			"node_modules/@scope/exports/lib/other/base.css":  {},
+			"src/node_modules/nested/index.css":               {},
+		},
+		Alias: map[string]string{
+			"@theme":        "src/theme",
+			"@theme/colors": "src/theme/colors.css",
+		},
+		IncludePaths: []string{"styles"},
+	}
+
+	for specifier, expected := range map[string]string{
+		"./local.css":                       "src/local.css",
+		"local":                             "src/local.css",
+		"normalize.css":                     "node_modules/normalize.css/normalize.css",
+		"~bootstrap":                        "node_modules/bootstrap/dist/css/bootstrap.css",
+		"~bootstrap/dist/css/bootstrap.css": "node_modules/bootstrap/dist/css/bootstrap.css",
+		"@scope/exports":                    "node_modules/@scope/exports/lib/main.css",
+		"@scope/exports/themes/dark":        "node_modules/@scope/exports/lib/themes/dark.css",
+		"@scope/exports/themes/legacy/old":  "node_modules/@scope/exports/lib/legacy/old.css",
+		"@scope/exports/base":               "node_modules/@scope/exports/lib/other/base.css",
+		"nested":                            "src/node_modules/nested/index.css",
+		"@theme":                            "src/theme/index.css",
+		"@theme/colors":                     "src/theme/colors.css",
+		"include.css":                       "styles/include.css",
+	} {
+		t.Run(specifier, func(t *testing.T) {
+			p, err := resolver.Resolve(specifier, "src/index.css")
+			require.NoError(t, err)
+			assert.Equal(t, expected, p)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := resolver.Resolve("~missing", "src/index.css")
+		require.Error(t, err)
+		assert.Equal(t, "could not find missing, tried:\n\tsrc/node_modules/missing\n\tnode_modules/missing\n\tstyles/missing\n\tstyles/missing.css", err.Error())
+	})
+}
+
+func TestNodeResolver_Compile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.css":                     {Data: []byte(`@import "~pkg"; @import "missing.css";`)},
+		"node_modules/pkg/package.json": {Data: []byte(`{"style": "pkg.css"}`)},
+		"node_modules/pkg/pkg.css":      {Data: []byte(`.pkg { color: red; }`)},
+	}
+
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"index.css"},
+		FS:       fsys,
+		Resolve:  (&cssc.NodeResolver{FS: fsys}).Resolve,
+		Reporter: &errors,
+		Bundle:   true,
+	})
+
+	require.Len(t, errors, 1)
+	assert.Contains(t, errors[0].Error(), "failed to resolve import \"missing.css\": could not find missing.css, tried:\n\tmissing.css\n\tnode_modules/missing.css")
+	assert.Contains(t, result.Files["index.css"], ".pkg{color:red}")
+}
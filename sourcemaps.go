@@ -0,0 +1,155 @@
+package cssc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/logging"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/internal/sources"
+)
+
+// loadInputSourceMap looks for a sourceMappingURL comment left in a source by the tool
+// that generated it, e.g. Sass or PostCSS, and loads the source map that it points to so
+// that our own source map can point to the original files. The comment is removed from
+// the stylesheet, since it does not apply to the output. The source map is not loaded if
+// the output has no source map.
+func (c *compilation) loadInputSourceMap(source *sources.Source, ss *ast.Stylesheet) {
+	for i := len(ss.Nodes) - 1; i >= 0; i-- {
+		comment, ok := ss.Nodes[i].(*ast.Comment)
+		if !ok {
+			continue
+		}
+
+		u, ok := sourceMappingURL(comment.Text)
+		if !ok {
+			continue
+		}
+
+		ss.Nodes = append(ss.Nodes[:i:i], ss.Nodes[i+1:]...)
+		if c.sourceMap == SourceMapNone {
+			return
+		}
+
+		m, err := c.readInputSourceMap(source, u)
+		if err != nil {
+			start := comment.Location().Position
+			c.reporter.AddError(logging.LocationWarnf(source, start, start+len(comment.Text)+4, "failed to load source map: %v", err))
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for j, s := range m.Sources {
+			// Share sources between input source maps so that they are only listed once.
+			if existing, ok := c.inputSources[s.Path]; ok {
+				m.Sources[j] = existing
+				continue
+			}
+			c.inputSources[s.Path] = s
+		}
+		c.inputSourceMaps[source.Index] = m
+		return
+	}
+}
+
+// sourceMappingURL returns the url in the text of a sourceMappingURL comment.
+func sourceMappingURL(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	for _, prefix := range []string{"# sourceMappingURL=", "@ sourceMappingURL="} {
+		if strings.HasPrefix(text, prefix) {
+			u := strings.TrimSpace(strings.TrimPrefix(text, prefix))
+			return u, u != ""
+		}
+	}
+
+	return "", false
+}
+
+// readInputSourceMap reads and parses the source map at u, which is either a data url
+// or a path relative to the source. The paths of the original sources are resolved
+// relative to the source map.
+func (c *compilation) readInputSourceMap(source *sources.Source, u string) (*printer.InputSourceMap, error) {
+	var data []byte
+	var dir string
+
+	switch {
+	case strings.HasPrefix(u, "data:"):
+		var err error
+		if data, err = decodeDataURL(u); err != nil {
+			return nil, err
+		}
+		dir = c.dir(source.Path)
+
+	case strings.Contains(u, "://"):
+		return nil, fmt.Errorf("unsupported source map url: %s", u)
+
+	default:
+		p, err := url.PathUnescape(u)
+		if err != nil {
+			return nil, err
+		}
+
+		p = c.join(c.dir(source.Path), p)
+		if data, err = c.readFile(p); err != nil {
+			return nil, err
+		}
+		dir = c.dir(p)
+	}
+
+	m, err := printer.ParseSourceMap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range m.Sources {
+		if strings.Contains(s.Path, "://") || (c.fs == nil && filepath.IsAbs(s.Path)) {
+			continue
+		}
+		s.Path = c.join(dir, s.Path)
+	}
+
+	return m, nil
+}
+
+// decodeDataURL returns the content of a data url, e.g. data:application/json;base64,e30=.
+func decodeDataURL(u string) ([]byte, error) {
+	comma := strings.IndexByte(u, ',')
+	if comma < 0 {
+		return nil, fmt.Errorf("invalid data url")
+	}
+
+	meta, content := u[len("data:"):comma], u[comma+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(content)
+	}
+
+	decoded, err := url.PathUnescape(content)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(decoded), nil
+}
+
+// dir returns the directory of a path returned by sourcePath.
+func (c *compilation) dir(p string) string {
+	if c.fs != nil {
+		return path.Dir(p)
+	}
+
+	return filepath.Dir(p)
+}
+
+// join joins a path relative to dir, which is a path returned by sourcePath.
+func (c *compilation) join(dir, p string) string {
+	if c.fs != nil {
+		return path.Join(dir, p)
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(p))
+}
@@ -0,0 +1,301 @@
+package cssc_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/ast"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sourceMap struct {
+	Version        int       `json:"version"`
+	File           string    `json:"file"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+	Names          []string  `json:"names"`
+	Mappings       string    `json:"mappings"`
+}
+
+// segment is a decoded source map segment. Name is -1 if the segment has no name.
+type segment struct {
+	GeneratedLine, GeneratedColumn int32
+	Source                         int32
+	OriginalLine, OriginalColumn   int32
+	Name                           int32
+}
+
+// decodeMappings decodes the segments in the mappings of a source map.
+func decodeMappings(t testing.TB, mappings string) []segment {
+	var rv []segment
+	var last segment
+	var name int32
+	for line, l := range strings.Split(mappings, ";") {
+		last.GeneratedColumn = 0
+		for _, s := range strings.Split(l, ",") {
+			if s == "" {
+				continue
+			}
+
+			in := []byte(s)
+			var fields []int32
+			for len(in) > 0 {
+				val, n := printer.VLQDecode(in)
+				require.NotZero(t, n)
+				fields = append(fields, val)
+				in = in[n:]
+			}
+			require.Contains(t, []int{4, 5}, len(fields))
+
+			last.GeneratedLine = int32(line)
+			last.GeneratedColumn += fields[0]
+			last.Source += fields[1]
+			last.OriginalLine += fields[2]
+			last.OriginalColumn += fields[3]
+			last.Name = -1
+			if len(fields) == 5 {
+				name += fields[4]
+				last.Name = name
+			}
+			rv = append(rv, last)
+		}
+	}
+
+	return rv
+}
+
+// mappedSources returns the index of the source of each segment in the mappings.
+func mappedSources(t testing.TB, mappings string) []int32 {
+	var rv []int32
+	for _, s := range decodeMappings(t, mappings) {
+		rv = append(rv, s.Source)
+	}
+
+	return rv
+}
+
+// mappedNames returns the name of each segment in the mappings that has one.
+func mappedNames(t testing.TB, m sourceMap) []string {
+	var rv []string
+	for _, s := range decodeMappings(t, m.Mappings) {
+		if s.Name >= 0 {
+			require.Less(t, int(s.Name), len(m.Names))
+			rv = append(rv, m.Names[s.Name])
+		}
+	}
+
+	return rv
+}
+
+func TestSourceMaps_External(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:      []string{"testdata/bundle/index.css"},
+		Reporter:   &errors,
+		Outdir:     "dist",
+		SourceMap:  cssc.SourceMapExternal,
+		SourceRoot: "/src/",
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	assert.Len(t, errors, 0)
+	require.Len(t, result.Files, 2)
+
+	out := result.Files[filepath.Join("dist", "index.css")]
+	assert.True(t, strings.HasSuffix(out, "\n/*# sourceMappingURL=index.css.map */\n"), out)
+	assert.NotContains(t, out, "data:application/json")
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(result.Files[filepath.Join("dist", "index.css.map")]), &m))
+	assert.Equal(t, 3, m.Version)
+	assert.Equal(t, "index.css", m.File)
+	assert.Equal(t, "/src/", m.SourceRoot)
+
+	// Sources are relative to the source map, and listed in the order they are first mapped.
+	dir := filepath.Join("..", "testdata", "bundle")
+	assert.Equal(t, []string{
+		filepath.ToSlash(filepath.Join(dir, "index.css")),
+		filepath.ToSlash(filepath.Join(dir, "c.css")),
+		filepath.ToSlash(filepath.Join(dir, "a.css")),
+		filepath.ToSlash(filepath.Join(dir, "b.css")),
+	}, m.Sources)
+	require.Len(t, m.SourcesContent, 4)
+	assert.Contains(t, *m.SourcesContent[1], ".c {")
+
+	// .c, .a, .c, .b, then .index, each with a selector, declaration and value.
+	assert.Equal(t, []int32{1, 1, 1, 2, 2, 2, 1, 1, 1, 3, 3, 3, 0, 0, 0}, mappedSources(t, m.Mappings))
+}
+
+func TestSourceMaps_Names(t *testing.T) {
+	// hashClasses renames classes like a css modules implementation would.
+	hashClasses := transforms.Plugin{
+		Name: "hash-classes",
+		Selector: func(sel *ast.Selector, ctx transforms.PluginContext) []*ast.Selector {
+			for _, p := range sel.Parts {
+				if c, ok := p.(*ast.ClassSelector); ok {
+					ctx.SetOriginalName(c, c.Name)
+					c.Name = "_" + c.Name + "_1x2y"
+				}
+			}
+			return []*ast.Selector{sel}
+		},
+	}
+
+	result := cssc.Transform(`:root { --primary: red; }
+.button { color: var(--primary); border: 1px solid var(--primary) }`, cssc.TransformOptions{
+		Path: "button.css",
+		Transforms: transforms.Options{
+			CustomProperties: transforms.CustomPropertiesTransformRoot,
+			Plugins:          []transforms.Plugin{hashClasses},
+		},
+	})
+
+	require.False(t, result.HasErrors(), result.Diagnostics)
+	assert.Equal(t, "._button_1x2y{color:red;border:1px solid red}", result.Output)
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(result.SourceMap), &m))
+	assert.Equal(t, []string{"button", "--primary"}, m.Names)
+	assert.Equal(t, []string{"button", "--primary", "--primary"}, mappedNames(t, m))
+}
+
+func TestSourceMaps_NamesPreserve(t *testing.T) {
+	result := cssc.Transform(`:root { --a: red; --b: var(--a); }
+.x { color: var(--b); }`, cssc.TransformOptions{
+		Path: "x.css",
+		Transforms: transforms.Options{
+			CustomProperties: transforms.CustomPropertiesTransformRootPreserve,
+		},
+	})
+
+	require.False(t, result.HasErrors(), result.Diagnostics)
+	assert.Equal(t, ":root{--a:red;--b:var(--a)}.x{color:red;color:var(--b)}", result.Output)
+
+	// Only the substituted value is named, after the property that defined it.
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(result.SourceMap), &m))
+	assert.Equal(t, []string{"--a"}, m.Names)
+	assert.Equal(t, []string{"--a"}, mappedNames(t, m))
+}
+
+func TestSourceMaps_Options(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:                 []string{"testdata/simple/index.css"},
+		Reporter:              &errors,
+		Outdir:                "dist",
+		SourceMap:             cssc.SourceMapExternal,
+		ExcludeSourcesContent: true,
+	})
+
+	assert.Len(t, errors, 0)
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(result.Files[filepath.Join("dist", "index.css.map")]), &m))
+	assert.Nil(t, m.SourcesContent)
+	assert.Len(t, m.Sources, 1)
+
+	result = cssc.Compile(cssc.Options{
+		Entry:     []string{"testdata/simple/index.css"},
+		Reporter:  &errors,
+		Outdir:    "dist",
+		SourceMap: cssc.SourceMapNone,
+	})
+
+	assert.Len(t, errors, 0)
+	require.Len(t, result.Files, 1)
+	assert.NotContains(t, result.Files[filepath.Join("dist", "index.css")], "sourceMappingURL")
+}
+
+func TestSourceMaps_Input(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:     []string{"testdata/inputsourcemap/compiled.css"},
+		Reporter:  &errors,
+		Outdir:    "dist",
+		SourceMap: cssc.SourceMapExternal,
+	})
+
+	assert.Len(t, errors, 0)
+
+	// The sourceMappingURL of the input is replaced with our own.
+	out := result.Files[filepath.Join("dist", "compiled.css")]
+	assert.Equal(t, ".a .b{color:red}\n/*# sourceMappingURL=compiled.css.map */\n", out)
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(result.Files[filepath.Join("dist", "compiled.css.map")]), &m))
+	assert.Equal(t, []string{filepath.ToSlash(filepath.Join("..", "testdata", "inputsourcemap", "styles.scss"))}, m.Sources)
+	require.Len(t, m.SourcesContent, 1)
+	assert.Contains(t, *m.SourcesContent[0], "$primary: red;")
+	assert.Equal(t, []string{"$primary"}, m.Names)
+
+	assert.Equal(t, []segment{
+		{0, 0, 0, 2, 2, -1},  // .a
+		{0, 3, 0, 2, 2, -1},  // .b
+		{0, 6, 0, 2, 7, -1},  // color
+		{0, 12, 0, 2, 14, 0}, // red
+	}, decodeMappings(t, m.Mappings))
+}
+
+func TestSourceMaps_InputNone(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{"out.css"},
+		FS: fstest.MapFS{
+			"out.css": {Data: []byte(".a{color:red}\n/*# sourceMappingURL=missing.css.map */")},
+		},
+		Reporter:  &errors,
+		SourceMap: cssc.SourceMapNone,
+	})
+
+	// The input source map isn't needed, so it isn't loaded.
+	assert.Len(t, errors, 0)
+	assert.Equal(t, ".a{color:red}", result.Files["out.css"])
+}
+
+func TestSourceMaps_InputInline(t *testing.T) {
+	input := `{"version":3,"sources":["a.less","b.less"],"sourcesContent":["a"],"names":[],"mappings":"AAAA,G,GCCE"}`
+	result := cssc.Transform(".a{color:red}\n/*# sourceMappingURL=data:application/json;base64,"+
+		base64.StdEncoding.EncodeToString([]byte(input))+" */", cssc.TransformOptions{
+		Path: "src/out.css",
+	})
+
+	require.False(t, result.HasErrors(), result.Diagnostics)
+	assert.Equal(t, ".a{color:red}", result.Output)
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(result.SourceMap), &m))
+	assert.Equal(t, []string{"a.less", "b.less"}, m.Sources)
+	require.Len(t, m.SourcesContent, 2)
+	assert.Equal(t, "a", *m.SourcesContent[0])
+	assert.Nil(t, m.SourcesContent[1], "missing content is null")
+
+	// The declaration is unmapped in the input, so it is left out.
+	assert.Equal(t, []segment{
+		{0, 0, 0, 0, 0, -1},
+		{0, 9, 1, 1, 2, -1},
+	}, decodeMappings(t, m.Mappings))
+}
+
+func TestSourceMaps_InputErrors(t *testing.T) {
+	result := cssc.Transform(".a{color:red}\n/*# sourceMappingURL=missing.css.map */", cssc.TransformOptions{
+		FS: fstest.MapFS{},
+	})
+
+	assert.False(t, result.HasErrors())
+	require.Len(t, result.Diagnostics, 1)
+	assert.True(t, result.Diagnostics[0].Warning)
+	assert.Contains(t, result.Diagnostics[0].Message, "failed to load source map")
+	assert.Equal(t, ".a{color:red}", result.Output)
+}
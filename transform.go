@@ -0,0 +1,153 @@
+package cssc
+
+import (
+	"io/fs"
+	"sync"
+
+	"github.com/stephen/cssc/internal/logging"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/transforms"
+)
+
+// TransformOptions is the set of options to pass to Transform.
+type TransformOptions struct {
+	// Path is the path of the source. It is used in diagnostics and the source map,
+	// and imports are resolved relative to it. If it is empty, the source is treated
+	// as a file named <stdin> in the working directory, or the root of FS.
+	Path string
+
+	Transforms transforms.Options
+
+	// Minify, Pretty and Indent control printing. See Options.
+	Minify bool
+	Pretty bool
+	Indent int
+
+	// FS and Resolve control how imported files are loaded. See Options.
+	FS      fs.FS
+	Resolve func(specifier, importer string) (string, error)
+
+	// SourceRoot and ExcludeSourcesContent control the source map. See Options.
+	SourceRoot            string
+	ExcludeSourcesContent bool
+}
+
+// TransformResult is the result of Transform.
+type TransformResult struct {
+	// Output is the compiled css.
+	Output string
+
+	// SourceMap is the source map for Output, as json.
+	SourceMap string
+
+	// Diagnostics is the list of errors and warnings reported while compiling.
+	Diagnostics []Diagnostic
+}
+
+// Diagnostic is an error or warning reported during compilation.
+type Diagnostic struct {
+	// Path is the path of the source that the diagnostic is about, if any.
+	Path string
+
+	// Line is the 1-indexed line of the diagnostic, and Column is its 0-indexed
+	// byte offset in the line. Both are zero if the diagnostic has no location.
+	Line, Column int
+
+	// Length is the length of the diagnostic's location in bytes.
+	Length int
+
+	// Message is the error message, without the location.
+	Message string
+
+	// Warning is set if the diagnostic is a warning rather than an error.
+	Warning bool
+
+	// Err is the reported error. Its Error method includes the location and an
+	// excerpt of the source.
+	Err error
+}
+
+// HasErrors returns whether or not any of the diagnostics are errors.
+func (r *TransformResult) HasErrors() bool {
+	for _, d := range r.Diagnostics {
+		if !d.Warning {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Transform compiles a stylesheet from a string. It runs the same parse, transform,
+// and print steps as Compile. If imports are inlined, they are loaded with opts.FS
+// and opts.Resolve. Otherwise, they are left in the output as they are.
+func Transform(source string, opts TransformOptions) *TransformResult {
+	reporter := &diagnosticReporter{}
+	c := newCompilation(Options{
+		Reporter:   reporter,
+		Transforms: opts.Transforms,
+		Minify:     opts.Minify,
+		Pretty:     opts.Pretty,
+		Indent:     opts.Indent,
+		FS:         opts.FS,
+		Resolve:    opts.Resolve,
+
+		SourceRoot:            opts.SourceRoot,
+		ExcludeSourcesContent: opts.ExcludeSourcesContent,
+	})
+	c.skipImports = opts.Transforms.ImportRules != transforms.ImportRulesInline
+
+	result := &TransformResult{}
+
+	path := opts.Path
+	if path == "" {
+		path = "<stdin>"
+	}
+
+	p, err := c.sourcePath(path)
+	if err != nil {
+		reporter.AddError(err)
+		result.Diagnostics = reporter.diagnostics
+		return result
+	}
+
+	idx := c.addSourceContent(p, source)
+	ss := c.parseSource(idx, true)
+
+	printOpts := c.printOptions
+	printOpts.OriginalSource = c.sourcesByIndex[idx]
+	printOpts.Sources = c.sourcesByIndex
+	printOpts.SourceMapFile = p
+	printOpts.Names = c.names
+	printOpts.InputSourceMaps = c.inputSourceMaps
+	result.Output, result.SourceMap, err = printer.PrintWithSourceMap(ss, printOpts)
+	if err != nil {
+		reporter.AddError(err)
+	}
+
+	result.Diagnostics = reporter.diagnostics
+	return result
+}
+
+// diagnosticReporter is a Reporter that collects diagnostics.
+type diagnosticReporter struct {
+	mu          sync.Mutex
+	diagnostics []Diagnostic
+}
+
+// AddError implements Reporter.
+func (r *diagnosticReporter) AddError(err error) {
+	d := Diagnostic{
+		Message: err.Error(),
+		Err:     err,
+	}
+
+	if l, ok := logging.Locate(err); ok {
+		d.Path, d.Line, d.Column, d.Length = l.Path, l.Line, l.Column, l.Length
+		d.Message, d.Warning = l.Message, l.Warning
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.diagnostics = append(r.diagnostics, d)
+}
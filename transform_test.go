@@ -0,0 +1,129 @@
+package cssc_test
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransform(t *testing.T) {
+	result := cssc.Transform(".a { color: red; }\n.b:any-link { color: blue; }", cssc.TransformOptions{
+		Path: "index.css",
+		Transforms: transforms.Options{
+			AnyLink: transforms.AnyLinkTransform,
+		},
+	})
+
+	assert.Empty(t, result.Diagnostics)
+	assert.False(t, result.HasErrors())
+	assert.Equal(t, ".a{color:red}.b:visited,.b:link{color:blue}", result.Output)
+
+	var sourceMap struct {
+		Version  int    `json:"version"`
+		Mappings string `json:"mappings"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(result.SourceMap), &sourceMap))
+	assert.Equal(t, 3, sourceMap.Version)
+	assert.NotEmpty(t, sourceMap.Mappings)
+}
+
+func TestTransform_Diagnostics(t *testing.T) {
+	result := cssc.Transform(".a { color: red; }\n.b { color: ; }\n.c { color: blue; }", cssc.TransformOptions{
+		Path: "index.css",
+	})
+
+	assert.True(t, result.HasErrors())
+	require.Len(t, result.Diagnostics, 1)
+
+	d := result.Diagnostics[0]
+	assert.Equal(t, "index.css", d.Path[len(d.Path)-len("index.css"):])
+	assert.Equal(t, 2, d.Line)
+	assert.False(t, d.Warning)
+	assert.NotEmpty(t, d.Message)
+	assert.Contains(t, result.Output, ".c{color:blue}")
+}
+
+func TestTransform_EmptyMediaQuery(t *testing.T) {
+	result := cssc.Transform("@media { a { color: red } }", cssc.TransformOptions{
+		Path: "index.css",
+		Transforms: transforms.Options{
+			MediaFeatureRanges: transforms.MediaFeatureRangesTransform,
+		},
+	})
+
+	assert.Empty(t, result.Diagnostics)
+	assert.Equal(t, "@media {a{color:red}}", result.Output)
+
+	result = cssc.Transform(`@import "./other.css" screen;`, cssc.TransformOptions{
+		Path: "index.css",
+		FS: fstest.MapFS{
+			"other.css": {Data: []byte(`@media { a { color: red } }`)},
+		},
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	assert.Empty(t, result.Diagnostics)
+	assert.Equal(t, "@media screen{a{color:red}}", result.Output)
+}
+
+func TestTransform_Imports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"css/other.css": {Data: []byte(`.other { color: red; }`)},
+	}
+
+	result := cssc.Transform(`@import "./other.css"; .a { color: blue; }`, cssc.TransformOptions{
+		Path: "css/index.css",
+		FS:   fsys,
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	assert.Empty(t, result.Diagnostics)
+	assert.Equal(t, ".other{color:red}.a{color:blue}", result.Output)
+
+	result = cssc.Transform(`@import "./missing.css";`, cssc.TransformOptions{
+		FS: fsys,
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	require.Len(t, result.Diagnostics, 1)
+	assert.Equal(t, "<stdin>", result.Diagnostics[0].Path)
+	assert.Equal(t, 1, result.Diagnostics[0].Line)
+	assert.Equal(t, `failed to read import "./missing.css": open missing.css: file does not exist`, result.Diagnostics[0].Message)
+
+	// Imports that are passed through aren't loaded.
+	result = cssc.Transform(`@import "./missing.css"; .a { color: blue; }`, cssc.TransformOptions{FS: fsys})
+	assert.Empty(t, result.Diagnostics)
+	assert.Equal(t, `@import "./missing.css";.a{color:blue}`, result.Output)
+}
+
+func TestTransform_RemovedPrefixWarnings(t *testing.T) {
+	source := ".a {\n  -webkit-border-radius: 2px;\n  border-radius: 2px;\n}\n"
+	opts := transforms.Options{
+		Prefixes: transforms.PrefixesRemove,
+		Targets:  transforms.Targets{transforms.Chrome: {Major: 120}},
+	}
+
+	result := cssc.Transform(source, cssc.TransformOptions{Path: "index.css", Transforms: opts})
+	assert.Empty(t, result.Diagnostics)
+	assert.Equal(t, ".a{border-radius:2px}", result.Output)
+
+	opts.WarnRemovedPrefixes = true
+	result = cssc.Transform(source, cssc.TransformOptions{Path: "index.css", Transforms: opts})
+	assert.False(t, result.HasErrors())
+	require.Len(t, result.Diagnostics, 1)
+	assert.True(t, result.Diagnostics[0].Warning)
+	assert.Equal(t, 2, result.Diagnostics[0].Line)
+	assert.Equal(t, "removed outdated vendor prefix: -webkit-border-radius", result.Diagnostics[0].Message)
+	assert.Equal(t, ".a{border-radius:2px}", result.Output)
+}
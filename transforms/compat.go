@@ -0,0 +1,136 @@
+package transforms
+
+// Feature is a CSS feature that some browsers don't support, and that a transform
+// can compile away.
+type Feature int
+
+const (
+	// FeatureMediaFeatureRanges is range syntax in media queries, e.g. (width >= 600px).
+	FeatureMediaFeatureRanges Feature = iota
+	// FeatureAnyLink is the :any-link pseudo-class.
+	FeatureAnyLink
+	// FeatureCustomProperties is custom properties and var().
+	FeatureCustomProperties
+	// FeatureCustomMediaQueries is @custom-media, which no browser supports yet.
+	FeatureCustomMediaQueries
+	// FeatureNesting is nested style rules.
+	FeatureNesting
+)
+
+// support is the first version of each browser that supports each feature. Browsers
+// that don't support a feature at all are left out. The data is from caniuse.com and
+// MDN.
+var support = map[Feature]map[Browser]Version{
+	FeatureMediaFeatureRanges: {
+		Chrome:    {104, 0},
+		Edge:      {104, 0},
+		Firefox:   {63, 0},
+		Safari:    {16, 4},
+		IOSSafari: {16, 4},
+		Opera:     {91, 0},
+		Samsung:   {20, 0},
+		Android:   {104, 0},
+	},
+	FeatureAnyLink: {
+		Chrome:    {65, 0},
+		Edge:      {79, 0},
+		Firefox:   {50, 0},
+		Safari:    {9, 0},
+		IOSSafari: {9, 0},
+		Opera:     {52, 0},
+		Samsung:   {9, 2},
+		Android:   {65, 0},
+	},
+	FeatureCustomProperties: {
+		Chrome:    {49, 0},
+		Edge:      {16, 0},
+		Firefox:   {31, 0},
+		Safari:    {9, 1},
+		IOSSafari: {9, 3},
+		Opera:     {36, 0},
+		Samsung:   {5, 0},
+		Android:   {49, 0},
+	},
+	FeatureCustomMediaQueries: {},
+	FeatureNesting: {
+		Chrome:    {120, 0},
+		Edge:      {120, 0},
+		Firefox:   {117, 0},
+		Safari:    {17, 2},
+		IOSSafari: {17, 2},
+		Opera:     {106, 0},
+		Samsung:   {25, 0},
+		Android:   {120, 0},
+	},
+}
+
+// releases is the list of recent versions of each browser, oldest first, for
+// last N versions queries. It is current as of October 2025.
+var releases = map[Browser][]Version{
+	Chrome:    majors(130, 141),
+	Edge:      majors(130, 141),
+	Firefox:   majors(130, 144),
+	Safari:    {{17, 0}, {17, 1}, {17, 2}, {17, 3}, {17, 4}, {17, 5}, {17, 6}, {18, 0}, {18, 1}, {18, 2}, {18, 3}, {18, 4}, {18, 5}, {18, 6}, {26, 0}},
+	IOSSafari: {{17, 0}, {17, 1}, {17, 2}, {17, 3}, {17, 4}, {17, 5}, {17, 6}, {18, 0}, {18, 1}, {18, 2}, {18, 3}, {18, 4}, {18, 5}, {18, 6}, {26, 0}},
+	Opera:     majors(115, 122),
+	Samsung:   majors(25, 28),
+	Android:   majors(130, 141),
+	IE:        {{10, 0}, {11, 0}},
+}
+
+// dead is the set of browsers that no longer get updates.
+var dead = map[Browser]bool{
+	IE: true,
+}
+
+// majors returns the major versions from first to last.
+func majors(first, last int) []Version {
+	rv := make([]Version, 0, last-first+1)
+	for v := first; v <= last; v++ {
+		rv = append(rv, Version{Major: v})
+	}
+
+	return rv
+}
+
+// Supports returns whether or not every browser in t supports f.
+func (t Targets) Supports(f Feature) bool {
+	for b, v := range t {
+		first, ok := support[f][b]
+		if !ok || v.Less(first) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithTargets returns a copy of o with the transforms that o.Targets need turned
+// on, in addition to the ones that are already set.
+func (o Options) WithTargets() Options {
+	if len(o.Targets) == 0 {
+		return o
+	}
+
+	if !o.Targets.Supports(FeatureMediaFeatureRanges) {
+		o.MediaFeatureRanges = MediaFeatureRangesTransform
+	}
+
+	if !o.Targets.Supports(FeatureAnyLink) {
+		o.AnyLink = AnyLinkTransform
+	}
+
+	if !o.Targets.Supports(FeatureCustomProperties) && o.CustomProperties == CustomPropertiesPassthrough {
+		o.CustomProperties = CustomPropertiesTransformRoot
+	}
+
+	if !o.Targets.Supports(FeatureCustomMediaQueries) {
+		o.CustomMediaQueries = CustomMediaQueriesTransform
+	}
+
+	if !o.Targets.Supports(FeatureNesting) {
+		o.Nesting = NestingTransform
+	}
+
+	return o
+}
@@ -0,0 +1,74 @@
+package transforms
+
+import "github.com/stephen/cssc/ast"
+
+// PluginStage controls when a plugin runs relative to the built-in transforms.
+type PluginStage int
+
+const (
+	// PluginStageAfter runs the plugin after the built-in transforms. It is the default.
+	PluginStageAfter PluginStage = iota
+	// PluginStageBefore runs the plugin before the built-in transforms, e.g. so that its
+	// output is transformed as well.
+	PluginStageBefore
+)
+
+// Plugin is a third-party transform. Every hook is optional.
+//
+// Plugins in the same stage run in the order they are listed in Options.Plugins.
+// For each node, the hooks of every plugin are called in that order, with each
+// plugin receiving the nodes returned by the previous one. Then, the children of
+// the resulting nodes are visited. Hooks that return nodes replace the node they
+// were called with: return a slice with only the node to keep it, or an empty
+// slice to remove it.
+//
+// Content inlined from other files by ImportRulesInline has already been
+// transformed when its file was compiled, so it is not visited again.
+//
+// Files are compiled concurrently, so hooks may be called from several goroutines
+// at once, each with a different stylesheet. Hooks that share state between files
+// must synchronize access to it.
+type Plugin struct {
+	// Name is the name of the plugin, which is used in error messages.
+	Name string
+
+	// Stage is when the plugin runs.
+	Stage PluginStage
+
+	// Stylesheet is called once for each stylesheet, before any of the other hooks.
+	Stylesheet func(ss *ast.Stylesheet, ctx PluginContext)
+
+	// Rule is called for each qualified (style) rule, including nested rules.
+	Rule func(rule *ast.QualifiedRule, ctx PluginContext) []ast.Node
+
+	// AtRule is called for each at-rule, including nested at-rules.
+	AtRule func(rule *ast.AtRule, ctx PluginContext) []ast.Node
+
+	// Declaration is called for each declaration.
+	Declaration func(decl *ast.Declaration, ctx PluginContext) []*ast.Declaration
+
+	// Value is called for each value of a declaration, for each argument of
+	// functions in those values, and for each operand of math expressions, e.g. the
+	// 1px in calc(1px + 2px). Operands must be replaced with exactly one value.
+	Value func(value ast.Value, ctx PluginContext) []ast.Value
+
+	// Selector is called for each selector in the selector list of a style rule.
+	Selector func(sel *ast.Selector, ctx PluginContext) []*ast.Selector
+}
+
+// PluginContext is passed to each plugin hook.
+type PluginContext interface {
+	// Path is the path of the stylesheet being transformed.
+	Path() string
+
+	// Errorf reports an error at the location of node.
+	Errorf(node ast.Node, format string, args ...interface{})
+
+	// Warnf reports a warning at the location of node.
+	Warnf(node ast.Node, format string, args ...interface{})
+
+	// SetOriginalName records the name that node had in the source before the plugin
+	// renamed it, e.g. the original name of a css module class. It is written to the
+	// source map's names.
+	SetOriginalName(node ast.Node, name string)
+}
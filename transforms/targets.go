@@ -0,0 +1,325 @@
+package transforms
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Browser is a browser that can be targeted.
+type Browser int
+
+const (
+	Chrome Browser = iota
+	Edge
+	Firefox
+	Safari
+	IOSSafari
+	Opera
+	Samsung
+	Android
+	IE
+)
+
+// browserNames is the browserslist name of each browser.
+var browserNames = map[Browser]string{
+	Chrome:    "chrome",
+	Edge:      "edge",
+	Firefox:   "firefox",
+	Safari:    "safari",
+	IOSSafari: "ios_saf",
+	Opera:     "opera",
+	Samsung:   "samsung",
+	Android:   "android",
+	IE:        "ie",
+}
+
+// browserAliases maps the names that browserslist accepts for each browser, in lower case.
+// Mobile versions of browsers that release with their desktop versions are treated as the
+// desktop browser.
+var browserAliases = map[string]Browser{
+	"chrome":           Chrome,
+	"and_chr":          Chrome,
+	"chromeandroid":    Chrome,
+	"edge":             Edge,
+	"firefox":          Firefox,
+	"ff":               Firefox,
+	"and_ff":           Firefox,
+	"firefoxandroid":   Firefox,
+	"safari":           Safari,
+	"ios_saf":          IOSSafari,
+	"ios":              IOSSafari,
+	"opera":            Opera,
+	"samsung":          Samsung,
+	"android":          Android,
+	"ie":               IE,
+	"explorer":         IE,
+	"internetexplorer": IE,
+}
+
+// String returns the browserslist name of the browser.
+func (b Browser) String() string {
+	if name, ok := browserNames[b]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Browser(%d)", int(b))
+}
+
+// Version is a browser version, e.g. Version{16, 4} for Safari 16.4.
+type Version struct {
+	Major, Minor int
+}
+
+// Less returns whether or not v is an earlier version than other.
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+
+	return v.Minor < other.Minor
+}
+
+// String returns the version as it is written in browserslist queries.
+func (v Version) String() string {
+	if v.Minor == 0 {
+		return strconv.Itoa(v.Major)
+	}
+
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// parseVersion parses a version like 15 or 15.4. For ranges like 15.2-15.3, as
+// browserslist uses for iOS Safari, the first version is used.
+func parseVersion(s string) (Version, error) {
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s = s[:i]
+	}
+
+	var v Version
+	parts := strings.SplitN(s, ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 0 {
+		return v, fmt.Errorf("invalid version: %s", s)
+	}
+	v.Major = major
+
+	if len(parts) > 1 {
+		minor, err := strconv.Atoi(parts[1])
+		if err != nil || minor < 0 {
+			return v, fmt.Errorf("invalid version: %s", s)
+		}
+		v.Minor = minor
+	}
+
+	return v, nil
+}
+
+// Targets is the minimum version of each browser to support. Browsers that are
+// not in Targets are not supported. If Targets is empty, no transforms are enabled
+// by it.
+type Targets map[Browser]Version
+
+// ParseTargets parses a list of browserslist queries into Targets. Each query may
+// itself be a comma-separated list. The supported queries are:
+//
+//	chrome >= 90, safari > 14, firefox 100   minimum versions of a browser
+//	last 2 versions, last 2 chrome versions  the latest versions of each browser, or one browser
+//	dead                                     browsers without updates, i.e. ie
+//	not ie, not dead, not safari < 15        remove browsers or versions from the previous queries
+//
+// Queries based on usage statistics, e.g. > 0.5% or defaults, are not supported, since
+// cssc does not bundle usage data.
+func ParseTargets(queries ...string) (Targets, error) {
+	t := make(Targets)
+	for _, q := range queries {
+		for _, query := range splitQueries(q) {
+			if err := t.apply(query); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// splitQueries splits a query on commas and "or".
+func splitQueries(q string) []string {
+	var rv []string
+	for _, part := range strings.Split(q, ",") {
+		for _, query := range strings.Split(part, " or ") {
+			if query = strings.TrimSpace(query); query != "" {
+				rv = append(rv, query)
+			}
+		}
+	}
+
+	return rv
+}
+
+// apply adds the browsers matched by a single query to t, or removes
+// them if the query starts with not.
+func (t Targets) apply(query string) error {
+	fields := strings.Fields(strings.ToLower(query))
+	not := len(fields) > 0 && fields[0] == "not"
+	if not {
+		fields = fields[1:]
+	}
+
+	matched, err := matchQuery(fields)
+	if err != nil {
+		return fmt.Errorf("unsupported browserslist query %q: %w", query, err)
+	}
+
+	for _, m := range matched {
+		if not {
+			t.remove(m)
+			continue
+		}
+
+		if current, ok := t[m.browser]; !ok || m.min.Less(current) {
+			t[m.browser] = m.min
+		}
+	}
+
+	return nil
+}
+
+// remove removes the versions matched by m from t.
+func (t Targets) remove(m match) {
+	current, ok := t[m.browser]
+	if !ok || current.Less(m.min) {
+		// Since targets are minimum versions, newer versions can't be removed.
+		return
+	}
+
+	if m.end == nil {
+		delete(t, m.browser)
+		return
+	}
+
+	if current.Less(*m.end) {
+		t[m.browser] = *m.end
+	}
+}
+
+// match is a range of versions of a browser matched by a query. end is the first
+// version after the range, or nil if every version from min is matched.
+type match struct {
+	browser Browser
+	min     Version
+	end     *Version
+}
+
+// matchQuery returns the browsers matched by the fields of a query.
+func matchQuery(fields []string) ([]match, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	switch fields[0] {
+	case "dead":
+		if len(fields) == 1 {
+			var rv []match
+			for b := range dead {
+				rv = append(rv, match{browser: b})
+			}
+			return rv, nil
+		}
+
+	case "last":
+		return matchLast(fields)
+	}
+
+	b, ok := browserAliases[fields[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown browser: %s", fields[0])
+	}
+
+	switch len(fields) {
+	case 1:
+		return []match{{browser: b}}, nil
+
+	case 2:
+		v, err := parseVersion(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		end := next(b, v)
+		return []match{{browser: b, min: v, end: &end}}, nil
+
+	case 3:
+		v, err := parseVersion(fields[2])
+		if err != nil {
+			return nil, err
+		}
+
+		switch fields[1] {
+		case ">=":
+			return []match{{browser: b, min: v}}, nil
+		case ">":
+			return []match{{browser: b, min: next(b, v)}}, nil
+		case "<=":
+			end := next(b, v)
+			return []match{{browser: b, end: &end}}, nil
+		case "<":
+			return []match{{browser: b, end: &v}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("expected <browser>, <browser> <version>, or <browser> <op> <version>")
+}
+
+// matchLast matches last N versions and last N <browser> versions queries.
+func matchLast(fields []string) ([]match, error) {
+	if len(fields) < 3 || len(fields) > 4 || (fields[len(fields)-1] != "versions" && fields[len(fields)-1] != "version") {
+		return nil, fmt.Errorf("expected last N versions or last N <browser> versions")
+	}
+
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid number of versions: %s", fields[1])
+	}
+
+	var browsers []Browser
+	if len(fields) == 4 {
+		b, ok := browserAliases[fields[2]]
+		if !ok {
+			return nil, fmt.Errorf("unknown browser: %s", fields[2])
+		}
+		browsers = []Browser{b}
+	} else {
+		for b := range releases {
+			if !dead[b] {
+				browsers = append(browsers, b)
+			}
+		}
+	}
+
+	var rv []match
+	for _, b := range browsers {
+		versions := releases[b]
+		if len(versions) == 0 {
+			continue
+		}
+
+		i := len(versions) - n
+		if i < 0 {
+			i = 0
+		}
+		rv = append(rv, match{browser: b, min: versions[i]})
+	}
+
+	return rv, nil
+}
+
+// next returns the first release of b after v.
+func next(b Browser, v Version) Version {
+	for _, r := range releases[b] {
+		if v.Less(r) {
+			return r
+		}
+	}
+
+	return Version{Major: v.Major + 1}
+}
@@ -0,0 +1,115 @@
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargets(t *testing.T) {
+	for _, tc := range []struct {
+		queries  []string
+		expected transforms.Targets
+	}{
+		{
+			[]string{"chrome >= 90, Safari >= 14.1", "firefox 100"},
+			transforms.Targets{
+				transforms.Chrome:  {Major: 90},
+				transforms.Safari:  {Major: 14, Minor: 1},
+				transforms.Firefox: {Major: 100},
+			},
+		},
+		{
+			[]string{"ios_saf 15.2-15.3 or ie 11"},
+			transforms.Targets{
+				transforms.IOSSafari: {Major: 15, Minor: 2},
+				transforms.IE:        {Major: 11},
+			},
+		},
+		{
+			// The lowest version wins.
+			[]string{"chrome >= 100, chrome >= 90, chrome > 95"},
+			transforms.Targets{transforms.Chrome: {Major: 90}},
+		},
+		{
+			[]string{"last 2 chrome versions, last 3 safari versions"},
+			transforms.Targets{
+				transforms.Chrome: {Major: 140},
+				transforms.Safari: {Major: 18, Minor: 5},
+			},
+		},
+		{
+			[]string{"chrome >= 90, ie 11, safari >= 12, firefox >= 80", "not dead, not safari < 14, not firefox"},
+			transforms.Targets{
+				transforms.Chrome: {Major: 90},
+				transforms.Safari: {Major: 14},
+			},
+		},
+		{
+			// Newer versions can't be removed from a minimum version.
+			[]string{"chrome >= 90, not chrome >= 100"},
+			transforms.Targets{transforms.Chrome: {Major: 90}},
+		},
+	} {
+		targets, err := transforms.ParseTargets(tc.queries...)
+		require.NoError(t, err, tc.queries)
+		assert.Equal(t, tc.expected, targets, tc.queries)
+	}
+}
+
+func TestParseTargets_LastVersions(t *testing.T) {
+	targets, err := transforms.ParseTargets("last 1 versions")
+	require.NoError(t, err)
+	assert.NotContains(t, targets, transforms.IE, "dead browsers are left out")
+	assert.Contains(t, targets, transforms.Chrome)
+	assert.Contains(t, targets, transforms.IOSSafari)
+}
+
+func TestParseTargets_Errors(t *testing.T) {
+	for _, q := range []string{
+		"> 0.5%",
+		"defaults",
+		"netscape >= 4",
+		"chrome >= abc",
+		"chrome ~ 90",
+		"last two versions",
+		"not",
+	} {
+		_, err := transforms.ParseTargets(q)
+		assert.Error(t, err, q)
+	}
+}
+
+func TestOptions_WithTargets(t *testing.T) {
+	assert.Equal(t, transforms.Options{}, transforms.Options{}.WithTargets())
+
+	modern, err := transforms.ParseTargets("chrome >= 120, firefox >= 117, safari >= 17.2")
+	require.NoError(t, err)
+	assert.Equal(t, transforms.Options{
+		Targets:            modern,
+		CustomMediaQueries: transforms.CustomMediaQueriesTransform,
+	}, transforms.Options{Targets: modern}.WithTargets())
+
+	old, err := transforms.ParseTargets("chrome >= 100, safari >= 15, ie 11")
+	require.NoError(t, err)
+	assert.Equal(t, transforms.Options{
+		Targets:            old,
+		MediaFeatureRanges: transforms.MediaFeatureRangesTransform,
+		AnyLink:            transforms.AnyLinkTransform,
+		CustomProperties:   transforms.CustomPropertiesTransformRoot,
+		CustomMediaQueries: transforms.CustomMediaQueriesTransform,
+		Nesting:            transforms.NestingTransform,
+	}, transforms.Options{Targets: old}.WithTargets())
+
+	// Transforms that are set explicitly stay on.
+	assert.Equal(t, transforms.AnyLinkTransform, transforms.Options{Targets: modern, AnyLink: transforms.AnyLinkTransform}.WithTargets().AnyLink)
+}
+
+func TestTargets_Supports(t *testing.T) {
+	assert.True(t, transforms.Targets{transforms.Safari: {Major: 16, Minor: 4}}.Supports(transforms.FeatureMediaFeatureRanges))
+	assert.False(t, transforms.Targets{transforms.Safari: {Major: 16, Minor: 3}}.Supports(transforms.FeatureMediaFeatureRanges))
+	assert.False(t, transforms.Targets{transforms.IE: {Major: 11}}.Supports(transforms.FeatureAnyLink))
+	assert.False(t, transforms.Targets{transforms.Chrome: {Major: 130}}.Supports(transforms.FeatureCustomMediaQueries))
+}
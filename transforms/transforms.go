@@ -6,8 +6,8 @@ type ImportRules int
 const (
 	// ImportRulesPassthrough passes @imports down without changes. It is the default.
 	ImportRulesPassthrough ImportRules = iota
-	// ImportRulesInline inlines imported content where an @import statement is seen. In this
-	// version, it ignores @supports rules and meedia queries.
+	// ImportRulesInline inlines imported content where an @import statement is seen. Imports
+	// with conditions are wrapped in matching @media, @supports, and @layer rules.
 	ImportRulesInline
 )
 
@@ -48,6 +48,18 @@ const (
 	// CustomPropertiesTransformRoot will transform properties defiend in :root selectors. Custom property definitions
 	// under any other selectors will be ignored and passed through.
 	CustomPropertiesTransformRoot
+	// CustomPropertiesTransformScoped adds a declaration with var() substituted before each declaration
+	// that uses it, e.g. color: red; color: var(--x), and keeps custom property definitions. Custom
+	// properties defined in rules with the same selector are used before ones from :root, and var()
+	// references in definitions and fallbacks are resolved too. A warning is reported for each
+	// definition that redefines a custom property outside of :root, since which value applies depends
+	// on the document.
+	CustomPropertiesTransformScoped
+	// CustomPropertiesTransformRootPreserve substitutes var() with properties defined in :root selectors
+	// like CustomPropertiesTransformRoot, but keeps the custom property definitions and adds the substituted
+	// declaration before the original one, e.g. color: red; color: var(--x), so that custom properties still
+	// work in browsers that support them, including when they are read or set from JavaScript.
+	CustomPropertiesTransformRootPreserve
 )
 
 // CustomMediaQueries controls transform options for @custom-media usage, specified in CSS Media Queries Level 5.
@@ -69,11 +81,43 @@ type CalcReduction int
 const (
 	// CalcReductionPassthrough passes through any math functions. It is the default.
 	CalcReductionPassthrough CalcReduction = iota
-	// CalcReductionReduce will attempt to reduce all math functions. If the call cannot be fully reduced, it
-	// will be left.
+	// CalcReductionReduce will attempt to reduce all math functions. Terms with the same unit are added, nested
+	// calc() is folded, and absolute units of the same type are converted, e.g. 1in + 4px becomes 100px. min(),
+	// max() and clamp() are reduced if all of their arguments can be. If the call cannot be fully reduced, it
+	// will be left, with the parts that could be simplified.
 	CalcReductionReduce
 )
 
+// Nesting controls transform options for nested style rules, specified in CSS Nesting Module Level 1.
+// See: https://www.w3.org/TR/css-nesting-1/.
+type Nesting int
+
+const (
+	// NestingPassthrough passes nested rules through. It is the default.
+	NestingPassthrough Nesting = iota
+	// NestingTransform flattens nested rules into top-level rules by replacing the nesting selector (&)
+	// with the parent rule's selectors.
+	NestingTransform
+)
+
+// Prefixes controls transform options for vendor prefixes, like autoprefixer.
+type Prefixes int
+
+const (
+	// PrefixesPassthrough leaves vendor prefixes as they are. It is the default.
+	PrefixesPassthrough Prefixes = iota
+	// PrefixesAdd adds the vendor-prefixed declarations, values, selectors and at-rules that
+	// Targets need, e.g. -webkit-user-select or ::-moz-selection, next to the unprefixed
+	// ones. Nothing is added if Targets is empty.
+	PrefixesAdd
+	// PrefixesRemove removes vendor-prefixed declarations, selectors and at-rules that none of
+	// Targets need, if the unprefixed ones are next to them. Nothing is removed if Targets is empty.
+	PrefixesRemove
+	// PrefixesAddAndRemove removes the vendor prefixes that Targets don't need, then adds
+	// the ones they do.
+	PrefixesAddAndRemove
+)
+
 // Options sets options about what transforms to run. By default,
 // no transforms are run.
 type Options struct {
@@ -83,4 +127,17 @@ type Options struct {
 	CustomProperties
 	CustomMediaQueries
 	CalcReduction
+	Nesting
+	Prefixes
+
+	// Targets is the set of browsers to support. Transforms for features that any of
+	// them lack are turned on automatically. See ParseTargets.
+	Targets Targets
+
+	// WarnRemovedPrefixes reports a warning for each vendor prefix that PrefixesRemove
+	// removes.
+	WarnRemovedPrefixes bool
+
+	// Plugins is the list of third-party transforms to run, in order.
+	Plugins []Plugin
 }